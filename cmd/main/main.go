@@ -2,11 +2,15 @@ package main
 
 import (
 	"bbb/configs"
+	"bbb/internal/api"
 	"bbb/internal/dto"
 	"bbb/internal/handlers"
 	"bbb/internal/repository"
 	service "bbb/internal/services"
 	"log"
+	"net/http"
+	"time"
+	_ "time/tzdata" // Users pick their own time zone, so the zone database is embedded in case the host lacks one
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"gorm.io/gorm"
@@ -17,41 +21,87 @@ var (
 	db  *gorm.DB    = configs.SetUpDatabaseConnection(env)
 
 	// Repositories
-	processRepo repository.ProcessRepository = repository.NewProcessRepository(db)
-	taskRepo    repository.TaskRepository    = repository.NewTaskRepository(db)
-	userRepo    repository.UserRepository    = repository.NewUserRepository(db)
-	teamRepo    repository.TeamRepository    = repository.NewTeamRepository(db)
+	processRepo      repository.ProcessRepository      = repository.NewProcessRepository(db)
+	taskRepo         repository.TaskRepository         = repository.NewTaskRepository(db)
+	userRepo         repository.UserRepository         = repository.NewUserRepository(db)
+	teamRepo         repository.TeamRepository         = repository.NewTeamRepository(db)
+	formRepo         repository.FormRepository         = repository.NewFormRepository(db)
+	attachmentRepo   repository.AttachmentRepository   = repository.NewAttachmentRepository(db)
+	commentRepo      repository.CommentRepository      = repository.NewCommentRepository(db)
+	apiTokenRepo     repository.APITokenRepository     = repository.NewAPITokenRepository(db)
+	webhookRepo      repository.WebhookRepository      = repository.NewWebhookRepository(db)
+	outboxRepo       repository.OutboxRepository       = repository.NewOutboxRepository(db)
+	notificationRepo repository.NotificationRepository = repository.NewNotificationRepository(db)
+	searchRepo       repository.SearchRepository       = repository.NewSearchRepository(db)
 
 	// Bot
-	bot *tgbotapi.BotAPI
+	sendLimiter = service.NewSendLimiter(&http.Client{}, env.SendRate, env.ChatSendInterval, env.GroupSendInterval)
+	bot         *tgbotapi.BotAPI
+	bulkBot     *tgbotapi.BotAPI // Same bot, but its messages wait while replies to users are queued
 
 	// Services
-	userService             = service.NewUserService(userRepo)
-	teamService             = service.NewTeamService(teamRepo, userRepo)
-	processService          = service.NewProcessService(processRepo)
-	processBuilderService   = service.NewProcessBuilderService()
-	processExecutionService = service.NewProcessExecutionService(processRepo, taskRepo, teamRepo)
-	taskBuilderService      = service.NewTaskBuilderService()
-	taskService             service.TaskService
-	teamBuilderService      = service.NewTeamBuilderService()
+	userService                = service.NewUserService(userRepo)
+	teamService                service.TeamService
+	processService             = service.NewProcessService(processRepo)
+	processBuilderService      = service.NewProcessBuilderService()
+	processExecutionService    = service.NewProcessExecutionService(processRepo, taskRepo, teamRepo)
+	taskBuilderService         = service.NewTaskBuilderService()
+	taskService                service.TaskService
+	outboxService              service.OutboxService
+	teamBuilderService         = service.NewTeamBuilderService()
+	availabilityBuilderService = service.NewAvailabilityBuilderService()
+	taskFormService            = service.NewTaskFormService(formRepo, taskRepo, processService)
+	formFieldBuilderService    = service.NewFormFieldBuilderService()
+	formFillService            = service.NewFormFillService()
+	attachmentService          = service.NewAttachmentService(attachmentRepo, taskRepo)
+	attachmentSessionService   = service.NewAttachmentSessionService()
+	commentSessionService      = service.NewCommentSessionService()
+	commentService             service.CommentService
+	apiTokenService            = service.NewAPITokenService(apiTokenRepo)
+	webhookSessionService      = service.NewWebhookSessionService()
+	webhookService             = service.NewWebhookService(service.NewWebhookClient(15*time.Second), 3, 2*time.Second)
+	eventService               service.EventService
+	notificationService        service.NotificationService
+	digestService              service.DigestService
+	searchService              = service.NewSearchService(searchRepo, processRepo, taskRepo, teamRepo)
+	searchSessionService       = service.NewSearchSessionService()
+	reportService              = service.NewReportService(processRepo, taskRepo, teamRepo, env.TimeLocation)
 
 	// Handlers
-	teamHandler    *handlers.TeamHandler
-	taskHandler    *handlers.TaskHandler
-	processHandler *handlers.ProcessHandler
-	helpHandler    *handlers.HelpHandler
-	startHandler   *handlers.StartHandler
+	teamHandler         *handlers.TeamHandler
+	taskHandler         *handlers.TaskHandler
+	processHandler      *handlers.ProcessHandler
+	helpHandler         *handlers.HelpHandler
+	startHandler        *handlers.StartHandler
+	availabilityHandler *handlers.AvailabilityHandler
+	commentHandler      *handlers.CommentHandler
+	apiTokenHandler     *handlers.APITokenHandler
+	webhookHandler      *handlers.WebhookHandler
+	outboxHandler       *handlers.OutboxHandler
+	settingsHandler     *handlers.SettingsHandler
+	groupHandler        *handlers.GroupHandler
+	inlineHandler       *handlers.InlineHandler
+	searchHandler       *handlers.SearchHandler
+	reportHandler       *handlers.ReportHandler
+
+	// HTTP API
+	apiServer *api.Server
 )
 
 var mainKeyboard = tgbotapi.NewReplyKeyboard(
 	tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("فرایند جدید"), tgbotapi.NewKeyboardButton("شروع فرایند"), tgbotapi.NewKeyboardButton("فرایند ها")),
 	tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("وظیفه جدید"), tgbotapi.NewKeyboardButton("وظایف من"), tgbotapi.NewKeyboardButton("لیست تیم ها")),
 	tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("تیم جدید"), tgbotapi.NewKeyboardButton("عضویت در تیم"), tgbotapi.NewKeyboardButton("راهنما")),
+	tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("وضعیت حضور"), tgbotapi.NewKeyboardButton("تنظیمات"), tgbotapi.NewKeyboardButton("جستجو")),
 )
 
 func init() {
 	var err error
-	bot, err = tgbotapi.NewBotAPIWithAPIEndpoint(env.Token, env.APIEndpoint)
+	bot, err = tgbotapi.NewBotAPIWithClient(env.Token, env.APIEndpoint, sendLimiter.Client(service.SendPriorityInteractive))
+	if err != nil {
+		log.Panic(err)
+	}
+	bulkBot, err = tgbotapi.NewBotAPIWithClient(env.Token, env.APIEndpoint, sendLimiter.Client(service.SendPriorityBulk))
 	if err != nil {
 		log.Panic(err)
 	}
@@ -60,14 +110,34 @@ func init() {
 	log.Printf("Authorized on account %s", bot.Self.UserName)
 
 	// Initialize taskService with bot
-	taskService = service.NewTaskService(taskRepo, teamService, processService, bot)
+	teamService = service.NewTeamService(teamRepo, userRepo, bot)
+	eventService = service.NewEventService(webhookRepo, processService, teamService, taskFormService, webhookService)
+	businessHours := service.NewBusinessHours(env.WorkDays, env.WorkHours, env.TimeLocation)
+	outboxService = service.NewOutboxService(outboxRepo, taskRepo, bulkBot)
+	commentService = service.NewCommentService(commentRepo, taskRepo, processService, teamService, userService, outboxService)
+	notificationService = service.NewNotificationService(notificationRepo, outboxService, env.TimeLocation)
+	taskService = service.NewTaskService(taskRepo, teamService, processService, userService, taskFormService, attachmentService, businessHours, webhookService, eventService, outboxService, notificationService, bulkBot)
+	outboxService.SetTaskRenderer(taskService)
+	digestService = service.NewDigestService(notificationService, taskService, processService, bulkBot)
 
 	// Initialize handlers
 	teamHandler = handlers.NewTeamHandler(teamService, userService, teamBuilderService)
-	taskHandler = handlers.NewTaskHandler(taskService, taskBuilderService, processService, teamService)
-	processHandler = handlers.NewProcessHandler(processService, processBuilderService, processExecutionService, taskService)
+	taskHandler = handlers.NewTaskHandler(taskService, taskBuilderService, processService, teamService, taskFormService, formFieldBuilderService, formFillService, attachmentService, attachmentSessionService, notificationService, env.TimeLocation)
+	processHandler = handlers.NewProcessHandler(processService, processBuilderService, processExecutionService, taskService, taskFormService, formFillService, attachmentService, env.TimeLocation)
 	helpHandler = handlers.NewHelpHandler(env, &mainKeyboard)
 	startHandler = handlers.NewStartHandler(&mainKeyboard)
+	availabilityHandler = handlers.NewAvailabilityHandler(userService, availabilityBuilderService, env.TimeLocation)
+	commentHandler = handlers.NewCommentHandler(commentService, commentSessionService, taskService, env.TimeLocation)
+	apiTokenHandler = handlers.NewAPITokenHandler(apiTokenService, env.TimeLocation)
+	webhookHandler = handlers.NewWebhookHandler(eventService, webhookSessionService, processService, teamService, env.TimeLocation)
+	outboxHandler = handlers.NewOutboxHandler(outboxService, userService, env.TimeLocation)
+	settingsHandler = handlers.NewSettingsHandler(notificationService, digestService)
+	groupHandler = handlers.NewGroupHandler(teamService, userService, taskService)
+	searchHandler = handlers.NewSearchHandler(searchService, searchSessionService)
+	reportHandler = handlers.NewReportHandler(reportService, teamService, env.TimeLocation)
+	inlineHandler = handlers.NewInlineHandler(searchService, processService, taskService, teamService, taskFormService, formFillService, attachmentService, env.TimeLocation)
+
+	apiServer = api.NewServer(apiTokenService, processService, taskService, teamService, userService, taskFormService, sendLimiter)
 }
 
 func main() {
@@ -76,6 +146,16 @@ func main() {
 
 	updates := bot.GetUpdatesChan(u)
 
+	go sendLimiter.Run()
+	go watchAvailability()
+	go watchScheduledTasks()
+	go runServiceTasks()
+	go deliverEvents()
+	go dispatchOutbox()
+	go taskService.RunNotificationEdits()
+	go sendDigests()
+	go serveAPI()
+
 	for update := range updates {
 		if update.Message != nil {
 			// Generic message sender with main keyboard
@@ -100,14 +180,40 @@ func main() {
 				log.Printf("Error saving/updating user: %v", err)
 			}
 
+			// The main keyboard and the handlers keeping per-user sessions belong to the private chat, so group
+			// chats only get the group team commands and comments replying to task announcements
+			if !update.Message.Chat.IsPrivate() {
+				sendGroupMessage := func(chatID int64, text string) {
+					if _, err := bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+						log.Printf("Error sending group message: %v", err)
+					}
+				}
+				groupHandler.HandleGroupMessages(bot, update, sendGroupMessage)
+				commentHandler.HandleCommentMessage(bot, update, sendGroupMessage)
+				continue
+			}
+
 			// Pass bot, update, and the sender function to handlers
 			startHandler.HandleStartCommand(bot, update, sendMessageWithKeyboard)
 			processHandler.HandleProcessCreation(bot, update, sendMessageWithKeyboard)
 			processHandler.HandleProcessExecution(bot, update, sendMessageWithKeyboard)
 			processHandler.HandleProcessCommands(bot, update, sendMessageWithKeyboard)
 			taskHandler.HandleTaskCreation(bot, update, sendMessageWithKeyboard)
+			taskHandler.HandleMyTasks(bot, update, sendMessageWithKeyboard)
+			taskHandler.HandleTaskForm(bot, update, sendMessageWithKeyboard)
+			taskHandler.HandleTaskAttachment(bot, update, sendMessageWithKeyboard)
 			teamHandler.HandleTeamCommands(bot, update, sendMessageWithKeyboard)
 			helpHandler.HandleHelpCommand(bot, update, sendMessageWithKeyboard)
+			availabilityHandler.HandleAvailabilityCommands(bot, update, sendMessageWithKeyboard)
+			commentHandler.HandleCommentMessage(bot, update, sendMessageWithKeyboard)
+			apiTokenHandler.HandleAPITokenCommands(bot, update, sendMessageWithKeyboard)
+			webhookHandler.HandleWebhookCommands(bot, update, sendMessageWithKeyboard)
+			outboxHandler.HandleOutboxCommands(bot, update, sendMessageWithKeyboard)
+			settingsHandler.HandleSettingsCommands(bot, update, sendMessageWithKeyboard)
+			searchHandler.HandleSearchMessage(bot, update, sendMessageWithKeyboard)
+
+		} else if update.InlineQuery != nil {
+			inlineHandler.HandleInlineQuery(bot, update)
 
 		} else if update.CallbackQuery != nil {
 			// Generic message sender for callback responses (might also include main keyboard)
@@ -119,10 +225,140 @@ func main() {
 				}
 			}
 
+			// Save or update the user, who may have only clicked a button in a team's group so far
+			if err := userService.SaveOrUpdateUser(dto.Message{
+				From: dto.User{
+					ID:         update.CallbackQuery.From.ID,
+					First_name: update.CallbackQuery.From.FirstName,
+					Last_name:  update.CallbackQuery.From.LastName,
+					Username:   update.CallbackQuery.From.UserName,
+				},
+			}); err != nil {
+				log.Printf("Error saving/updating user: %v", err)
+			}
+
+			// Buttons of cards shared through inline mode belong to no chat the other handlers know of
+			if update.CallbackQuery.Message == nil {
+				inlineHandler.HandleInlineCallback(bot, update, sendCallbackMessageWithKeyboard)
+				continue
+			}
+
 			// Pass bot, update, and the sender function to callback handlers
 			processHandler.HandleProcessCallback(bot, update, sendCallbackMessageWithKeyboard)
 			taskHandler.HandleCallbackQuery(bot, update, sendCallbackMessageWithKeyboard)
 			teamHandler.HandleTeamCallback(bot, update, sendCallbackMessageWithKeyboard)
+			availabilityHandler.HandleAvailabilityCallback(bot, update, sendCallbackMessageWithKeyboard)
+			commentHandler.HandleCommentCallback(bot, update, sendCallbackMessageWithKeyboard)
+			apiTokenHandler.HandleAPITokenCallback(bot, update, sendCallbackMessageWithKeyboard)
+			webhookHandler.HandleWebhookCallback(bot, update, sendCallbackMessageWithKeyboard)
+			outboxHandler.HandleOutboxCallback(bot, update, sendCallbackMessageWithKeyboard)
+			settingsHandler.HandleSettingsCallback(bot, update, sendCallbackMessageWithKeyboard)
+			groupHandler.HandleGroupCallback(bot, update, sendCallbackMessageWithKeyboard)
+			searchHandler.HandleSearchCallback(bot, update, sendCallbackMessageWithKeyboard)
+			reportHandler.HandleReportCallback(bot, update, sendCallbackMessageWithKeyboard)
+		}
+	}
+}
+
+// watchAvailability periodically ends away periods that are over and lets the users know
+func watchAvailability() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		users, err := userService.ClearExpiredAvailability()
+		if err != nil {
+			log.Printf("Error clearing expired availability: %v", err)
+			continue
+		}
+		for _, user := range users {
+			msg := tgbotapi.NewMessage(user.ID, "مرخصی شما به پایان رسید و وضعیت شما به «در دسترس» تغییر کرد.")
+			msg.ReplyMarkup = mainKeyboard
+			if _, err := bulkBot.Send(msg); err != nil {
+				log.Printf("Error sending availability message: %v", err)
+			}
 		}
 	}
 }
+
+// watchScheduledTasks periodically completes the timer tasks whose waiting time is over and reports the tasks
+// that passed their deadline
+func watchScheduledTasks() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := taskService.RunScheduledTasks(); err != nil {
+			log.Printf("Error running scheduled tasks: %v", err)
+		}
+		if err := taskService.PublishOverdueTasks(); err != nil {
+			log.Printf("Error publishing overdue tasks: %v", err)
+		}
+	}
+}
+
+// runServiceTasks periodically calls the webhooks of the waiting service tasks. Webhooks may be slow to answer,
+// so they get their own worker and never hold up the timers.
+func runServiceTasks() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := taskService.RunServiceTasks(); err != nil {
+			log.Printf("Error running service tasks: %v", err)
+		}
+	}
+}
+
+// dispatchOutbox sends the queued bot messages as soon as they are queued, and retries failed ones periodically
+func dispatchOutbox() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-outboxService.Pending():
+		}
+		if err := outboxService.Dispatch(); err != nil {
+			log.Printf("Error dispatching outbox: %v", err)
+		}
+	}
+}
+
+// sendDigests periodically sends the users' daily and weekly digests whose time has come
+func sendDigests() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := digestService.SendDueDigests(); err != nil {
+			log.Printf("Error sending digests: %v", err)
+		}
+	}
+}
+
+// deliverEvents periodically sends the queued lifecycle events to the webhook subscriptions
+func deliverEvents() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := eventService.DeliverDue(); err != nil {
+			log.Printf("Error delivering webhook events: %v", err)
+		}
+	}
+}
+
+// serveAPI runs the HTTP API other systems use to start processes and work on tasks
+func serveAPI() {
+	server := &http.Server{
+		Addr:              env.HTTPAddress,
+		Handler:           apiServer.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("HTTP API listening on %s", env.HTTPAddress)
+	if err := server.ListenAndServe(); err != nil {
+		log.Printf("HTTP API stopped: %v", err)
+	}
+}
@@ -30,11 +30,46 @@ func SetUpDatabaseConnection(env Env) *gorm.DB {
 		&models.PendingTask{},
 		&models.CompletedTask{},
 		&models.InProgressTask{},
+		&models.TaskNotification{},
+		&models.TaskAssignmentLog{},
+		&models.ProcessVariable{},
+		&models.TaskAttachment{},
+		&models.Comment{},
+		&models.TaskFormField{},
+		&models.TaskFormAnswer{},
+		&models.APIToken{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.OutboxMessage{},
+		&models.NotificationSettings{},
 	)
 	if err != nil {
 		fmt.Println(err)
 		panic("AutoMigrate failed")
 	}
 
+	for _, statement := range searchStatements {
+		if err := db.Exec(statement).Error; err != nil {
+			fmt.Println(err)
+			panic("Setting up search failed")
+		}
+	}
+
 	return db
 }
+
+// searchStatements set up full-text search. search_normalize folds the ways the same Persian text gets typed: Arabic
+// yeh and kaf and other Arabic letter forms become their Persian forms, Arabic and Persian digits become ASCII digits,
+// diacritics and tatweel are dropped and the zero-width non-joiner becomes a space, so «می‌شود» and «می شود» match. The indexes cover the texts that
+// grow with use; their expressions must stay identical to those in the search repository.
+var searchStatements = []string{
+	`CREATE OR REPLACE FUNCTION search_normalize(input text) RETURNS text AS $$
+		SELECT lower(translate(coalesce(input, ''),
+			'يكىۀةأإآؤئ٠١٢٣٤٥٦٧٨٩۰۱۲۳۴۵۶۷۸۹' || chr(8204) || 'ًٌٍَُِّْٰـ',
+			'یکیههاااوی01234567890123456789 '))
+	$$ LANGUAGE sql IMMUTABLE`,
+	`CREATE INDEX IF NOT EXISTS idx_task_executions_search ON task_executions
+		USING gin (to_tsvector('simple', search_normalize(user_description)))`,
+	`CREATE INDEX IF NOT EXISTS idx_comments_search ON comments
+		USING gin (to_tsvector('simple', search_normalize(text)))`,
+}
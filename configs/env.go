@@ -16,6 +16,12 @@ type Env struct {
 	HelpMessageID     int
 	HelpMessageChatID int64
 	APIEndpoint       string
+	WorkDays          string        // Working days for timer tasks, e.g. "6,0,1,2,3" where 0 is Sunday
+	WorkHours         string        // Daily working hours for timer tasks, e.g. "08:00-16:00"
+	HTTPAddress       string        // Address the HTTP API listens on, ":8080" by default
+	SendRate          int           // Messages the bot sends a second at most, 25 by default
+	ChatSendInterval  time.Duration // Wait between messages to one private chat, 1s by default
+	GroupSendInterval time.Duration // Wait between messages to one group, 3s by default
 }
 
 func NewEnv() Env {
@@ -24,6 +30,9 @@ func NewEnv() Env {
 	BotID, _ := strconv.ParseInt(os.Getenv("BOT_ID"), 10, 64)
 	HelpMessageID, _ := strconv.ParseInt(os.Getenv("HELP_MESSAGE_ID"), 10, 32)
 	HelpMessageChatID, _ := strconv.ParseInt(os.Getenv("HELP_MESSAGE_CHAT_ID"), 10, 64)
+	SendRate, _ := strconv.Atoi(os.Getenv("SEND_RATE"))
+	ChatSendInterval, errChat := time.ParseDuration(os.Getenv("CHAT_SEND_INTERVAL"))
+	GroupSendInterval, errGroup := time.ParseDuration(os.Getenv("GROUP_SEND_INTERVAL"))
 
 	env := Env{
 		AppEnv:            os.Getenv("APP_ENV"),
@@ -34,6 +43,25 @@ func NewEnv() Env {
 		Token:             os.Getenv("TOKEN"),
 		HelpMessageID:     int(HelpMessageID),
 		HelpMessageChatID: HelpMessageChatID,
+		WorkDays:          os.Getenv("WORK_DAYS"),
+		WorkHours:         os.Getenv("WORK_HOURS"),
+		HTTPAddress:       os.Getenv("HTTP_ADDRESS"),
+		SendRate:          SendRate,
+		ChatSendInterval:  ChatSendInterval,
+		GroupSendInterval: GroupSendInterval,
+	}
+
+	if env.HTTPAddress == "" {
+		env.HTTPAddress = ":8080"
+	}
+	if env.SendRate <= 0 {
+		env.SendRate = 25
+	}
+	if errChat != nil {
+		env.ChatSendInterval = time.Second
+	}
+	if errGroup != nil {
+		env.GroupSendInterval = 3 * time.Second
 	}
 
 	if env.AppEnv == "development" {
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// metrics reports the state of the bot's send queue in the Prometheus text format.
+// Like the rest of the API it needs a bearer token, so the scraper is configured with one.
+func (s *Server) metrics(w http.ResponseWriter, r *http.Request, _ int64) {
+	stats := s.sendLimiter.Stats()
+	pause := time.Until(stats.PausedUntil).Seconds()
+	if pause < 0 {
+		pause = 0
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP bot_send_queue_depth Messages waiting for their turn to be sent.")
+	fmt.Fprintln(w, "# TYPE bot_send_queue_depth gauge")
+	fmt.Fprintf(w, "bot_send_queue_depth{priority=\"interactive\"} %d\n", stats.InteractiveQueued)
+	fmt.Fprintf(w, "bot_send_queue_depth{priority=\"bulk\"} %d\n", stats.BulkQueued)
+	fmt.Fprintln(w, "# HELP bot_messages_sent_total Messages let through the send queue.")
+	fmt.Fprintln(w, "# TYPE bot_messages_sent_total counter")
+	fmt.Fprintf(w, "bot_messages_sent_total %d\n", stats.Sent)
+	fmt.Fprintln(w, "# HELP bot_send_throttled_total Times the messenger answered with a retry time.")
+	fmt.Fprintln(w, "# TYPE bot_send_throttled_total counter")
+	fmt.Fprintf(w, "bot_send_throttled_total %d\n", stats.Throttled)
+	fmt.Fprintln(w, "# HELP bot_send_paused_seconds Seconds left until sending resumes after throttling.")
+	fmt.Fprintln(w, "# TYPE bot_send_paused_seconds gauge")
+	fmt.Fprintf(w, "bot_send_paused_seconds %g\n", pause)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bbb/internal/dto"
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxExecutionTitleLength matches the size of the title column of process executions
+const maxExecutionTitleLength = 200
+
+func (s *Server) listProcesses(w http.ResponseWriter, r *http.Request, userID int64) {
+	processes, err := s.processService.GetProcessesByUserID(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting processes")
+		return
+	}
+	writeResult(w, http.StatusOK, processes)
+}
+
+// startProcessExecution starts a process of the user, filling its start form from the given variables
+func (s *Server) startProcessExecution(w http.ResponseWriter, r *http.Request, userID int64) {
+	processID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	process, err := s.processService.GetProcessByID(processID)
+	if err != nil || process == nil {
+		writeError(w, http.StatusNotFound, "process not found")
+		return
+	}
+	if process.UserID != userID {
+		writeError(w, http.StatusForbidden, "only the owner of the process can start it")
+		return
+	}
+
+	var req dto.StartProcessRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if utf8.RuneCountInString(req.Title) > maxExecutionTitleLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("title must be at most %d characters", maxExecutionTitleLength))
+		return
+	}
+
+	fields, err := s.formService.GetStartFields(processID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting start form")
+		return
+	}
+	variables, err := validateStartVariables(fields, req.Variables)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	execution, started, err := s.taskService.StartProcessExecution(processID, req.Title, variables)
+	if err != nil {
+		log.Printf("Error starting process %d from api: %v", processID, err)
+		writeError(w, http.StatusInternalServerError, "error starting process")
+		return
+	}
+	writeResult(w, http.StatusCreated, dto.ProcessExecutionResponse{
+		Execution:      execution,
+		Variables:      variables,
+		TaskExecutions: started,
+	})
+}
+
+// getProcessExecution returns the status, variables and task executions of a process execution of the user
+func (s *Server) getProcessExecution(w http.ResponseWriter, r *http.Request, userID int64) {
+	executionID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	execution, err := s.processService.GetProcessExecutionByID(executionID)
+	if err != nil || execution == nil {
+		writeError(w, http.StatusNotFound, "process execution not found")
+		return
+	}
+	process, err := s.processService.GetProcessByID(execution.ProcessID)
+	if err != nil || process == nil || process.UserID != userID {
+		writeError(w, http.StatusForbidden, "only the owner of the process can view its executions")
+		return
+	}
+
+	variables, err := s.processService.GetVariables(executionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting process variables")
+		return
+	}
+	taskExecutions, err := s.taskService.GetTaskExecutionsByProcessExecutionID(executionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting task executions")
+		return
+	}
+	writeResult(w, http.StatusOK, dto.ProcessExecutionResponse{
+		Execution:      execution,
+		Variables:      variables,
+		TaskExecutions: taskExecutions,
+	})
+}
+
+// validateStartVariables checks the values given for the start form fields the same way the bot checks typed answers.
+// Variables that don't belong to a field are passed through unchanged.
+func validateStartVariables(fields []models.TaskFormField, values map[string]string) (map[string]string, error) {
+	variables := make(map[string]string, len(values))
+	for name, value := range values {
+		if name = strings.TrimSpace(name); name != "" {
+			variables[name] = value
+		}
+	}
+
+	for i := range fields {
+		field := &fields[i]
+		name := field.VariableName()
+		value, exists := variables[name]
+		if !exists || strings.TrimSpace(value) == "" {
+			if field.Required {
+				return nil, fmt.Errorf("variable %q is required", name)
+			}
+			continue
+		}
+		if field.Type == models.FormFieldTypeFile {
+			continue
+		}
+		normalized, err := service.ValidateFormInput(field, value)
+		if err != nil {
+			return nil, errors.New(name + ": " + err.Error())
+		}
+		variables[name] = normalized
+	}
+	return variables, nil
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bbb/internal/dto"
+	service "bbb/internal/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRequestSize limits the size of request bodies
+const maxRequestSize = 1 << 20
+
+type (
+	// Server exposes the processes, task executions and teams of the bot over an HTTP JSON API.
+	// It calls the same services as the bot handlers, acting as the user an API token was issued for.
+	Server struct {
+		apiTokenService service.APITokenService
+		processService  service.ProcessService
+		taskService     service.TaskService
+		teamService     service.TeamService
+		userService     service.UserService
+		formService     service.TaskFormService
+		sendLimiter     service.SendLimiter
+	}
+
+	// authenticatedHandler is an endpoint that runs on behalf of the user of the request's API token
+	authenticatedHandler func(w http.ResponseWriter, r *http.Request, userID int64)
+)
+
+// NewServer creates the HTTP API server
+func NewServer(
+	apiTokenService service.APITokenService,
+	processService service.ProcessService,
+	taskService service.TaskService,
+	teamService service.TeamService,
+	userService service.UserService,
+	formService service.TaskFormService,
+	sendLimiter service.SendLimiter,
+) *Server {
+	return &Server{
+		apiTokenService: apiTokenService,
+		processService:  processService,
+		taskService:     taskService,
+		teamService:     teamService,
+		userService:     userService,
+		formService:     formService,
+		sendLimiter:     sendLimiter,
+	}
+}
+
+// Handler returns the routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/processes", s.authenticate(s.listProcesses))
+	mux.HandleFunc("POST /api/processes/{id}/executions", s.authenticate(s.startProcessExecution))
+	mux.HandleFunc("GET /api/executions/{id}", s.authenticate(s.getProcessExecution))
+
+	mux.HandleFunc("GET /api/tasks", s.authenticate(s.listTasks))
+	mux.HandleFunc("POST /api/tasks/{id}/claim", s.authenticate(s.claimTask))
+	mux.HandleFunc("POST /api/tasks/{id}/complete", s.authenticate(s.completeTask))
+
+	mux.HandleFunc("GET /api/teams", s.authenticate(s.listTeams))
+	mux.HandleFunc("POST /api/teams", s.authenticate(s.createTeam))
+	mux.HandleFunc("GET /api/teams/{id}", s.authenticate(s.getTeam))
+	mux.HandleFunc("POST /api/teams/{id}/members", s.authenticate(s.addTeamMember))
+	mux.HandleFunc("DELETE /api/teams/{id}/members/{userID}", s.authenticate(s.removeTeamMember))
+
+	mux.HandleFunc("GET /metrics", s.authenticate(s.metrics))
+
+	return mux
+}
+
+// authenticate resolves the user of the bearer token in the Authorization header before calling the endpoint
+func (s *Server) authenticate(next authenticatedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		userID, err := s.apiTokenService.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next(w, r, userID)
+	}
+}
+
+func writeResult(w http.ResponseWriter, status int, result interface{}) {
+	writeJSON(w, status, dto.APIResponse{OK: true, Result: result})
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	writeJSON(w, status, dto.APIResponse{OK: false, Description: description, ErrorCode: status})
+}
+
+func writeJSON(w http.ResponseWriter, status int, response dto.APIResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error writing api response: %v", err)
+	}
+}
+
+// readJSON decodes the request body into v. An empty body leaves v untouched.
+func readJSON(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("invalid json body: " + err.Error())
+	}
+	return nil
+}
+
+// pathID reads a numeric ID from the path of the request
+func pathID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(r.PathValue(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bbb/internal/dto"
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// taskPageSize is the number of task executions listed per page
+const taskPageSize = 20
+
+// listTasks lists the task executions of the user, filtered by ?filter=assigned|available|completed and paged by ?page=
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request, userID int64) {
+	filter := service.UserTaskFilter(r.URL.Query().Get("filter"))
+	if filter == "" {
+		filter = service.UserTaskFilterAssigned
+	}
+	page := 0
+	if value := r.URL.Query().Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "invalid page")
+			return
+		}
+		page = parsed
+	}
+
+	taskExecutions, total, err := s.taskService.ListUserTasks(userID, filter, page, taskPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeResult(w, http.StatusOK, dto.TaskListResponse{
+		TaskExecutions: taskExecutions,
+		Total:          total,
+		Page:           page,
+		PageSize:       taskPageSize,
+	})
+}
+
+// claimTask assigns a pending task execution of one of the user's teams to the user
+func (s *Server) claimTask(w http.ResponseWriter, r *http.Request, userID int64) {
+	taskExecutionID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	taskExecution, err := s.taskService.GetTaskExecutionByID(taskExecutionID)
+	if err != nil || taskExecution == nil || taskExecution.Task == nil {
+		writeError(w, http.StatusNotFound, "task execution not found")
+		return
+	}
+	if taskExecution.Task.TeamID == nil || !s.isTeamMember(*taskExecution.Task.TeamID, userID) {
+		writeError(w, http.StatusForbidden, "only members of the task's team can claim it")
+		return
+	}
+
+	if err := s.taskService.AssignTask(taskExecutionID, userID); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.writeTaskExecution(w, taskExecutionID)
+}
+
+// completeTask answers the form of a task execution assigned to the user and completes it
+func (s *Server) completeTask(w http.ResponseWriter, r *http.Request, userID int64) {
+	taskExecutionID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	taskExecution, err := s.taskService.GetTaskExecutionByID(taskExecutionID)
+	if err != nil || taskExecution == nil {
+		writeError(w, http.StatusNotFound, "task execution not found")
+		return
+	}
+	if taskExecution.Status != models.TaskStatusAssigned || taskExecution.UserID == nil || *taskExecution.UserID != userID {
+		writeError(w, http.StatusConflict, "task is not assigned to you")
+		return
+	}
+
+	var req dto.CompleteTaskRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := s.formService.GetFields(taskExecution.TaskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting task form")
+		return
+	}
+	answers, err := formAnswers(fields, req.Answers)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.taskService.CompleteTask(taskExecutionID, userID, answers, req.Note); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.writeTaskExecution(w, taskExecutionID)
+}
+
+// writeTaskExecution responds with the current state of a task execution
+func (s *Server) writeTaskExecution(w http.ResponseWriter, taskExecutionID uint) {
+	taskExecution, err := s.taskService.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting task execution")
+		return
+	}
+	writeResult(w, http.StatusOK, taskExecution)
+}
+
+func (s *Server) isTeamMember(teamID uint, userID int64) bool {
+	members, err := s.teamService.GetTeamMembers(teamID)
+	if err != nil {
+		return false
+	}
+	for _, member := range members {
+		if member.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// formAnswers turns the answers given by variable name into answers of the form fields. Required fields are
+// checked when the form is submitted.
+func formAnswers(fields []models.TaskFormField, values map[string]string) ([]models.TaskFormAnswer, error) {
+	var answers []models.TaskFormAnswer
+	for i := range fields {
+		field := &fields[i]
+		value, exists := values[field.VariableName()]
+		if !exists || strings.TrimSpace(value) == "" {
+			continue
+		}
+		if field.Type != models.FormFieldTypeFile {
+			normalized, err := service.ValidateFormInput(field, value)
+			if err != nil {
+				return nil, errors.New(field.VariableName() + ": " + err.Error())
+			}
+			value = normalized
+		}
+		answers = append(answers, models.TaskFormAnswer{FieldID: field.ID, Field: field, Value: value})
+	}
+	return answers, nil
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bbb/internal/dto"
+	"bbb/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTeamNameLength matches the size of the name column of teams
+const maxTeamNameLength = 100
+
+// listTeams lists the teams owned by the user
+func (s *Server) listTeams(w http.ResponseWriter, r *http.Request, userID int64) {
+	teams, err := s.teamService.GetTeamsByOwnerID(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting teams")
+		return
+	}
+	writeResult(w, http.StatusOK, teams)
+}
+
+func (s *Server) createTeam(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req dto.CreateTeamRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxTeamNameLength {
+		writeError(w, http.StatusBadRequest, "name is required and must be at most 100 characters")
+		return
+	}
+
+	team := &models.Team{
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		OwnerID:     userID,
+	}
+	if err := s.teamService.CreateTeam(team); err != nil {
+		writeError(w, http.StatusInternalServerError, "error creating team")
+		return
+	}
+	writeResult(w, http.StatusCreated, dto.TeamResponse{Team: team, Members: []models.User{}})
+}
+
+func (s *Server) getTeam(w http.ResponseWriter, r *http.Request, userID int64) {
+	team, ok := s.ownedTeam(w, r, userID)
+	if !ok {
+		return
+	}
+	s.writeTeam(w, http.StatusOK, team)
+}
+
+func (s *Server) addTeamMember(w http.ResponseWriter, r *http.Request, userID int64) {
+	team, ok := s.ownedTeam(w, r, userID)
+	if !ok {
+		return
+	}
+	var req dto.TeamMemberRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.UserID == 0 {
+		writeError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	if user, err := s.userService.GetUserByID(req.UserID); err != nil || user == nil {
+		writeError(w, http.StatusNotFound, "user not found, they must start the bot first")
+		return
+	}
+
+	if err := s.teamService.AddMember(team.ID, req.UserID); err != nil {
+		writeError(w, http.StatusInternalServerError, "error adding member")
+		return
+	}
+	s.writeTeam(w, http.StatusOK, team)
+}
+
+func (s *Server) removeTeamMember(w http.ResponseWriter, r *http.Request, userID int64) {
+	team, ok := s.ownedTeam(w, r, userID)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid userID")
+		return
+	}
+
+	if err := s.teamService.RemoveMember(team.ID, memberID); err != nil {
+		writeError(w, http.StatusInternalServerError, "error removing member")
+		return
+	}
+	s.writeTeam(w, http.StatusOK, team)
+}
+
+// ownedTeam returns the team in the path of the request if the user owns it, and responds with an error otherwise
+func (s *Server) ownedTeam(w http.ResponseWriter, r *http.Request, userID int64) (*models.Team, bool) {
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	team, err := s.teamService.GetTeamByID(teamID)
+	if err != nil || team == nil {
+		writeError(w, http.StatusNotFound, "team not found")
+		return nil, false
+	}
+	if team.OwnerID != userID {
+		writeError(w, http.StatusForbidden, "only the owner of the team can manage it")
+		return nil, false
+	}
+	return team, true
+}
+
+// writeTeam responds with a team along with its current members
+func (s *Server) writeTeam(w http.ResponseWriter, status int, team *models.Team) {
+	members, err := s.teamService.GetTeamMembers(team.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error getting team members")
+		return
+	}
+	writeResult(w, status, dto.TeamResponse{Team: team, Members: members})
+}
@@ -0,0 +1,55 @@
+package dto
+
+import "bbb/internal/models"
+
+type (
+	// APIResponse wraps every response of the HTTP API, in the same shape as the messenger API responses
+	APIResponse struct {
+		OK          bool        `json:"ok"`
+		Result      interface{} `json:"result,omitempty"`
+		Description string      `json:"description,omitempty"`
+		ErrorCode   int         `json:"error_code,omitempty"`
+	}
+
+	StartProcessRequest struct {
+		Title     string            `json:"title"`
+		Variables map[string]string `json:"variables"`
+	}
+
+	// CompleteTaskRequest answers the form of a task execution, keyed by field variable names, along with an optional note
+	CompleteTaskRequest struct {
+		Answers map[string]string `json:"answers"`
+		Note    string            `json:"note"`
+	}
+
+	CreateTeamRequest struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	TeamMemberRequest struct {
+		UserID int64 `json:"user_id"`
+	}
+)
+
+type (
+	// ProcessExecutionResponse describes a process execution along with its variables and task executions
+	ProcessExecutionResponse struct {
+		Execution      *models.ProcessExecution `json:"execution"`
+		Variables      map[string]string        `json:"variables"`
+		TaskExecutions []models.TaskExecution   `json:"task_executions"`
+	}
+
+	// TaskListResponse is one page of the task executions of a user
+	TaskListResponse struct {
+		TaskExecutions []models.TaskExecution `json:"task_executions"`
+		Total          int64                  `json:"total"`
+		Page           int                    `json:"page"`
+		PageSize       int                    `json:"page_size"`
+	}
+
+	TeamResponse struct {
+		Team    *models.Team  `json:"team"`
+		Members []models.User `json:"members"`
+	}
+)
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// APITokenHandler lets users issue and revoke the tokens other systems use to call the HTTP API on their behalf.
+type APITokenHandler struct {
+	apiTokenService service.APITokenService
+	location        *time.Location
+}
+
+// NewAPITokenHandler creates a new APITokenHandler.
+func NewAPITokenHandler(apiTokenService service.APITokenService, location *time.Location) *APITokenHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &APITokenHandler{
+		apiTokenService: apiTokenService,
+		location:        location,
+	}
+}
+
+// HandleAPITokenCommands handles the /api_token command, which lists the tokens of the user.
+func (h *APITokenHandler) HandleAPITokenCommands(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || update.Message.Text != "/api_token" {
+		return
+	}
+	if !update.Message.Chat.IsPrivate() {
+		sendMessage(update.Message.Chat.ID, "برای امنیت بیشتر، توکن‌های API را فقط در گفتگوی خصوصی با ربات مدیریت کنید.")
+		return
+	}
+	h.sendTokenList(bot, update.Message.Chat.ID, update.Message.From.ID)
+}
+
+// HandleAPITokenCallback handles issuing and revoking tokens from the token list.
+func (h *APITokenHandler) HandleAPITokenCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callbackMsg := ""
+
+	switch {
+	case data == "api_token_issue":
+		token, _, err := h.apiTokenService.IssueToken(userID)
+		if err != nil {
+			log.Printf("Error issuing api token for user %d: %v", userID, err)
+			sendMessage(chatID, "خطا در ساخت توکن. لطفا دوباره تلاش کنید.")
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, fmt.Sprintf("🔑 توکن جدید شما:\n\n%s\n\nاین توکن فقط همین یک بار نمایش داده می‌شود. آن را در سرآیند Authorization به شکل «Bearer <توکن>» به API بفرستید. هر کس این توکن را داشته باشد می‌تواند به جای شما فرایندها را شروع و وظایف را انجام دهد.", token))
+		callbackMsg = "توکن ساخته شد"
+
+	case strings.HasPrefix(data, "api_token_revoke_"):
+		tokenID, err := strconv.ParseUint(strings.TrimPrefix(data, "api_token_revoke_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "شناسه توکن نامعتبر است.")
+			callbackMsg = "خطا"
+			break
+		}
+		if err := h.apiTokenService.RevokeToken(uint(tokenID), userID); err != nil {
+			sendMessage(chatID, "توکن یافت نشد یا قبلا باطل شده است.")
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, "توکن باطل شد و دیگر قابل استفاده نیست.")
+		h.sendTokenList(bot, chatID, userID)
+		callbackMsg = "توکن باطل شد"
+	}
+
+	if callbackMsg != "" {
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+		if _, err := bot.Request(callback); err != nil {
+			log.Printf("Error answering callback query: %v", err)
+		}
+	}
+}
+
+// sendTokenList shows the tokens of a user with buttons to revoke them or issue a new one
+func (h *APITokenHandler) sendTokenList(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	tokens, err := h.apiTokenService.GetUserTokens(userID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "خطا در دریافت توکن‌ها. لطفا دوباره تلاش کنید.")
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending api token error: %v", errSend)
+		}
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("🔑 توکن‌های API\nسامانه‌های دیگر با این توکن‌ها می‌توانند فرایندهای شما را شروع کنند، وضعیت اجراها را ببینند، وظایف را به عهده بگیرند و تکمیل کنند و تیم‌هایتان را مدیریت کنند.\n")
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	if len(tokens) == 0 {
+		text.WriteString("\nهنوز توکنی نساخته‌اید.")
+	}
+	for _, token := range tokens {
+		text.WriteString(fmt.Sprintf("\n- %s… ساخته شده در %s، %s", token.Prefix, formatTime(&token.CreatedAt, h.location), apiTokenUsageTitle(&token, h.location)))
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("ابطال %s…", token.Prefix), fmt.Sprintf("api_token_revoke_%d", token.ID)),
+		))
+	}
+	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ ساخت توکن جدید", "api_token_issue"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending api token list: %v", errSend)
+	}
+}
+
+func apiTokenUsageTitle(token *models.APIToken, location *time.Location) string {
+	if token.LastUsedAt == nil {
+		return "هنوز استفاده نشده"
+	}
+	return "آخرین استفاده " + formatTime(token.LastUsedAt, location)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// AvailabilityHandler handles out-of-office status commands and callbacks.
+type AvailabilityHandler struct {
+	userService                service.UserService
+	availabilityBuilderService *service.AvailabilityBuilderService
+	location                   *time.Location
+}
+
+// NewAvailabilityHandler creates a new AvailabilityHandler.
+func NewAvailabilityHandler(
+	userService service.UserService,
+	availabilityBuilderService *service.AvailabilityBuilderService,
+	location *time.Location,
+) *AvailabilityHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &AvailabilityHandler{
+		userService:                userService,
+		availabilityBuilderService: availabilityBuilderService,
+		location:                   location,
+	}
+}
+
+// HandleAvailabilityCommands handles the availability menu and the custom date input.
+func (h *AvailabilityHandler) HandleAvailabilityCommands(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil {
+		return
+	}
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if update.Message.Text == "وضعیت حضور" {
+		h.availabilityBuilderService.Cancel(userID)
+		user, err := h.userService.GetUserByID(userID)
+		if err != nil {
+			sendMessage(chatID, "خطا در دریافت وضعیت حضور. لطفا دوباره تلاش کنید.")
+			return
+		}
+
+		status := "✅ شما در دسترس هستید و وظایف جدید برایتان ارسال می‌شود."
+		if h.userService.IsAway(user) {
+			status = fmt.Sprintf("🌴 شما تا %s در مرخصی هستید.", user.AwayUntil.In(h.location).Format("2006-01-02 15:04"))
+			if user.AwayDelegateID != nil {
+				if delegate, err := h.userService.GetUserByID(*user.AwayDelegateID); err == nil {
+					status += fmt.Sprintf("\nجانشین: %s %s", delegate.FirstName, delegate.LastName)
+				}
+			}
+		}
+
+		msg := tgbotapi.NewMessage(chatID, status+"\n\nوضعیت جدید را انتخاب کنید:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("در دسترس هستم", "availability_available"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("مرخصی ۱ روزه", "availability_days_1"),
+				tgbotapi.NewInlineKeyboardButtonData("مرخصی ۳ روزه", "availability_days_3"),
+				tgbotapi.NewInlineKeyboardButtonData("مرخصی ۷ روزه", "availability_days_7"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("مرخصی تا تاریخ مشخص", "availability_custom"),
+			),
+		)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending availability menu: %v", errSend)
+		}
+		return
+	}
+
+	builder, exists := h.availabilityBuilderService.GetBuilder(userID)
+	if !exists || builder.CurrentStep != "until" || update.Message.Text == "" {
+		return
+	}
+
+	until, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(update.Message.Text), h.location)
+	if err != nil {
+		sendMessage(chatID, "تاریخ نامعتبر است. لطفا تاریخ را به شکل 2025-01-31 وارد کنید.")
+		return
+	}
+	// The user is away for the whole given day
+	h.availabilityBuilderService.SetUntil(userID, until.AddDate(0, 0, 1))
+	h.sendDelegateSelection(bot, chatID, userID)
+}
+
+// HandleAvailabilityCallback handles callback queries of the availability menu.
+func (h *AvailabilityHandler) HandleAvailabilityCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callbackMsg := ""
+
+	switch {
+	case data == "availability_available":
+		h.availabilityBuilderService.Cancel(userID)
+		if err := h.userService.SetAvailable(userID); err != nil {
+			sendMessage(chatID, "خطا در تغییر وضعیت حضور: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, "وضعیت شما به «در دسترس» تغییر کرد.")
+		callbackMsg = "در دسترس"
+
+	case strings.HasPrefix(data, "availability_days_"):
+		days, err := strconv.Atoi(strings.TrimPrefix(data, "availability_days_"))
+		if err != nil || days <= 0 {
+			sendMessage(chatID, "مدت مرخصی نامعتبر است.")
+			callbackMsg = "خطا"
+			break
+		}
+		h.availabilityBuilderService.SetUntil(userID, time.Now().AddDate(0, 0, days))
+		h.sendDelegateSelection(bot, chatID, userID)
+		callbackMsg = "انتخاب جانشین"
+
+	case data == "availability_custom":
+		h.availabilityBuilderService.StartAway(userID)
+		sendMessage(chatID, "تاریخ آخرین روز مرخصی را به شکل 2025-01-31 وارد کنید:")
+		callbackMsg = "تاریخ را وارد کنید"
+
+	case strings.HasPrefix(data, "availability_delegate_"):
+		delegateID, err := strconv.ParseInt(strings.TrimPrefix(data, "availability_delegate_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه جانشین.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		until, ok := h.availabilityBuilderService.CompleteAway(userID)
+		if !ok {
+			sendMessage(chatID, "ابتدا مدت مرخصی را از منوی «وضعیت حضور» انتخاب کنید.")
+			callbackMsg = "خطا"
+			break
+		}
+
+		var delegate *int64
+		if delegateID != 0 {
+			delegate = &delegateID
+		}
+		if err := h.userService.SetAway(userID, until, delegate); err != nil {
+			sendMessage(chatID, "خطا در ثبت مرخصی: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+
+		response := fmt.Sprintf("مرخصی شما تا %s ثبت شد.", until.In(h.location).Format("2006-01-02 15:04"))
+		if delegate == nil {
+			response += "\nدر این مدت وظایف جدید برای شما ارسال نمی‌شود."
+		} else {
+			response += "\nدر این مدت وظایف جدید برای جانشین شما ارسال می‌شود."
+		}
+		sendMessage(chatID, response)
+		callbackMsg = "مرخصی ثبت شد"
+	}
+
+	if callbackMsg != "" {
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+		if _, err := bot.Request(callback); err != nil {
+			log.Printf("Error answering callback query: %v", err)
+		}
+	}
+}
+
+func (h *AvailabilityHandler) sendDelegateSelection(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	teammates, err := h.userService.GetTeammates(userID)
+	if err != nil {
+		log.Printf("Error getting teammates of user %d: %v", userID, err)
+	}
+
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	for _, teammate := range teammates {
+		if h.userService.IsAway(&teammate) {
+			continue
+		}
+		row := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s %s", teammate.FirstName, teammate.LastName),
+				fmt.Sprintf("availability_delegate_%d", teammate.ID),
+			),
+		}
+		keyboardRows = append(keyboardRows, row)
+	}
+	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("بدون جانشین", "availability_delegate_0")))
+
+	msg := tgbotapi.NewMessage(chatID, "در مدت مرخصی، وظایف شما به چه کسی سپرده شود؟")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending delegate selection: %v", errSend)
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// CommentHandler handles the discussion threads of task executions and process executions.
+type CommentHandler struct {
+	commentService  service.CommentService
+	commentSessions *service.CommentSessionService
+	taskService     service.TaskService
+	location        *time.Location
+}
+
+// NewCommentHandler creates a new CommentHandler.
+func NewCommentHandler(
+	commentService service.CommentService,
+	commentSessions *service.CommentSessionService,
+	taskService service.TaskService,
+	location *time.Location,
+) *CommentHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &CommentHandler{
+		commentService:  commentService,
+		commentSessions: commentSessions,
+		taskService:     taskService,
+		location:        location,
+	}
+}
+
+// HandleCommentMessage posts comments written after pressing "نظر", or sent as a reply to a task notification.
+// Comment sessions are started from the private chat, so in groups only replies to task announcements are comments.
+func (h *CommentHandler) HandleCommentMessage(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || update.Message.Text == "" || update.Message.From == nil {
+		return
+	}
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	var target service.CommentTarget
+	exists := false
+	if update.Message.Chat.IsPrivate() {
+		target, exists = h.commentSessions.GetSession(userID)
+	}
+	if exists {
+		h.commentSessions.EndSession(userID)
+	} else {
+		reply := update.Message.ReplyToMessage
+		if reply == nil {
+			return
+		}
+		taskExecutionID, ok := h.commentService.GetNotifiedTaskExecutionID(chatID, reply.MessageID)
+		if !ok {
+			return
+		}
+		target = service.CommentTarget{TaskExecutionID: taskExecutionID}
+	}
+
+	var err error
+	if target.TaskExecutionID != 0 {
+		_, err = h.commentService.AddTaskComment(target.TaskExecutionID, userID, update.Message.Text)
+	} else {
+		_, err = h.commentService.AddProcessExecutionComment(target.ProcessExecutionID, userID, update.Message.Text)
+	}
+	if err != nil {
+		sendMessage(chatID, "خطا در ثبت نظر: "+err.Error())
+		return
+	}
+	sendMessage(chatID, "نظر شما ثبت شد و برای دیگر افراد مرتبط ارسال شد.")
+}
+
+// HandleCommentCallback handles callback queries of discussion threads.
+func (h *CommentHandler) HandleCommentCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callbackMsg := ""
+
+	switch {
+	case strings.HasPrefix(data, "comment_task_"), strings.HasPrefix(data, "comment_execution_"):
+		target, err := parseCommentTarget(strings.TrimPrefix(data, "comment_"))
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		h.commentSessions.StartSession(userID, target)
+		msg := tgbotapi.NewMessage(chatID, "لطفا نظر خود را بنویسید:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("لغو", "comment_cancel"),
+			),
+		)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending comment request: %v", errSend)
+		}
+		callbackMsg = "نوشتن نظر"
+
+	case data == "comment_cancel":
+		h.commentSessions.EndSession(userID)
+		sendMessage(chatID, "نوشتن نظر لغو شد.")
+		callbackMsg = "لغو شد"
+
+	case strings.HasPrefix(data, "comments_task_"), strings.HasPrefix(data, "comments_execution_"):
+		target, err := parseCommentTarget(strings.TrimPrefix(data, "comments_"))
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		if err := h.sendThread(bot, chatID, userID, target); err != nil {
+			sendMessage(chatID, "خطا در دریافت گفتگو: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		callbackMsg = "گفتگو"
+	}
+
+	if callbackMsg != "" {
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+		bot.Request(callback)
+	}
+}
+
+// sendThread sends the comments of a task execution or a process execution
+func (h *CommentHandler) sendThread(bot *tgbotapi.BotAPI, chatID int64, userID int64, target service.CommentTarget) error {
+	var comments []models.Comment
+	var err error
+	var commentData string
+	titles := make(map[uint]string)
+	if target.TaskExecutionID != 0 {
+		comments, err = h.commentService.GetTaskComments(target.TaskExecutionID, userID)
+		commentData = fmt.Sprintf("comment_task_%d", target.TaskExecutionID)
+	} else {
+		comments, err = h.commentService.GetProcessExecutionComments(target.ProcessExecutionID, userID)
+		commentData = fmt.Sprintf("comment_execution_%d", target.ProcessExecutionID)
+		if taskExecs, errTasks := h.taskService.GetTaskExecutionsByProcessExecutionID(target.ProcessExecutionID); errTasks == nil {
+			for _, taskExec := range taskExecs {
+				if taskExec.Task != nil {
+					titles[taskExec.ID] = taskExec.Task.Title
+				}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	if len(comments) == 0 {
+		text.WriteString("هنوز نظری ثبت نشده است.")
+	} else {
+		text.WriteString("💬 گفتگو:\n")
+		for _, comment := range comments {
+			author := fmt.Sprintf("%d", comment.UserID)
+			if comment.User != nil {
+				author = strings.TrimSpace(comment.User.FirstName + " " + comment.User.LastName)
+			}
+			text.WriteString(fmt.Sprintf("\n[%s] %s", formatTime(&comment.CreatedAt, h.location), author))
+			if comment.TaskExecutionID != nil && titles[*comment.TaskExecutionID] != "" {
+				text.WriteString(fmt.Sprintf(" (وظیفه «%s»)", titles[*comment.TaskExecutionID]))
+			}
+			text.WriteString(":\n" + comment.Text + "\n")
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💬 نظر", commentData),
+		),
+	)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending comment thread: %v", errSend)
+	}
+	return nil
+}
+
+// parseCommentTarget parses payloads in the form "task_<taskExecutionID>" or "execution_<processExecutionID>"
+func parseCommentTarget(payload string) (service.CommentTarget, error) {
+	var target service.CommentTarget
+	if strings.HasPrefix(payload, "task_") {
+		id, err := strconv.ParseUint(strings.TrimPrefix(payload, "task_"), 10, 64)
+		if err != nil {
+			return target, err
+		}
+		target.TaskExecutionID = uint(id)
+		return target, nil
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(payload, "execution_"), 10, 64)
+	if err != nil {
+		return target, err
+	}
+	target.ProcessExecutionID = uint(id)
+	return target, nil
+}
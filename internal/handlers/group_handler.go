@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"bbb/internal/dto"
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// groupTaskListSize is the number of open tasks listed by /team in a group
+const groupTaskListSize = 15
+
+// GroupHandler links teams to group chats, keeps the team members in step with the group and answers team commands
+// sent in the group.
+type GroupHandler struct {
+	teamService service.TeamService
+	userService service.UserService
+	taskService service.TaskService
+}
+
+// NewGroupHandler creates a new GroupHandler.
+func NewGroupHandler(teamService service.TeamService, userService service.UserService, taskService service.TaskService) *GroupHandler {
+	return &GroupHandler{
+		teamService: teamService,
+		userService: userService,
+		taskService: taskService,
+	}
+}
+
+// HandleGroupMessages handles messages sent in group chats: members joining and leaving, and the group team commands.
+// Anyone who writes in the group of a team becomes a member of the team.
+func (h *GroupHandler) HandleGroupMessages(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || !isGroupChat(update.Message.Chat) {
+		return
+	}
+	message := update.Message
+	chatID := message.Chat.ID
+	team, err := h.teamService.GetTeamByGroupChatID(chatID)
+	if err != nil {
+		team = nil
+	}
+
+	for _, member := range message.NewChatMembers {
+		if member.ID == bot.Self.ID {
+			h.reply(bot, chatID, "سلام! 👋\nبرای اینکه اعضای این گروه عضو یک تیم شوند و وظایف باز تیم در همین گروه اعلام شود، یکی از مدیران گروه /link_team را بفرستد.", nil)
+			continue
+		}
+		if team != nil && !member.IsBot {
+			h.addMember(team, &member)
+		}
+	}
+	if left := message.LeftChatMember; left != nil && team != nil {
+		switch {
+		case left.ID == bot.Self.ID:
+			if err := h.teamService.UnlinkGroup(team); err != nil {
+				log.Printf("Error unlinking team %d from group %d: %v", team.ID, chatID, err)
+			}
+			return
+		case !left.IsBot:
+			if err := h.teamService.RemoveGroupMember(team, left.ID); err != nil {
+				log.Printf("Error removing user %d from team %d: %v", left.ID, team.ID, err)
+			}
+		}
+	}
+	// A group upgraded to a supergroup gets a new chat ID, so the team follows it
+	if message.MigrateToChatID != 0 && team != nil {
+		if err := h.teamService.MoveGroup(team, message.MigrateToChatID); err != nil {
+			log.Printf("Error moving team %d to supergroup %d: %v", team.ID, message.MigrateToChatID, err)
+		}
+		return
+	}
+	if message.From == nil || message.From.IsBot {
+		return
+	}
+	userID := message.From.ID
+	if team != nil {
+		if _, err := h.teamService.AddGroupMember(team, userID); err != nil {
+			log.Printf("Error adding user %d to team %d: %v", userID, team.ID, err)
+		}
+	}
+
+	switch message.Command() {
+	case "link_team":
+		if !h.teamService.IsGroupAdmin(chatID, userID) {
+			h.reply(bot, chatID, "فقط مدیران گروه می‌توانند گروه را به تیم متصل کنند.", nil)
+			return
+		}
+		h.sendLinkMenu(bot, chatID, userID, team)
+
+	case "unlink_team":
+		if team == nil {
+			h.reply(bot, chatID, "این گروه به هیچ تیمی متصل نیست.", nil)
+			return
+		}
+		if !h.teamService.IsManager(team, userID) {
+			h.reply(bot, chatID, "فقط مالک تیم یا مدیران گروه می‌توانند اتصال را بردارند.", nil)
+			return
+		}
+		if err := h.teamService.UnlinkGroup(team); err != nil {
+			log.Printf("Error unlinking team %d from group %d: %v", team.ID, chatID, err)
+			h.reply(bot, chatID, "خطا در برداشتن اتصال گروه. لطفا دوباره تلاش کنید.", nil)
+			return
+		}
+		h.reply(bot, chatID, fmt.Sprintf("اتصال این گروه به تیم «%s» برداشته شد. اعضای تیم تغییری نکردند و اعلان‌های وظایف دوباره برای هر عضو جداگانه فرستاده می‌شود.", team.Name), nil)
+
+	case "team":
+		if team == nil {
+			h.reply(bot, chatID, "این گروه به هیچ تیمی متصل نیست. مدیران گروه می‌توانند با /link_team آن را به یک تیم متصل کنند.", nil)
+			return
+		}
+		h.sendTeamSummary(bot, chatID, userID, team)
+
+	case "sync_team":
+		if team == nil {
+			h.reply(bot, chatID, "این گروه به هیچ تیمی متصل نیست.", nil)
+			return
+		}
+		if !h.teamService.IsManager(team, userID) {
+			h.reply(bot, chatID, "فقط مالک تیم یا مدیران گروه می‌توانند اعضا را همگام کنند.", nil)
+			return
+		}
+		h.reply(bot, chatID, h.syncAdmins(team), nil)
+	}
+}
+
+// HandleGroupCallback handles linking a group to a team and syncing its members.
+func (h *GroupHandler) HandleGroupCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil || !strings.HasPrefix(update.CallbackQuery.Data, "group_") {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	chat := update.CallbackQuery.Message.Chat
+	chatID := chat.ID
+	var callbackMsg string
+
+	switch {
+	case !isGroupChat(chat):
+		callbackMsg = "این دکمه فقط در گروه کار می‌کند"
+
+	case strings.HasPrefix(data, "group_link_"):
+		if !h.teamService.IsGroupAdmin(chatID, userID) {
+			callbackMsg = "فقط مدیران گروه می‌توانند گروه را به تیم متصل کنند"
+			break
+		}
+		var team *models.Team
+		var err error
+		if choice := strings.TrimPrefix(data, "group_link_"); choice == "new" {
+			team, err = h.teamService.CreateGroupTeam(userID, chatID, chat.Title)
+		} else if teamID, parseErr := strconv.ParseUint(choice, 10, 64); parseErr != nil {
+			err = parseErr
+		} else {
+			team, err = h.teamService.LinkGroup(uint(teamID), userID, chatID, chat.Title)
+		}
+		if err != nil {
+			h.reply(bot, chatID, "خطا در اتصال گروه به تیم: "+err.Error(), nil)
+			callbackMsg = "خطا در اتصال"
+			break
+		}
+		h.reply(bot, chatID, fmt.Sprintf("✅ این گروه به تیم «%s» متصل شد.\n\n• وظایف باز تیم از این پس یک بار در همین گروه اعلام می‌شوند و هر عضو می‌تواند با دکمه‌ی زیر پیام آن‌ها را به عهده بگیرد.\n• اعضایی که به گروه بپیوندند، در گروه پیام بفرستند یا وظیفه‌ای را به عهده بگیرند عضو تیم می‌شوند و با ترک گروه از تیم خارج می‌شوند.\n\n%s",
+			team.Name, h.syncAdmins(team)), nil)
+		callbackMsg = "گروه متصل شد"
+
+	case data == "group_sync":
+		team, err := h.teamService.GetTeamByGroupChatID(chatID)
+		if err != nil {
+			callbackMsg = "این گروه به هیچ تیمی متصل نیست"
+			break
+		}
+		if !h.teamService.IsManager(team, userID) {
+			callbackMsg = "فقط مالک تیم یا مدیران گروه می‌توانند اعضا را همگام کنند"
+			break
+		}
+		h.reply(bot, chatID, h.syncAdmins(team), nil)
+		callbackMsg = "اعضا همگام شدند"
+	}
+
+	callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+	if _, err := bot.Request(callback); err != nil {
+		log.Printf("Error answering callback query: %v", err)
+	}
+}
+
+// sendLinkMenu offers the teams the admin owns, and a new team named after the group, to link the group to
+func (h *GroupHandler) sendLinkMenu(bot *tgbotapi.BotAPI, chatID int64, userID int64, linked *models.Team) {
+	if linked != nil {
+		h.reply(bot, chatID, fmt.Sprintf("این گروه اکنون به تیم «%s» متصل است. برای متصل کردن آن به تیم دیگری، ابتدا /unlink_team را بفرستید.", linked.Name), nil)
+		return
+	}
+	teams, err := h.teamService.GetTeamsByOwnerID(userID)
+	if err != nil {
+		h.reply(bot, chatID, "خطا در دریافت لیست تیم‌ها. لطفا دوباره تلاش کنید.", nil)
+		return
+	}
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	for _, team := range teams {
+		label := team.Name
+		if team.GroupChatID != nil {
+			label += " (متصل به گروه دیگر)"
+		}
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("group_link_%d", team.ID)),
+		))
+	}
+	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ تیم جدید با نام این گروه", "group_link_new"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	h.reply(bot, chatID, "این گروه به کدام تیم متصل شود؟ فقط تیم‌هایی که مالک آن‌ها هستید نمایش داده می‌شوند.", &keyboard)
+}
+
+// sendTeamSummary shows the team of the group with its members and open tasks
+func (h *GroupHandler) sendTeamSummary(bot *tgbotapi.BotAPI, chatID int64, userID int64, team *models.Team) {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("👥 تیم «%s»\n", team.Name))
+	if members, err := h.teamService.GetTeamMembers(team.ID); err == nil {
+		text.WriteString(fmt.Sprintf("تعداد اعضا: %d\n", len(members)))
+	}
+
+	taskExecs, err := h.taskService.GetOpenTeamTasks(team.ID)
+	switch {
+	case err != nil:
+		log.Printf("Error getting open tasks of team %d: %v", team.ID, err)
+		text.WriteString("\nخطا در دریافت وظایف باز تیم.")
+	case len(taskExecs) == 0:
+		text.WriteString("\nتیم وظیفه‌ی بازی ندارد.")
+	default:
+		text.WriteString(fmt.Sprintf("\nوظایف باز (%d):\n", len(taskExecs)))
+		for i, taskExec := range taskExecs {
+			if i == groupTaskListSize {
+				text.WriteString(fmt.Sprintf("… و %d وظیفه‌ی دیگر\n", len(taskExecs)-groupTaskListSize))
+				break
+			}
+			assignee := "⏳ در انتظار"
+			if taskExec.User != nil {
+				assignee = fmt.Sprintf("%s %s", taskExec.User.FirstName, taskExec.User.LastName)
+			}
+			text.WriteString(fmt.Sprintf("• %s %s - %s\n", taskExec.Task.Title, processExecutionName(&taskExec), assignee))
+		}
+	}
+
+	if !h.teamService.IsManager(team, userID) {
+		h.reply(bot, chatID, text.String(), nil)
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("وظایف باز تیم", fmt.Sprintf("team_tasks_%d", team.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("همگام‌سازی اعضا", "group_sync"),
+		),
+	)
+	h.reply(bot, chatID, text.String(), &keyboard)
+}
+
+// syncAdmins adds the group administrators to the team. The Bot API only lists the administrators of a group,
+// so the other members join the team as they show up in the group.
+func (h *GroupHandler) syncAdmins(team *models.Team) string {
+	admins, err := h.teamService.GetGroupAdmins(*team.GroupChatID)
+	if err != nil {
+		log.Printf("Error getting administrators of group %d: %v", *team.GroupChatID, err)
+		return "خطا در دریافت مدیران گروه. مطمئن شوید ربات عضو گروه است."
+	}
+	added := 0
+	for i := range admins {
+		if h.addMember(team, &admins[i]) {
+			added++
+		}
+	}
+	return fmt.Sprintf("%d نفر از مدیران گروه به تیم اضافه شدند. سایر اعضای گروه با فرستادن پیام در گروه یا به عهده گرفتن یک وظیفه عضو تیم می‌شوند.", added)
+}
+
+// addMember saves a user seen in the group and adds them to its team, reporting whether they were added
+func (h *GroupHandler) addMember(team *models.Team, user *tgbotapi.User) bool {
+	if err := h.userService.SaveOrUpdateUser(dto.Message{
+		From: dto.User{
+			ID:         user.ID,
+			First_name: user.FirstName,
+			Last_name:  user.LastName,
+			Username:   user.UserName,
+		},
+	}); err != nil {
+		log.Printf("Error saving user %d: %v", user.ID, err)
+		return false
+	}
+	added, err := h.teamService.AddGroupMember(team, user.ID)
+	if err != nil {
+		log.Printf("Error adding user %d to team %d: %v", user.ID, team.ID, err)
+		return false
+	}
+	return added
+}
+
+// reply sends a message to the group without the main keyboard, which is meant for private chats
+func (h *GroupHandler) reply(bot *tgbotapi.BotAPI, chatID int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	if _, err := bot.Send(msg); err != nil {
+		log.Printf("Error sending group message: %v", err)
+	}
+}
+
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// inlineCacheSeconds is how long clients may reuse an answer; tasks are claimed and completed all the time, so a card
+// must not outlive the state it shows by much
+const inlineCacheSeconds = 5
+
+// InlineHandler answers inline queries (@bot query) with cards of the user's processes, open tasks and teams, which
+// can be shared in any chat, and handles the buttons of those cards.
+type InlineHandler struct {
+	searchService     service.SearchService
+	processService    service.ProcessService
+	taskService       service.TaskService
+	teamService       service.TeamService
+	formService       service.TaskFormService
+	formFillService   *service.FormFillService
+	attachmentService service.AttachmentService
+	location          *time.Location
+}
+
+// NewInlineHandler creates a new InlineHandler.
+func NewInlineHandler(
+	searchService service.SearchService,
+	processService service.ProcessService,
+	taskService service.TaskService,
+	teamService service.TeamService,
+	formService service.TaskFormService,
+	formFillService *service.FormFillService,
+	attachmentService service.AttachmentService,
+	location *time.Location,
+) *InlineHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &InlineHandler{
+		searchService:     searchService,
+		processService:    processService,
+		taskService:       taskService,
+		teamService:       teamService,
+		formService:       formService,
+		formFillService:   formFillService,
+		attachmentService: attachmentService,
+		location:          location,
+	}
+}
+
+// HandleInlineQuery searches what the user may see and answers with a card for every match.
+func (h *InlineHandler) HandleInlineQuery(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	if update.InlineQuery == nil {
+		return
+	}
+	userID := update.InlineQuery.From.ID
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: update.InlineQuery.ID,
+		IsPersonal:    true,
+		CacheTime:     inlineCacheSeconds,
+		Results:       []interface{}{},
+	}
+	results, err := h.searchService.Search(userID, update.InlineQuery.Query)
+	if err != nil {
+		log.Printf("Error searching for user %d: %v", userID, err)
+	} else {
+		for _, process := range results.Processes {
+			answer.Results = append(answer.Results, h.processResult(&process))
+		}
+		for _, taskExec := range results.TaskExecutions {
+			answer.Results = append(answer.Results, h.taskResult(&taskExec))
+		}
+		for _, team := range results.Teams {
+			answer.Results = append(answer.Results, h.teamResult(&team, userID))
+		}
+	}
+	if len(answer.Results) == 0 {
+		answer.SwitchPMText = "چیزی یافت نشد؛ رفتن به ربات"
+		answer.SwitchPMParameter = "inline"
+	}
+	if _, err := bot.Request(answer); err != nil {
+		log.Printf("Error answering inline query: %v", err)
+	}
+}
+
+// HandleInlineCallback handles the buttons of shared cards. Such messages belong to no chat of the bot, so the
+// follow-up is sent to the private chat of whoever pressed the button.
+func (h *InlineHandler) HandleInlineCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil || update.CallbackQuery.InlineMessageID == "" {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	inlineMessageID := update.CallbackQuery.InlineMessageID
+	var callbackMsg string
+	alert := true
+
+	switch {
+	case strings.HasPrefix(data, "inline_start_process_"):
+		processID, err := strconv.ParseUint(strings.TrimPrefix(data, "inline_start_process_"), 10, 64)
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		process, err := h.processService.GetProcessByID(uint(processID))
+		if err != nil || process == nil {
+			callbackMsg = "فرایند یافت نشد"
+			break
+		}
+		if process.UserID != userID {
+			callbackMsg = "فقط مالک فرایند می‌تواند آن را شروع کند"
+			break
+		}
+		fields, err := h.formService.GetStartFields(process.ID)
+		if err != nil {
+			log.Printf("Error getting start form of process %d: %v", process.ID, err)
+			callbackMsg = "خطا در دریافت فرم شروع فرایند"
+			break
+		}
+		// The process is started by TaskHandler once the title and the start form are filled in the private chat
+		h.formFillService.StartProcessForm(userID, process.ID, fields)
+		if err := askExecutionTitle(bot, userID); err != nil {
+			log.Printf("Error sending execution title question to user %d: %v", userID, err)
+			callbackMsg = "ابتدا ربات را در گفتگوی خصوصی شروع کنید"
+			break
+		}
+		callbackMsg = fmt.Sprintf("شروع فرایند «%s» در گفتگوی خصوصی با ربات ادامه پیدا می‌کند", process.Name)
+		alert = false
+
+	case strings.HasPrefix(data, "inline_claim_task_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "inline_claim_task_"), 10, 64)
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(uint(taskExecutionID))
+		if err != nil || taskExec.Task == nil || taskExec.Task.TeamID == nil {
+			callbackMsg = "وظیفه یافت نشد"
+			break
+		}
+		if member, err := h.teamService.IsMember(*taskExec.Task.TeamID, userID); err != nil || !member {
+			callbackMsg = "فقط اعضای تیم این وظیفه می‌توانند آن را به عهده بگیرند"
+			break
+		}
+		if err := h.taskService.AssignTask(taskExec.ID, userID); err != nil {
+			callbackMsg = "خطا در به عهده گرفتن وظیفه: " + err.Error()
+			break
+		}
+		sendAssignedTask(bot, userID, taskExec.ID, "وظیفه با موفقیت به شما اختصاص داده شد.")
+		sendEarlierAttachments(bot, h.taskService, h.attachmentService, userID, taskExec.ID)
+		if claimed, err := h.taskService.GetTaskExecutionByID(taskExec.ID); err == nil {
+			text, keyboard := h.taskCard(claimed)
+			h.editCard(bot, inlineMessageID, text, keyboard)
+		}
+		callbackMsg = "وظیفه به شما تخصیص داده شد؛ ادامه‌ی کار در گفتگوی خصوصی با ربات است"
+		alert = false
+
+	case strings.HasPrefix(data, "inline_join_team_"):
+		if err := h.teamService.JoinTeam(userID, strings.TrimPrefix(data, "inline_join_team_")); err != nil {
+			callbackMsg = "خطا در پیوستن به تیم؛ ممکن است قبلا عضو شده باشید"
+			break
+		}
+		callbackMsg = "با موفقیت به تیم پیوستید!"
+		alert = false
+
+	default:
+		return
+	}
+
+	callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+	callback.ShowAlert = alert
+	if _, err := bot.Request(callback); err != nil {
+		log.Printf("Error answering callback query: %v", err)
+	}
+}
+
+func (h *InlineHandler) processResult(process *models.Process) tgbotapi.InlineQueryResultArticle {
+	text := fmt.Sprintf("🧩 فرایند «%s»", process.Name)
+	if process.Description != "" {
+		text += "\n\n" + process.Description
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ شروع این فرایند", fmt.Sprintf("inline_start_process_%d", process.ID)),
+		),
+	)
+	result := tgbotapi.NewInlineQueryResultArticle(fmt.Sprintf("process_%d", process.ID), "🧩 "+process.Name, text)
+	result.Description = shortText(process.Description, 100)
+	result.ReplyMarkup = &keyboard
+	return result
+}
+
+func (h *InlineHandler) taskResult(taskExec *models.TaskExecution) tgbotapi.InlineQueryResultArticle {
+	text, keyboard := h.taskCard(taskExec)
+	result := tgbotapi.NewInlineQueryResultArticle(fmt.Sprintf("task_%d", taskExec.ID), "📌 "+h.taskTitle(taskExec), text)
+	result.Description = fmt.Sprintf("%s — %s — %s", taskExec.Task.Process.Name, processExecutionName(taskExec), taskStatusTitle(taskExec.Status))
+	result.ReplyMarkup = keyboard
+	return result
+}
+
+func (h *InlineHandler) teamResult(team *models.Team, userID int64) tgbotapi.InlineQueryResultArticle {
+	text := fmt.Sprintf("👥 تیم «%s»", team.Name)
+	if team.Description != "" {
+		text += "\n\n" + team.Description
+	}
+	result := tgbotapi.NewInlineQueryResultArticle(fmt.Sprintf("team_%d", team.ID), "👥 "+team.Name, text)
+	result.Description = shortText(team.Description, 100)
+	// Only the owner hands out the join key, so only their cards let others join
+	if team.OwnerID == userID {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("➕ پیوستن به این تیم", "inline_join_team_"+team.JoinKey),
+			),
+		)
+		result.ReplyMarkup = &keyboard
+		result.Description = "مالک تیم"
+	}
+	return result
+}
+
+// taskCard describes an open task execution, with a button to claim it while it waits for someone
+func (h *InlineHandler) taskCard(taskExec *models.TaskExecution) (string, *tgbotapi.InlineKeyboardMarkup) {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("📌 وظیفه «%s»\n", h.taskTitle(taskExec)))
+	text.WriteString(fmt.Sprintf("فرایند: %s — %s\n", taskExec.Task.Process.Name, processExecutionName(taskExec)))
+	text.WriteString(fmt.Sprintf("وضعیت: %s\n", taskStatusTitle(taskExec.Status)))
+	if taskExec.User != nil {
+		text.WriteString(fmt.Sprintf("انجام دهنده: %s %s\n", taskExec.User.FirstName, taskExec.User.LastName))
+	}
+	if taskExec.DueAt != nil {
+		text.WriteString(fmt.Sprintf("مهلت: %s\n", formatTime(taskExec.DueAt, h.location)))
+	}
+	if taskExec.Status != models.TaskStatusPending {
+		return text.String(), nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("به عهده گرفتن وظیفه", fmt.Sprintf("inline_claim_task_%d", taskExec.ID)),
+		),
+	)
+	return text.String(), &keyboard
+}
+
+func (h *InlineHandler) taskTitle(taskExec *models.TaskExecution) string {
+	variables, err := h.processService.GetVariables(taskExec.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting variables of process execution %d: %v", taskExec.ProcessExecutionID, err)
+	}
+	title := service.RenderVariables(taskExec.Task.Title, variables)
+	if taskExec.InstanceItem != "" {
+		title += " — " + taskExec.InstanceItem
+	}
+	return title
+}
+
+// editCard replaces a shared card after its state changed
+func (h *InlineHandler) editCard(bot *tgbotapi.BotAPI, inlineMessageID string, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.EditMessageTextConfig{
+		BaseEdit: tgbotapi.BaseEdit{InlineMessageID: inlineMessageID, ReplyMarkup: keyboard},
+		Text:     text,
+	}
+	if _, err := bot.Send(edit); err != nil {
+		log.Printf("Error editing inline message %s: %v", inlineMessageID, err)
+	}
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const myTasksPageSize = 5
+
+var myTasksTabs = []struct {
+	filter service.UserTaskFilter
+	title  string
+}{
+	{service.UserTaskFilterAssigned, "📌 به عهده‌ی من"},
+	{service.UserTaskFilterAvailable, "📥 قابل برداشت"},
+	{service.UserTaskFilterCompleted, "✅ تکمیل‌شده"},
+}
+
+// HandleMyTasks handles the "وظایف من" command.
+func (h *TaskHandler) HandleMyTasks(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || update.Message.Text != "وظایف من" {
+		return
+	}
+
+	text, keyboard, err := h.renderMyTasks(update.Message.From.ID, service.UserTaskFilterAssigned, 0)
+	if err != nil {
+		log.Printf("Error listing tasks of user %d: %v", update.Message.From.ID, err)
+		sendMessage(update.Message.Chat.ID, "خطا در دریافت وظایف. لطفا دوباره تلاش کنید.")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ReplyMarkup = keyboard
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending my tasks: %v", errSend)
+	}
+}
+
+// handleMyTasksPage switches tab or page of an existing "وظایف من" message.
+// The callback payload has the form "<filter>_<page>".
+func (h *TaskHandler) handleMyTasksPage(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, payload string) error {
+	separator := strings.LastIndex(payload, "_")
+	if separator == -1 {
+		return fmt.Errorf("invalid payload: %s", payload)
+	}
+	page, err := strconv.Atoi(payload[separator+1:])
+	if err != nil || page < 0 {
+		return fmt.Errorf("invalid page: %s", payload)
+	}
+	filter := service.UserTaskFilter(payload[:separator])
+
+	text, keyboard, err := h.renderMyTasks(callbackQuery.From.ID, filter, page)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, text, keyboard)
+	if _, errSend := bot.Send(edit); errSend != nil {
+		log.Printf("Error editing my tasks: %v", errSend)
+	}
+	return nil
+}
+
+func (h *TaskHandler) renderMyTasks(userID int64, filter service.UserTaskFilter, page int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	taskExecs, total, err := h.taskService.ListUserTasks(userID, filter, page, myTasksPageSize)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	var tabRow []tgbotapi.InlineKeyboardButton
+	var text strings.Builder
+	for _, tab := range myTasksTabs {
+		title := tab.title
+		if tab.filter == filter {
+			title = "• " + title
+			text.WriteString(tab.title + "\n\n")
+		}
+		tabRow = append(tabRow, tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("my_tasks_%s_0", tab.filter)))
+	}
+	keyboardRows = append(keyboardRows, tabRow)
+
+	if total == 0 {
+		text.WriteString("وظیفه‌ای در این بخش وجود ندارد.")
+	}
+
+	now := time.Now()
+	for i, taskExec := range taskExecs {
+		number := page*myTasksPageSize + i + 1
+		text.WriteString(fmt.Sprintf("%d. %s\n   فرایند: %s — %s\n   %s\n\n",
+			number,
+			taskExecutionTitle(&taskExec),
+			taskExec.Task.Process.Name,
+			processExecutionName(&taskExec),
+			h.taskTimingSummary(&taskExec, now)))
+
+		details := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("ℹ️ %d", number), fmt.Sprintf("task_details_%d", taskExec.ID))
+		switch filter {
+		case service.UserTaskFilterAssigned:
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✔️ تکمیل %d", number), fmt.Sprintf("complete_task_%d", taskExec.ID)),
+				details,
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("↩️ بازگرداندن %d", number), fmt.Sprintf("release_task_%d", taskExec.ID)),
+			))
+		case service.UserTaskFilterAvailable:
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🙋 برداشتن %d", number), fmt.Sprintf("take_task_%d", taskExec.ID)),
+				details,
+			))
+		default:
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(details))
+		}
+	}
+
+	pages := int((total + myTasksPageSize - 1) / myTasksPageSize)
+	if pages > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("« قبلی", fmt.Sprintf("my_tasks_%s_%d", filter, page-1)))
+		}
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("صفحه %d از %d", page+1, pages), fmt.Sprintf("my_tasks_%s_%d", filter, page)))
+		if page < pages-1 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("بعدی »", fmt.Sprintf("my_tasks_%s_%d", filter, page+1)))
+		}
+		keyboardRows = append(keyboardRows, navRow)
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(keyboardRows...), nil
+}
+
+// sendTaskExecutionDetails sends the full state of a task execution with its assignment history.
+func (h *TaskHandler) sendTaskExecutionDetails(bot *tgbotapi.BotAPI, chatID int64, userID int64, taskExecutionID uint) error {
+	taskExec, err := h.taskService.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		return err
+	}
+
+	variables, err := h.processService.GetVariables(taskExec.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting variables of process execution %d: %v", taskExec.ProcessExecutionID, err)
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("عنوان: %s\nتوضیحات: %s\n",
+		service.RenderVariables(taskExec.Task.Title, variables),
+		service.RenderVariables(taskExec.Task.Description, variables)))
+	if taskExec.InstanceItem != "" {
+		text.WriteString(fmt.Sprintf("مورد: %s\n", taskExec.InstanceItem))
+	}
+	text.WriteString(fmt.Sprintf("فرایند: %s — %s\n", taskExec.Task.Process.Name, processExecutionName(taskExec)))
+	text.WriteString(fmt.Sprintf("وضعیت: %s\n", taskStatusTitle(taskExec.Status)))
+	if taskExec.User != nil {
+		text.WriteString(fmt.Sprintf("انجام دهنده: %s %s\n", taskExec.User.FirstName, taskExec.User.LastName))
+	}
+	text.WriteString(fmt.Sprintf("فعال شده: %s\n", formatTime(&taskExec.CreatedAt, h.location)))
+	if taskExec.AssignedAt != nil {
+		text.WriteString(fmt.Sprintf("به عهده گرفته شده: %s\n", formatTime(taskExec.AssignedAt, h.location)))
+	}
+	if taskExec.CompletedAt != nil {
+		text.WriteString(fmt.Sprintf("تکمیل شده: %s\n", formatTime(taskExec.CompletedAt, h.location)))
+	}
+	text.WriteString(h.taskTimingSummary(taskExec, time.Now()) + "\n")
+	if taskExec.UserDescription != "" {
+		text.WriteString(fmt.Sprintf("توضیح انجام دهنده: %s\n", taskExec.UserDescription))
+	}
+
+	answers, err := h.formService.GetAnswers(taskExecutionID)
+	if err == nil && len(answers) > 0 {
+		text.WriteString("\nاطلاعات ثبت شده:" + formatFormAnswers(answers) + "\n")
+	}
+	attachments, err := h.attachmentService.GetAttachments(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting attachments of task execution %d: %v", taskExecutionID, err)
+	} else if len(attachments) > 0 {
+		text.WriteString(fmt.Sprintf("\n📎 %d پیوست\n", len(attachments)))
+	}
+
+	if logs, err := h.taskService.GetAssignmentLogs(taskExecutionID); err == nil && len(logs) > 0 {
+		text.WriteString("\nتاریخچه تخصیص:\n")
+		for _, entry := range logs {
+			text.WriteString(fmt.Sprintf("- %s: %s\n", formatTime(&entry.CreatedAt, h.location), assignmentActionTitle(entry.Action)))
+		}
+	}
+
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	if taskExec.Status == models.TaskStatusAssigned && taskExec.UserID != nil && *taskExec.UserID == userID {
+		keyboardRows = append(keyboardRows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("تکمیل وظیفه", fmt.Sprintf("complete_task_%d", taskExec.ID)),
+				tgbotapi.NewInlineKeyboardButtonData("بازگرداندن به تیم", fmt.Sprintf("release_task_%d", taskExec.ID)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📎 افزودن پیوست", fmt.Sprintf("attach_task_%d", taskExec.ID)),
+			),
+		)
+	} else if taskExec.Status == models.TaskStatusPending {
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("به عهده گرفتن وظیفه", fmt.Sprintf("take_task_%d", taskExec.ID)),
+		))
+	}
+	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("💬 نظر", fmt.Sprintf("comment_task_%d", taskExec.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("گفتگو", fmt.Sprintf("comments_task_%d", taskExec.ID)),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending task execution details: %v", errSend)
+	}
+	sendFormFiles(bot, chatID, answers)
+	for _, attachment := range attachments {
+		sendAttachment(bot, chatID, attachment, "")
+	}
+	return nil
+}
+
+// taskTimingSummary describes the age of a task execution and how it stands against its deadline
+func (h *TaskHandler) taskTimingSummary(taskExec *models.TaskExecution, now time.Time) string {
+	if taskExec.Status == models.TaskStatusCompleted && taskExec.CompletedAt != nil {
+		return fmt.Sprintf("تکمیل شده در %s", formatTime(taskExec.CompletedAt, h.location))
+	}
+
+	summary := fmt.Sprintf("⏱ %s پیش فعال شده", formatDuration(now.Sub(taskExec.CreatedAt)))
+	switch {
+	case taskExec.DueAt == nil:
+		summary += " | بدون مهلت"
+	case taskExec.IsOverdue(now):
+		summary += fmt.Sprintf(" | ⚠️ %s از مهلت گذشته", formatDuration(now.Sub(*taskExec.DueAt)))
+	default:
+		summary += fmt.Sprintf(" | ⏰ %s تا پایان مهلت", formatDuration(taskExec.DueAt.Sub(now)))
+	}
+	return summary
+}
+
+// taskExecutionTitle returns the task title, followed by the list item for instances of multi-instance tasks
+func taskExecutionTitle(taskExec *models.TaskExecution) string {
+	if taskExec.InstanceItem == "" {
+		return taskExec.Task.Title
+	}
+	return fmt.Sprintf("%s — %s", taskExec.Task.Title, taskExec.InstanceItem)
+}
+
+func formatTime(t *time.Time, location *time.Location) string {
+	if t == nil {
+		return "-"
+	}
+	return t.In(location).Format("2006-01-02 15:04")
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%d روز", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%d ساعت", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d دقیقه", int(d.Minutes()))
+	}
+}
+
+func taskStatusTitle(status models.TaskStatus) string {
+	switch status {
+	case models.TaskStatusPending:
+		return "در انتظار به عهده گرفتن"
+	case models.TaskStatusAssigned:
+		return "در حال انجام"
+	case models.TaskStatusCompleted:
+		return "تکمیل شده"
+	case models.TaskStatusCancelled:
+		return "لغو شده"
+	case models.TaskStatusWaiting:
+		return "در انتظار (زیرفرایند، زمان‌سنج یا وب‌هوک)"
+	case models.TaskStatusFailed:
+		return "ناموفق"
+	}
+	return string(status)
+}
+
+func assignmentActionTitle(action models.TaskAssignmentAction) string {
+	switch action {
+	case models.TaskAssignmentActionClaim:
+		return "به عهده گرفته شد"
+	case models.TaskAssignmentActionRelease:
+		return "به تیم بازگردانده شد"
+	case models.TaskAssignmentActionDelegateRequest:
+		return "درخواست واگذاری ارسال شد"
+	case models.TaskAssignmentActionDelegateAccept:
+		return "واگذاری پذیرفته شد"
+	case models.TaskAssignmentActionDelegateDecline:
+		return "واگذاری رد شد"
+	case models.TaskAssignmentActionReassign:
+		return "توسط مالک تیم دوباره تخصیص داده شد"
+	}
+	return string(action)
+}
+
+// processExecutionName returns the title and number of the process execution of a task execution
+func processExecutionName(taskExec *models.TaskExecution) string {
+	if taskExec.ProcessExecution == nil {
+		return fmt.Sprintf("#%d", taskExec.ProcessExecutionID)
+	}
+	return taskExec.ProcessExecution.DisplayName()
+}
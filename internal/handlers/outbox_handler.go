@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// deadLetterListSize is the number of undelivered messages shown to a process owner
+const deadLetterListSize = 10
+
+// OutboxHandler shows process owners the messages that could not be delivered and lets them send them again.
+type OutboxHandler struct {
+	outboxService service.OutboxService
+	userService   service.UserService
+	location      *time.Location
+}
+
+// NewOutboxHandler creates a new OutboxHandler.
+func NewOutboxHandler(outboxService service.OutboxService, userService service.UserService, location *time.Location) *OutboxHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &OutboxHandler{
+		outboxService: outboxService,
+		userService:   userService,
+		location:      location,
+	}
+}
+
+// HandleOutboxCommands handles the /outbox command, which lists the undelivered messages of the user's processes.
+func (h *OutboxHandler) HandleOutboxCommands(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || update.Message.Text != "/outbox" {
+		return
+	}
+	h.sendDeadLetters(bot, update.Message.Chat.ID, update.Message.From.ID)
+}
+
+// HandleOutboxCallback handles sending undelivered messages again.
+func (h *OutboxHandler) HandleOutboxCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil || !strings.HasPrefix(update.CallbackQuery.Data, "outbox_retry_") {
+		return
+	}
+	userID := update.CallbackQuery.From.ID
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callbackMsg := "در صف ارسال قرار گرفت"
+
+	messageID, err := strconv.ParseUint(strings.TrimPrefix(update.CallbackQuery.Data, "outbox_retry_"), 10, 64)
+	if err != nil {
+		sendMessage(chatID, "شناسه پیام نامعتبر است.")
+		callbackMsg = "خطا"
+	} else if err := h.outboxService.Retry(uint(messageID), userID); err != nil {
+		sendMessage(chatID, "خطا در ارسال دوباره: "+err.Error())
+		callbackMsg = "خطا"
+	} else {
+		sendMessage(chatID, "پیام دوباره در صف ارسال قرار گرفت.")
+	}
+
+	callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+	if _, err := bot.Request(callback); err != nil {
+		log.Printf("Error answering callback query: %v", err)
+	}
+}
+
+// sendDeadLetters lists the latest undelivered messages of the user's processes with buttons to send them again
+func (h *OutboxHandler) sendDeadLetters(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	messages, err := h.outboxService.GetDeadMessages(userID, deadLetterListSize)
+	var text strings.Builder
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	switch {
+	case err != nil:
+		text.WriteString("خطا در دریافت پیام‌های ارسال نشده. لطفا دوباره تلاش کنید.")
+	case len(messages) == 0:
+		text.WriteString("✅ همه‌ی پیام‌های فرایندهای شما ارسال شده‌اند.")
+	default:
+		text.WriteString("📭 پیام‌های ارسال نشده‌ی فرایندهای شما:\n")
+		for _, message := range messages {
+			text.WriteString(fmt.Sprintf("\n#%d به %s - %s\n   %s\n   خطا: %s",
+				message.ID, h.recipientName(message.ChatID), formatTime(&message.CreatedAt, h.location), outboxMessageTitle(&message), message.LastError))
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔁 ارسال دوباره #%d", message.ID), fmt.Sprintf("outbox_retry_%d", message.ID)),
+			))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	if len(keyboardRows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	}
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending dead letters: %v", errSend)
+	}
+}
+
+func (h *OutboxHandler) recipientName(chatID int64) string {
+	user, err := h.userService.GetUserByID(chatID)
+	if err != nil || user == nil {
+		return strconv.FormatInt(chatID, 10)
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// outboxMessageTitle describes what an undelivered message was about
+func outboxMessageTitle(message *models.OutboxMessage) string {
+	if message.TaskExecution != nil && message.TaskExecution.Task != nil {
+		return "اعلان وظیفه: " + message.TaskExecution.Task.Title
+	}
+	return shortText(message.Text, 50)
+}
+
+// shortText cuts a text down to the given number of characters, marking the cut
+func shortText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const processExecutionsPageSize = 5
+
+// handleProcessExecutionsPage shows a page of the executions of a process, either as a new message
+// or by editing the list the callback came from. The callback payload has the form "<processID>_<page>".
+func (h *ProcessHandler) handleProcessExecutionsPage(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, payload string, edit bool) error {
+	parts := strings.SplitN(payload, "_", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid payload: %s", payload)
+	}
+	processID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil || page < 0 {
+		return fmt.Errorf("invalid page: %s", payload)
+	}
+
+	process, err := h.processService.GetProcessByID(uint(processID))
+	if err != nil {
+		return err
+	}
+	if process.UserID != callbackQuery.From.ID {
+		return fmt.Errorf("user %d does not own process %d", callbackQuery.From.ID, process.ID)
+	}
+
+	executions, err := h.processService.GetProcessExecutionsByProcessID(process.ID)
+	if err != nil {
+		return err
+	}
+	tasks, err := h.taskService.GetTasksByProcessID(process.ID)
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("اجراهای فرایند %s:\n\n", process.Name))
+	if len(executions) == 0 {
+		text.WriteString("این فرایند هنوز اجرا نشده است.")
+	}
+
+	start := page * processExecutionsPageSize
+	if start > len(executions) {
+		start = len(executions)
+	}
+	end := start + processExecutionsPageSize
+	if end > len(executions) {
+		end = len(executions)
+	}
+
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	for _, execution := range executions[start:end] {
+		taskExecs, err := h.taskService.GetTaskExecutionsByProcessExecutionID(execution.ID)
+		if err != nil {
+			return err
+		}
+		text.WriteString(fmt.Sprintf("%s — %s\n   شروع: %s\n   پایان: %s\n   پیشرفت: %d از %d وظیفه\n\n",
+			execution.DisplayName(),
+			processExecutionStatusTitle(execution.Status),
+			formatTime(&execution.StartedAt, h.location),
+			formatTime(execution.CompletedAt, h.location),
+			completedTaskCount(taskExecs),
+			len(tasks)))
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("جزئیات اجرای #%d", execution.ID), fmt.Sprintf("view_execution_%d", execution.ID)),
+		))
+	}
+
+	pages := (len(executions) + processExecutionsPageSize - 1) / processExecutionsPageSize
+	if pages > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("« قبلی", fmt.Sprintf("executions_page_%d_%d", process.ID, page-1)))
+		}
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("صفحه %d از %d", page+1, pages), fmt.Sprintf("executions_page_%d_%d", process.ID, page)))
+		if page < pages-1 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("بعدی »", fmt.Sprintf("executions_page_%d_%d", process.ID, page+1)))
+		}
+		keyboardRows = append(keyboardRows, navRow)
+	}
+
+	var msg tgbotapi.Chattable
+	if edit {
+		editMsg := tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, text.String())
+		if len(keyboardRows) > 0 {
+			markup := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+			editMsg.ReplyMarkup = &markup
+		}
+		msg = editMsg
+	} else {
+		newMsg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, text.String())
+		if len(keyboardRows) > 0 {
+			newMsg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+		}
+		msg = newMsg
+	}
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending process executions: %v", errSend)
+	}
+	return nil
+}
+
+// sendProcessExecutionDetails sends the state of every task of a process execution.
+func (h *ProcessHandler) sendProcessExecutionDetails(bot *tgbotapi.BotAPI, chatID int64, userID int64, executionID uint) error {
+	execution, err := h.processService.GetProcessExecutionByID(executionID)
+	if err != nil {
+		return err
+	}
+	process, err := h.processService.GetProcessByID(execution.ProcessID)
+	if err != nil {
+		return err
+	}
+	if process.UserID != userID {
+		return fmt.Errorf("user %d does not own process %d", userID, process.ID)
+	}
+
+	tasks, err := h.taskService.GetTasksByProcessID(process.ID)
+	if err != nil {
+		return err
+	}
+	taskExecs, err := h.taskService.GetTaskExecutionsByProcessExecutionID(execution.ID)
+	if err != nil {
+		return err
+	}
+
+	answers, err := h.formService.GetProcessExecutionAnswers(execution.ID)
+	if err != nil {
+		return err
+	}
+	attachments, err := h.attachmentService.GetProcessExecutionAttachments(execution.ID)
+	if err != nil {
+		return err
+	}
+	attachmentCounts := make(map[uint]int)
+	for _, attachment := range attachments {
+		attachmentCounts[attachment.TaskExecutionID]++
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("فرایند: %s\nاجرا: %s\nوضعیت: %s\n", process.Name, execution.DisplayName(), processExecutionStatusTitle(execution.Status)))
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	if execution.ParentTaskExecutionID != nil {
+		if parentTask, err := h.taskService.GetTaskExecutionByID(*execution.ParentTaskExecutionID); err == nil {
+			text.WriteString(fmt.Sprintf("زیرفرایندِ وظیفه «%s» در اجرای %s\n", parentTask.Task.Title, processExecutionName(parentTask)))
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("اجرای والد #%d", parentTask.ProcessExecutionID), fmt.Sprintf("view_execution_%d", parentTask.ProcessExecutionID)),
+			))
+		}
+	}
+	text.WriteString(fmt.Sprintf("شروع: %s\nپایان: %s\nپیشرفت: %d از %d وظیفه\n\n",
+		formatTime(&execution.StartedAt, h.location),
+		formatTime(execution.CompletedAt, h.location),
+		completedTaskCount(taskExecs),
+		len(tasks)))
+
+	variables, err := h.processService.GetVariables(execution.ID)
+	if err != nil {
+		return err
+	}
+	if len(variables) > 0 {
+		names := make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		text.WriteString("متغیرها:\n")
+		for _, name := range names {
+			text.WriteString(fmt.Sprintf("- %s = %s\n", name, variables[name]))
+		}
+		text.WriteString("\n")
+	}
+
+	for _, task := range tasks {
+		text.WriteString(fmt.Sprintf("▫️ %s\n", task.Title))
+		started := false
+		for _, taskExec := range taskExecs {
+			if taskExec.TaskID != task.ID {
+				continue
+			}
+			started = true
+			if taskExec.InstanceItem != "" {
+				text.WriteString(fmt.Sprintf("   مورد: %s\n", taskExec.InstanceItem))
+			}
+			text.WriteString(fmt.Sprintf("   وضعیت: %s\n", taskStatusTitle(taskExec.Status)))
+			if taskExec.User != nil {
+				text.WriteString(fmt.Sprintf("   انجام دهنده: %s %s\n", taskExec.User.FirstName, taskExec.User.LastName))
+			}
+			text.WriteString(fmt.Sprintf("   فعال شده: %s\n", formatTime(&taskExec.CreatedAt, h.location)))
+			if taskExec.AssignedAt != nil {
+				text.WriteString(fmt.Sprintf("   به عهده گرفته شده: %s\n", formatTime(taskExec.AssignedAt, h.location)))
+			}
+			if taskExec.WakeAt != nil && taskExec.Status == models.TaskStatusWaiting && task.IsTimer() {
+				text.WriteString(fmt.Sprintf("   ⏱ ادامه در: %s\n", formatTime(taskExec.WakeAt, h.location)))
+			}
+			if taskExec.CompletedAt != nil {
+				text.WriteString(fmt.Sprintf("   تکمیل شده: %s\n", formatTime(taskExec.CompletedAt, h.location)))
+			}
+			for _, answer := range answers {
+				if answer.TaskExecutionID == taskExec.ID && answer.Field != nil {
+					text.WriteString(fmt.Sprintf("   %s: %s\n", answer.Field.Label, answer.DisplayValue()))
+				}
+			}
+			if count := attachmentCounts[taskExec.ID]; count > 0 {
+				text.WriteString(fmt.Sprintf("   📎 %d پیوست\n", count))
+			}
+			if task.IsSubProcess() {
+				if subExecution, err := h.processService.GetSubProcessExecution(taskExec.ID); err == nil {
+					text.WriteString(fmt.Sprintf("   🔗 زیرفرایند: %s — %s\n", subExecution.DisplayName(), processExecutionStatusTitle(subExecution.Status)))
+					keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("زیرفرایند %s", task.Title), fmt.Sprintf("view_execution_%d", subExecution.ID)),
+					))
+				}
+			}
+		}
+		if !started {
+			text.WriteString("   هنوز فعال نشده\n")
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("💬 گفتگو", fmt.Sprintf("comments_execution_%d", execution.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("بازگشت به اجراها", fmt.Sprintf("process_executions_%d_0", process.ID)),
+	))
+	if execution.Status == models.ProcessExecutionStatusPending || execution.Status == models.ProcessExecutionStatusRunning {
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("لغو فرایند اجرایی", fmt.Sprintf("cancel_execution_%d", execution.ID)),
+		))
+	}
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending process execution details: %v", errSend)
+	}
+	return nil
+}
+
+// completedTaskCount counts the distinct tasks that have a completed execution
+func completedTaskCount(taskExecs []models.TaskExecution) int {
+	completed := make(map[uint]bool)
+	for _, taskExec := range taskExecs {
+		if taskExec.Status == models.TaskStatusCompleted {
+			completed[taskExec.TaskID] = true
+		}
+	}
+	return len(completed)
+}
+
+func processExecutionStatusTitle(status models.ProcessExecutionStatus) string {
+	switch status {
+	case models.ProcessExecutionStatusPending, models.ProcessExecutionStatusRunning:
+		return "🔄 در جریان"
+	case models.ProcessExecutionStatusCompleted:
+		return "✅ تکمیل شده"
+	case models.ProcessExecutionStatusFailed:
+		return "❌ ناموفق"
+	case models.ProcessExecutionStatusCancelled:
+		return "⛔️ لغو شده"
+	}
+	return string(status)
+}
@@ -2,12 +2,12 @@ package handlers
 
 import (
 	// Keep for other handlers if used, or remove if not used in this file
-	"bbb/internal/models"
 	service "bbb/internal/services"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -18,6 +18,10 @@ type ProcessHandler struct {
 	processBuilderService *service.ProcessBuilderService
 	processExecService    service.ProcessExecutionService // Retained as it's a distinct service
 	taskService           service.TaskService
+	formService           service.TaskFormService
+	formFillService       *service.FormFillService
+	attachmentService     service.AttachmentService
+	location              *time.Location
 }
 
 const (
@@ -30,12 +34,23 @@ func NewProcessHandler(
 	processBuilderService *service.ProcessBuilderService,
 	processExecService service.ProcessExecutionService, // Retained
 	taskService service.TaskService,
+	formService service.TaskFormService,
+	formFillService *service.FormFillService,
+	attachmentService service.AttachmentService,
+	location *time.Location,
 ) *ProcessHandler {
+	if location == nil {
+		location = time.Local
+	}
 	return &ProcessHandler{
 		processService:        processService,
 		processBuilderService: processBuilderService,
 		processExecService:    processExecService, // Retained
 		taskService:           taskService,
+		formService:           formService,
+		formFillService:       formFillService,
+		attachmentService:     attachmentService,
+		location:              location,
 	}
 }
 
@@ -176,81 +191,21 @@ func (h *ProcessHandler) HandleProcessCallback(bot *tgbotapi.BotAPI, update tgbo
 			return
 		}
 
-		// Start a new process execution using h.processService
-		execution, err := h.processService.StartProcessExecution(uint(processID))
-		if err != nil {
-			sendMessage(chatID, "خطا در شروع فرایند. لطفا دوباره تلاش کنید.")
-			log.Printf("Error starting process execution: %v", err)
-			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا در شروع")
-			bot.Request(callbackAns)
-			return
-		}
-
-		// Get all tasks for the process
-		tasks, err := h.taskService.GetTasksByProcessID(uint(processID))
+		fields, err := h.formService.GetStartFields(uint(processID))
 		if err != nil {
-			sendMessage(chatID, "خطا در دریافت وظایف اولیه فرایند.")
-			log.Printf("Error getting tasks by processID %d: %v", processID, err)
-			// execution might still be valid, but no tasks to start.
-			// Decide if to rollback or just inform user.
-			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا در وظایف")
+			sendMessage(chatID, "خطا در دریافت فرم شروع فرایند.")
+			log.Printf("Error getting start form of process %d: %v", processID, err)
+			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا در فرم شروع")
 			bot.Request(callbackAns)
 			return
 		}
 
-		var firstTasks []models.Task
-		for _, task := range tasks {
-			preTaskIDs, err := h.taskService.GetTaskPrerequisites(task.ID)
-			if err != nil {
-				sendMessage(chatID, fmt.Sprintf("خطا در بررسی پیش‌نیازهای وظیفه %s.", task.Title))
-				log.Printf("Error getting prerequisites for task %d: %v", task.ID, err)
-				// Potentially skip this task or halt process? For now, log and continue.
-				continue
-			}
-			if len(preTaskIDs) == 0 {
-				firstTasks = append(firstTasks, task)
-			}
-		}
-
-		if len(firstTasks) == 0 {
-			sendMessage(chatID, "فرایند شروع شد، اما هیچ وظیفه اولیه‌ای برای آن تعریف نشده یا پیش‌نیازها مشکل دارند.")
-			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "وظیفه اولیه نیست")
-			bot.Request(callbackAns)
-			return
-		}
-
-		var startedTasksInfo strings.Builder
-		startedTasksCount := 0
-		for _, task := range firstTasks {
-			// StartTaskExecution now also notifies users for the task.
-			// It returns models.TaskExecution
-			taskExecution, err := h.taskService.StartTaskExecution(execution.ID, task.ID)
-			if err != nil {
-				// Log error, maybe inform user specifically about this task failing to start
-				log.Printf("Error starting task execution for task %d in process exec %d: %v", task.ID, execution.ID, err)
-				sendMessage(chatID, fmt.Sprintf("خطا در شروع وظیفه %s. %s", task.Title, err.Error()))
-				continue // Try to start other initial tasks
-			}
-
-			// Add the task execution to the pending list of the process execution
-			if err := h.processService.AddPendingTask(execution.ID, taskExecution.ID); err != nil {
-				log.Printf("Error adding pending task %d to process exec %d: %v", taskExecution.ID, execution.ID, err)
-				sendMessage(chatID, fmt.Sprintf("خطا در ثبت وظیفه %s به عنوان وظیفه در انتظار.", task.Title))
-				// This is more critical, as the task started but isn't tracked by process properly.
-				// Potentially needs rollback or admin alert.
-				continue
-			}
-			startedTasksInfo.WriteString(fmt.Sprintf("- %s\n", task.Title))
-			startedTasksCount++
-		}
-
-		if startedTasksCount > 0 {
-			responseMsg := fmt.Sprintf("فرایند با شناسه اجرای %d شروع شد.\nوظایف اولیه زیر آغاز شدند و به تیم‌های مربوطه اطلاع داده شد:\n%s", execution.ID, startedTasksInfo.String())
-			sendMessage(chatID, responseMsg)
-		} else {
-			sendMessage(chatID, fmt.Sprintf("فرایند با شناسه اجرای %d شروع شد، اما هیچ وظیفه اولیه‌ای با موفقیت آغاز نشد.", execution.ID))
+		// The process is started by TaskHandler once the title and the start form are filled
+		h.formFillService.StartProcessForm(update.CallbackQuery.From.ID, uint(processID), fields)
+		if errSend := askExecutionTitle(bot, chatID); errSend != nil {
+			log.Printf("Error sending execution title question: %v", errSend)
 		}
-		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "فرایند شروع شد")
+		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "شروع فرایند")
 		bot.Request(callbackAns)
 
 	} else if strings.HasPrefix(data, "view_process_") {
@@ -284,8 +239,32 @@ func (h *ProcessHandler) HandleProcessCallback(bot *tgbotapi.BotAPI, update tgbo
 			}
 			keyboard = append(keyboard, row)
 		}
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📊 اجراهای فرایند", fmt.Sprintf("process_executions_%d_0", processID)),
+			tgbotapi.NewInlineKeyboardButtonData("📈 گزارش زمان‌ها", fmt.Sprintf("process_report_%d", processID)),
+		))
 
-		msg := tgbotapi.NewMessage(chatID, "وظایف این فرایند:")
+		text := "وظایف این فرایند:"
+		startFields, err := h.formService.GetStartFields(uint(processID))
+		if err != nil {
+			log.Printf("Error getting start form of process %d: %v", processID, err)
+		} else {
+			keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📝 افزودن فیلد فرم شروع", fmt.Sprintf("add_start_field_%d", processID)),
+			))
+			if len(startFields) > 0 {
+				text = "فرم شروع فرایند:"
+				for _, field := range startFields {
+					text += fmt.Sprintf("\n- %s (%s) ← {{%s}}", field.Label, formFieldTypeTitle(field.Type), field.VariableName())
+					keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData("🗑 حذف "+field.Label, fmt.Sprintf("delete_form_field_%d", field.ID)),
+					))
+				}
+				text += "\n\nوظایف این فرایند:"
+			}
+		}
+
+		msg := tgbotapi.NewMessage(chatID, text)
 		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
 		if _, errBot := bot.Send(msg); errBot != nil {
 			log.Printf("Error sending task list for process: %v", errBot)
@@ -293,29 +272,55 @@ func (h *ProcessHandler) HandleProcessCallback(bot *tgbotapi.BotAPI, update tgbo
 		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "وظایف نمایش داده شد")
 		bot.Request(callbackAns)
 
-	} else if strings.HasPrefix(data, "view_task_") { // This was in original process_handler, might belong to task_handler
-		taskID, err := strconv.ParseUint(strings.TrimPrefix(data, "view_task_"), 10, 64)
+	} else if strings.HasPrefix(data, "process_executions_") || strings.HasPrefix(data, "executions_page_") {
+		edit := strings.HasPrefix(data, "executions_page_")
+		payload := strings.TrimPrefix(strings.TrimPrefix(data, "process_executions_"), "executions_page_")
+		if err := h.handleProcessExecutionsPage(bot, update.CallbackQuery, payload, edit); err != nil {
+			log.Printf("Error listing process executions: %v", err)
+			sendMessage(chatID, "خطا در دریافت اجراهای فرایند.")
+			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا در اجراها")
+			bot.Request(callbackAns)
+			return
+		}
+		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "اجراهای فرایند")
+		bot.Request(callbackAns)
+
+	} else if strings.HasPrefix(data, "view_execution_") {
+		executionID, err := strconv.ParseUint(strings.TrimPrefix(data, "view_execution_"), 10, 64)
 		if err != nil {
-			sendMessage(chatID, "خطا در پردازش شناسه وظیفه.")
-			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "شناسه وظیفه نامعتبر")
+			sendMessage(chatID, "خطا در پردازش شناسه فرایند اجرایی.")
+			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "شناسه نامعتبر")
 			bot.Request(callbackAns)
 			return
 		}
+		if err := h.sendProcessExecutionDetails(bot, chatID, update.CallbackQuery.From.ID, uint(executionID)); err != nil {
+			log.Printf("Error showing process execution %d: %v", executionID, err)
+			sendMessage(chatID, "خطا در دریافت اطلاعات فرایند اجرایی.")
+			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا")
+			bot.Request(callbackAns)
+			return
+		}
+		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "جزئیات اجرا")
+		bot.Request(callbackAns)
 
-		task, err := h.taskService.GetTaskByID(uint(taskID))
+	} else if strings.HasPrefix(data, "cancel_execution_") {
+		executionID, err := strconv.ParseUint(strings.TrimPrefix(data, "cancel_execution_"), 10, 64)
 		if err != nil {
-			sendMessage(chatID, "خطا در دریافت اطلاعات وظیفه.")
-			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا در دریافت وظیفه")
+			sendMessage(chatID, "خطا در پردازش شناسه فرایند اجرایی.")
+			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "شناسه نامعتبر")
 			bot.Request(callbackAns)
 			return
 		}
 
-		taskDetails := fmt.Sprintf("عنوان: %s\\nتوضیحات: %s",
-			task.Title,
-			task.Description,
-		)
-		sendMessage(chatID, taskDetails)
-		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "جزئیات وظیفه")
+		if err := h.taskService.CancelProcessExecution(uint(executionID), update.CallbackQuery.From.ID); err != nil {
+			sendMessage(chatID, "خطا در لغو فرایند اجرایی: "+err.Error())
+			callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "خطا در لغو")
+			bot.Request(callbackAns)
+			return
+		}
+
+		sendMessage(chatID, fmt.Sprintf("فرایند اجرایی %d لغو شد و وظایف باز آن بسته شدند.", executionID))
+		callbackAns := tgbotapi.NewCallback(update.CallbackQuery.ID, "فرایند لغو شد")
 		bot.Request(callbackAns)
 	} else {
 		log.Printf("ProcessHandler received unhandled callback data: %s from chatID %d", data, chatID)
@@ -326,3 +331,16 @@ func (h *ProcessHandler) HandleProcessCallback(bot *tgbotapi.BotAPI, update tgbo
 
 // No specific inline keyboards defined here for now, as main.go handles the persistent keyboard.
 // If HandleProcessCreation callback for confirmation was still here, its keyboard would be defined here.
+
+// askExecutionTitle asks for the title of a process execution, the first question of starting a process
+func askExecutionTitle(bot *tgbotapi.BotAPI, chatID int64) error {
+	msg := tgbotapi.NewMessage(chatID, "لطفا عنوانی برای این اجرای فرایند وارد کنید (مثلا «خرید لپ‌تاپ واحد مالی»):")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("بدون عنوان", "form_skip"),
+			tgbotapi.NewInlineKeyboardButtonData("لغو", "form_cancel"),
+		),
+	)
+	_, err := bot.Send(msg)
+	return err
+}
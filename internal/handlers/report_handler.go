@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// reportBarWidth is the number of blocks of the longest bar of a report chart
+	reportBarWidth = 12
+	// reportMessageLimit keeps a report within the length of a single message
+	reportMessageLimit = 4000
+)
+
+// reportPeriods are the periods, in weeks, a report can be switched to
+var reportPeriods = []int{4, service.ReportDefaultWeeks, 26, 52}
+
+// ReportHandler shows process owners how long their processes and tasks take and where executions get stuck, and
+// team managers how the work of their team is going and how it is spread among the members.
+type ReportHandler struct {
+	reportService service.ReportService
+	teamService   service.TeamService
+	location      *time.Location
+}
+
+// NewReportHandler creates a new ReportHandler.
+func NewReportHandler(reportService service.ReportService, teamService service.TeamService, location *time.Location) *ReportHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &ReportHandler{
+		reportService: reportService,
+		teamService:   teamService,
+		location:      location,
+	}
+}
+
+// HandleReportCallback handles opening the report of a process or team, switching its period and exporting it as CSV.
+// Callback data has the forms "process_report_<processID>", "report_period_<processID>_<weeks>" and
+// "report_csv_<processID>_<weeks>", and the same for teams with "team_report_", "team_period_" and "team_csv_".
+func (h *ReportHandler) HandleReportCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	message := update.CallbackQuery.Message
+	var callbackMsg string
+
+	switch {
+	case strings.HasPrefix(data, "process_report_"):
+		processID, err := strconv.ParseUint(strings.TrimPrefix(data, "process_report_"), 10, 64)
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		report, err := h.reportService.ProcessReport(uint(processID), userID, service.ReportDefaultWeeks)
+		if err != nil {
+			sendMessage(message.Chat.ID, "خطا در تهیه‌ی گزارش: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, h.processReportText(report))
+		msg.ReplyMarkup = reportKeyboard("report_period_", "report_csv_", report.Process.ID, report.Weeks)
+		if _, err := bot.Send(msg); err != nil {
+			log.Printf("Error sending process report: %v", err)
+		}
+		callbackMsg = "گزارش فرایند"
+
+	case strings.HasPrefix(data, "report_period_"):
+		processID, weeks, err := parseReportPayload(strings.TrimPrefix(data, "report_period_"))
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		report, err := h.reportService.ProcessReport(processID, userID, weeks)
+		if err != nil {
+			sendMessage(message.Chat.ID, "خطا در تهیه‌ی گزارش: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, h.processReportText(report),
+			reportKeyboard("report_period_", "report_csv_", report.Process.ID, report.Weeks))
+		if _, err := bot.Send(edit); err != nil {
+			log.Printf("Error editing process report: %v", err)
+		}
+		callbackMsg = formatReportPeriod(weeks)
+
+	case strings.HasPrefix(data, "report_csv_"):
+		processID, weeks, err := parseReportPayload(strings.TrimPrefix(data, "report_csv_"))
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		report, err := h.reportService.ProcessReport(processID, userID, weeks)
+		if err != nil {
+			sendMessage(message.Chat.ID, "خطا در تهیه‌ی گزارش: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		content, err := h.reportService.ProcessReportCSV(report)
+		if err != nil {
+			log.Printf("Error writing CSV report of process %d: %v", processID, err)
+			sendMessage(message.Chat.ID, "خطا در ساخت فایل گزارش.")
+			callbackMsg = "خطا"
+			break
+		}
+		document := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{
+			Name:  fmt.Sprintf("process-%d-report-%s.csv", processID, report.Until.In(h.location).Format("2006-01-02")),
+			Bytes: content,
+		})
+		document.Caption = fmt.Sprintf("گزارش فرایند «%s» — %s", report.Process.Name, formatReportPeriod(weeks))
+		if _, err := bot.Send(document); err != nil {
+			log.Printf("Error sending CSV report: %v", err)
+		}
+		callbackMsg = "فایل گزارش ارسال شد"
+
+	case strings.HasPrefix(data, "team_report_"):
+		teamID, err := strconv.ParseUint(strings.TrimPrefix(data, "team_report_"), 10, 64)
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		report, err := h.teamReport(uint(teamID), userID, service.ReportDefaultWeeks)
+		if err != nil {
+			sendMessage(message.Chat.ID, "خطا در تهیه‌ی گزارش: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, h.teamReportText(report))
+		msg.ReplyMarkup = reportKeyboard("team_period_", "team_csv_", report.Team.ID, report.Weeks)
+		if _, err := bot.Send(msg); err != nil {
+			log.Printf("Error sending team report: %v", err)
+		}
+		callbackMsg = "عملکرد تیم"
+
+	case strings.HasPrefix(data, "team_period_"):
+		teamID, weeks, err := parseReportPayload(strings.TrimPrefix(data, "team_period_"))
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		report, err := h.teamReport(teamID, userID, weeks)
+		if err != nil {
+			sendMessage(message.Chat.ID, "خطا در تهیه‌ی گزارش: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, h.teamReportText(report),
+			reportKeyboard("team_period_", "team_csv_", report.Team.ID, report.Weeks))
+		if _, err := bot.Send(edit); err != nil {
+			log.Printf("Error editing team report: %v", err)
+		}
+		callbackMsg = formatReportPeriod(weeks)
+
+	case strings.HasPrefix(data, "team_csv_"):
+		teamID, weeks, err := parseReportPayload(strings.TrimPrefix(data, "team_csv_"))
+		if err != nil {
+			callbackMsg = "شناسه نامعتبر"
+			break
+		}
+		report, err := h.teamReport(teamID, userID, weeks)
+		if err != nil {
+			sendMessage(message.Chat.ID, "خطا در تهیه‌ی گزارش: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		content, err := h.reportService.TeamReportCSV(report)
+		if err != nil {
+			log.Printf("Error writing CSV report of team %d: %v", teamID, err)
+			sendMessage(message.Chat.ID, "خطا در ساخت فایل گزارش.")
+			callbackMsg = "خطا"
+			break
+		}
+		document := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{
+			Name:  fmt.Sprintf("team-%d-report-%s.csv", teamID, report.Until.In(h.location).Format("2006-01-02")),
+			Bytes: content,
+		})
+		document.Caption = fmt.Sprintf("عملکرد تیم «%s» — %s", report.Team.Name, formatReportPeriod(weeks))
+		if _, err := bot.Send(document); err != nil {
+			log.Printf("Error sending CSV report: %v", err)
+		}
+		callbackMsg = "فایل گزارش ارسال شد"
+
+	default:
+		return
+	}
+
+	callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+	if _, err := bot.Request(callback); err != nil {
+		log.Printf("Error answering callback query: %v", err)
+	}
+}
+
+// processReportText describes the report with text bar charts of the weekly throughput and of where executions spend their time
+func (h *ReportHandler) processReportText(report *service.ProcessReport) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("📈 گزارش فرایند «%s» — %s\n", report.Process.Name, formatReportPeriod(report.Weeks)))
+	text.WriteString(fmt.Sprintf("از %s تا %s\n\n", report.Since.In(h.location).Format("2006-01-02"), report.Until.In(h.location).Format("2006-01-02")))
+	text.WriteString(fmt.Sprintf("اجراها: %d شروع شده، %d تکمیل شده، %d باز\n", report.Started, report.Completed, report.Open))
+	text.WriteString(fmt.Sprintf("⏱ زمان کامل اجرا: %s\n", formatDurationStats(report.CycleTime)))
+
+	if report.Bottleneck != nil {
+		text.WriteString(fmt.Sprintf("\n🚧 گلوگاه: «%s» — میانه‌ی ماندن %s", report.Bottleneck.Task.Title, formatDuration(report.Bottleneck.Dwell.Median)))
+		if report.Bottleneck.Open > 0 {
+			text.WriteString(fmt.Sprintf("، %d مورد باز", report.Bottleneck.Open))
+		}
+		text.WriteString("\n")
+	}
+
+	if report.Completed > 0 {
+		maxCompleted := 0
+		for _, week := range report.Throughput {
+			maxCompleted = max(maxCompleted, week.Completed)
+		}
+		text.WriteString("\n📦 اجراهای تکمیل شده در هر هفته:\n")
+		for _, week := range report.Throughput {
+			text.WriteString(fmt.Sprintf("%s %s %d\n", week.WeekStart.In(h.location).Format("01-02"), reportBar(float64(week.Completed), float64(maxCompleted)), week.Completed))
+		}
+	}
+
+	var maxDwell time.Duration
+	for _, taskReport := range report.Tasks {
+		maxDwell = max(maxDwell, taskReport.Dwell.Median)
+	}
+	if maxDwell > 0 {
+		text.WriteString("\n🧭 میانه‌ی ماندن اجراها در هر وظیفه (از فعال شدن تا تکمیل، یا تا اکنون برای موارد باز):\n")
+		for _, taskReport := range report.Tasks {
+			if taskReport.Dwell.Count == 0 {
+				continue
+			}
+			text.WriteString(fmt.Sprintf("%s %s %s\n", reportBar(float64(taskReport.Dwell.Median), float64(maxDwell)), formatDuration(taskReport.Dwell.Median), taskReport.Task.Title))
+		}
+	}
+
+	var details strings.Builder
+	for _, taskReport := range report.Tasks {
+		if taskReport.Completed == 0 && taskReport.Open == 0 {
+			continue
+		}
+		details.WriteString(fmt.Sprintf("\n• %s — %d تکمیل شده، %d باز\n", taskReport.Task.Title, taskReport.Completed, taskReport.Open))
+		if taskReport.Claim.Count > 0 {
+			details.WriteString(fmt.Sprintf("   تا به عهده گرفتن: %s\n", formatDurationStats(taskReport.Claim)))
+		}
+		if taskReport.Work.Count > 0 {
+			details.WriteString(fmt.Sprintf("   تا تکمیل پس از به عهده گرفتن: %s\n", formatDurationStats(taskReport.Work)))
+		}
+		if taskReport.Lead.Count > 0 {
+			details.WriteString(fmt.Sprintf("   کل زمان وظیفه: %s\n", formatDurationStats(taskReport.Lead)))
+		}
+	}
+	if details.Len() == 0 {
+		text.WriteString("\nدر این بازه هیچ وظیفه‌ای از این فرایند انجام یا باز نشده است.")
+	} else {
+		text.WriteString("\n📋 جزئیات وظایف (میانگین / میانه / صدک ۹۰):\n")
+		text.WriteString(details.String())
+	}
+
+	return fitReportMessage(text.String())
+}
+
+// teamReport returns the report of the team if the user manages it
+func (h *ReportHandler) teamReport(teamID uint, userID int64, weeks int) (*service.TeamReport, error) {
+	team, err := h.teamService.GetTeamByID(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if !h.teamService.IsManager(team, userID) {
+		return nil, errors.New("فقط مالک تیم یا مدیران گروه تیم می‌توانند عملکرد تیم را ببینند")
+	}
+	return h.reportService.TeamReport(team.ID, weeks)
+}
+
+// teamReportText describes the team's work with text bar charts of the weekly completions and of each member's workload
+func (h *ReportHandler) teamReportText(report *service.TeamReport) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("👥 عملکرد تیم «%s» — %s\n", report.Team.Name, formatReportPeriod(report.Weeks)))
+	text.WriteString(fmt.Sprintf("از %s تا %s\n\n", report.Since.In(h.location).Format("2006-01-02"), report.Until.In(h.location).Format("2006-01-02")))
+	text.WriteString(fmt.Sprintf("باز: %d (%d در انتظار به عهده گرفتن، %d در حال انجام)\n", report.Pending+report.Assigned, report.Pending, report.Assigned))
+	text.WriteString(fmt.Sprintf("تکمیل شده در این بازه: %d\n", report.Completed))
+	text.WriteString(fmt.Sprintf("⏱ زمان انجام پس از به عهده گرفتن: %s\n", formatDurationStats(report.Completion)))
+	if len(report.Overdue) > 0 {
+		text.WriteString(fmt.Sprintf("⏰ عقب‌افتاده: %d\n", len(report.Overdue)))
+	}
+
+	if report.Completed > 0 {
+		maxCompleted := 0
+		for _, week := range report.Throughput {
+			maxCompleted = max(maxCompleted, week.Completed)
+		}
+		text.WriteString("\n📦 وظایف تکمیل شده در هر هفته:\n")
+		for _, week := range report.Throughput {
+			text.WriteString(fmt.Sprintf("%s %s %d\n", week.WeekStart.In(h.location).Format("01-02"), reportBar(float64(week.Completed), float64(maxCompleted)), week.Completed))
+		}
+	}
+
+	maxAssigned := 0
+	for _, member := range report.Members {
+		maxAssigned = max(maxAssigned, member.Assigned)
+	}
+	if maxAssigned > 0 {
+		text.WriteString("\n⚖️ کارهای در دست هر عضو:\n")
+		for _, member := range report.Members {
+			text.WriteString(fmt.Sprintf("%s %d %s\n", reportBar(float64(member.Assigned), float64(maxAssigned)), member.Assigned, memberName(&member.User)))
+		}
+	}
+
+	if len(report.Members) > 0 {
+		text.WriteString("\n🧑‍💼 اعضا (زمان انجام: میانگین / میانه / صدک ۹۰):\n")
+		for _, member := range report.Members {
+			text.WriteString(fmt.Sprintf("\n• %s — %d به عهده گرفته، %d تکمیل شده، %d در دست", memberName(&member.User), member.Claimed, member.Completed, member.Assigned))
+			if member.Overdue > 0 {
+				text.WriteString(fmt.Sprintf("، %d عقب‌افتاده", member.Overdue))
+			}
+			text.WriteString("\n")
+			if member.Completion.Count > 0 {
+				text.WriteString(fmt.Sprintf("   زمان انجام: %s\n", formatDurationStats(member.Completion)))
+			}
+		}
+	}
+
+	if len(report.Overdue) > 0 {
+		text.WriteString("\n⏰ کارهای عقب‌افتاده:\n")
+		for _, taskExec := range report.Overdue {
+			assignee := "در انتظار به عهده گرفتن"
+			if taskExec.User != nil {
+				assignee = memberName(taskExec.User)
+			}
+			text.WriteString(fmt.Sprintf("• %s (%s) — %s — مهلت: %s\n", taskExecutionTitle(&taskExec), processExecutionName(&taskExec), assignee, formatTime(taskExec.DueAt, h.location)))
+		}
+	}
+	return fitReportMessage(text.String())
+}
+
+func memberName(user *models.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.Username != "" {
+		name += " (@" + user.Username + ")"
+	}
+	return name
+}
+
+// reportKeyboard offers the other periods of the report of the process or team and its CSV export. The callback data
+// of the buttons is the given prefix followed by "<id>_<weeks>".
+func reportKeyboard(periodPrefix, csvPrefix string, id uint, currentWeeks int) tgbotapi.InlineKeyboardMarkup {
+	var periodRow []tgbotapi.InlineKeyboardButton
+	for _, weeks := range reportPeriods {
+		title := fmt.Sprintf("%d هفته", weeks)
+		if weeks == currentWeeks {
+			title = "✅ " + title
+		}
+		periodRow = append(periodRow, tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("%s%d_%d", periodPrefix, id, weeks)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		periodRow,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📥 دریافت فایل CSV", fmt.Sprintf("%s%d_%d", csvPrefix, id, currentWeeks)),
+		),
+	)
+}
+
+// parseReportPayload parses a callback payload of the form "<id>_<weeks>"
+func parseReportPayload(payload string) (uint, int, error) {
+	parts := strings.SplitN(payload, "_", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid payload: %s", payload)
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	weeks, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint(id), weeks, nil
+}
+
+// fitReportMessage cuts a report at a line boundary to fit in a single message; the CSV file has everything
+func fitReportMessage(text string) string {
+	if len(text) <= reportMessageLimit {
+		return text
+	}
+	cut := strings.LastIndex(text[:reportMessageLimit], "\n")
+	return text[:cut] + "\n\n… ادامه‌ی جزئیات در فایل CSV"
+}
+
+func formatReportPeriod(weeks int) string {
+	return fmt.Sprintf("%d هفته‌ی گذشته", weeks)
+}
+
+func formatDurationStats(stats service.DurationStats) string {
+	if stats.Count == 0 {
+		return "بدون داده"
+	}
+	return fmt.Sprintf("%s / %s / %s (%d مورد)", formatDuration(stats.Average), formatDuration(stats.Median), formatDuration(stats.P90), stats.Count)
+}
+
+// reportBar draws a bar as long as the value relative to the largest one, with eighth blocks for the remainder.
+// Any value above zero gets at least the thinnest block.
+func reportBar(value, maxValue float64) string {
+	if maxValue <= 0 || value <= 0 {
+		return ""
+	}
+	eighths := int(value / maxValue * reportBarWidth * 8)
+	if eighths == 0 {
+		eighths = 1
+	}
+	bar := strings.Repeat("█", eighths/8)
+	if remainder := eighths % 8; remainder > 0 {
+		bar += string([]rune("▏▎▍▌▋▊▉")[remainder-1])
+	}
+	return bar
+}
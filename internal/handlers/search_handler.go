@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// searchPageSize is the number of search results shown on each page
+const searchPageSize = 8
+
+// SearchHandler handles the full-text search of processes, tasks, executions, notes and comments.
+type SearchHandler struct {
+	searchService  service.SearchService
+	searchSessions *service.SearchSessionService
+}
+
+// NewSearchHandler creates a new SearchHandler.
+func NewSearchHandler(searchService service.SearchService, searchSessions *service.SearchSessionService) *SearchHandler {
+	return &SearchHandler{
+		searchService:  searchService,
+		searchSessions: searchSessions,
+	}
+}
+
+// HandleSearchMessage handles the "جستجو" command, either followed by the query or asking for it.
+// Results include private notes, so search only works in private chats.
+func (h *SearchHandler) HandleSearchMessage(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || update.Message.Text == "" || !update.Message.Chat.IsPrivate() {
+		return
+	}
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+	text := strings.TrimSpace(update.Message.Text)
+
+	var query string
+	switch {
+	case text == "جستجو" || text == "/search":
+		h.searchSessions.StartSession(userID)
+		sendMessage(chatID, "عبارت مورد نظر را بفرستید. نام و توضیحات فرایندها و وظایف، عنوان و اطلاعات اجراها، توضیحات انجام دهندگان و نظرها جستجو می‌شوند.")
+		return
+	case strings.HasPrefix(text, "جستجو "):
+		h.searchSessions.EndSession(userID)
+		query = strings.TrimPrefix(text, "جستجو ")
+	case h.searchSessions.EndSession(userID):
+		query = text
+	default:
+		return
+	}
+
+	h.searchSessions.SetQuery(userID, query)
+	resultsText, keyboard, err := h.renderResults(userID, query, 0)
+	if err != nil {
+		sendMessage(chatID, "خطا در جستجو: "+err.Error())
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, resultsText)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	if _, err := bot.Send(msg); err != nil {
+		log.Printf("Error sending search results: %v", err)
+	}
+}
+
+// HandleSearchCallback turns the pages of the last search results.
+func (h *SearchHandler) HandleSearchCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil || !strings.HasPrefix(update.CallbackQuery.Data, "search_page_") {
+		return
+	}
+	userID := update.CallbackQuery.From.ID
+	message := update.CallbackQuery.Message
+	callbackMsg := "نتایج جستجو"
+
+	page, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, "search_page_"))
+	query, exists := h.searchSessions.GetQuery(userID)
+	switch {
+	case err != nil || page < 0:
+		callbackMsg = "صفحه نامعتبر"
+	case !exists:
+		callbackMsg = "این جستجو منقضی شده است؛ دوباره جستجو کنید"
+	default:
+		text, keyboard, err := h.renderResults(userID, query, page)
+		if err != nil {
+			log.Printf("Error searching for user %d: %v", userID, err)
+			callbackMsg = "خطا در جستجو"
+			break
+		}
+		var edit tgbotapi.EditMessageTextConfig
+		if keyboard != nil {
+			edit = tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, *keyboard)
+		} else {
+			edit = tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+		}
+		if _, err := bot.Send(edit); err != nil {
+			log.Printf("Error editing search results: %v", err)
+		}
+	}
+
+	callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+	if _, err := bot.Request(callback); err != nil {
+		log.Printf("Error answering callback query: %v", err)
+	}
+}
+
+// renderResults lists a page of results with a button opening each one, and buttons to move between pages
+func (h *SearchHandler) renderResults(userID int64, query string, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	results, total, err := h.searchService.FullTextSearch(userID, query, page, searchPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return fmt.Sprintf("🔎 نتیجه‌ای برای «%s» یافت نشد.", query), nil, nil
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🔎 نتایج جستجوی «%s»: %d نتیجه\n\n", query, total))
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	for i, result := range results {
+		number := page*searchPageSize + i + 1
+		icon, kindTitle, callbackData := searchResultView(&result)
+		text.WriteString(fmt.Sprintf("%d. %s %s: %s\n", number, icon, kindTitle, result.Title))
+		if snippet := strings.Join(strings.Fields(result.Snippet), " "); snippet != "" {
+			text.WriteString(fmt.Sprintf("   %s\n", shortText(snippet, 80)))
+		}
+		text.WriteString("\n")
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d. %s %s", number, icon, shortText(result.Title, 30)), callbackData),
+		))
+	}
+
+	pages := int((total + searchPageSize - 1) / searchPageSize)
+	if pages > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("« قبلی", fmt.Sprintf("search_page_%d", page-1)))
+		}
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("صفحه %d از %d", page+1, pages), fmt.Sprintf("search_page_%d", page)))
+		if page < pages-1 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("بعدی »", fmt.Sprintf("search_page_%d", page+1)))
+		}
+		keyboardRows = append(keyboardRows, navRow)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	return text.String(), &keyboard, nil
+}
+
+// searchResultView returns the icon and title of a kind of search result, and the callback opening its view
+func searchResultView(result *models.SearchResult) (string, string, string) {
+	switch result.Kind {
+	case models.SearchResultProcess:
+		return "🧩", "فرایند", fmt.Sprintf("view_process_%d", result.ID)
+	case models.SearchResultTask:
+		return "📋", "وظیفه", fmt.Sprintf("view_task_%d", result.ID)
+	case models.SearchResultExecution:
+		return "📊", "اجرای فرایند", fmt.Sprintf("view_execution_%d", result.ID)
+	case models.SearchResultNote:
+		return "📝", "توضیح انجام دهنده", fmt.Sprintf("task_details_%d", result.ID)
+	case models.SearchResultTaskComment:
+		return "💬", "نظر روی وظیفه", fmt.Sprintf("task_details_%d", result.ID)
+	default:
+		return "💬", "نظر روی اجرا", fmt.Sprintf("view_execution_%d", result.ID)
+	}
+}
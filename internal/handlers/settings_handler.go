@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingsTimeZones are the time zones offered in the settings menu
+var settingsTimeZones = []string{
+	"Asia/Tehran", "Asia/Kabul", "Asia/Dushanbe", "Asia/Dubai", "Asia/Baghdad", "Europe/Istanbul",
+	"Europe/London", "Europe/Berlin", "America/New_York", "America/Toronto", "Australia/Sydney", "UTC",
+}
+
+// weekdayTitles are the Persian names of the days of the week, starting with Sunday like time.Weekday
+var weekdayTitles = []string{"یکشنبه", "دوشنبه", "سه‌شنبه", "چهارشنبه", "پنجشنبه", "جمعه", "شنبه"}
+
+// SettingsHandler handles the notification settings of users: their time zone, their digest, how each event
+// is announced and their quiet hours.
+type SettingsHandler struct {
+	notificationService service.NotificationService
+	digestService       service.DigestService
+}
+
+// NewSettingsHandler creates a new SettingsHandler.
+func NewSettingsHandler(notificationService service.NotificationService, digestService service.DigestService) *SettingsHandler {
+	return &SettingsHandler{
+		notificationService: notificationService,
+		digestService:       digestService,
+	}
+}
+
+// HandleSettingsCommands handles the settings menu button and the /settings command.
+func (h *SettingsHandler) HandleSettingsCommands(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || (update.Message.Text != "تنظیمات" && update.Message.Text != "/settings") {
+		return
+	}
+	chatID := update.Message.Chat.ID
+	if !update.Message.Chat.IsPrivate() {
+		sendMessage(chatID, "تنظیمات اعلان‌ها را در گفتگوی خصوصی با ربات تغییر دهید.")
+		return
+	}
+
+	text, keyboard, err := h.settingsMenu(update.Message.From.ID)
+	if err != nil {
+		sendMessage(chatID, "خطا در دریافت تنظیمات. لطفا دوباره تلاش کنید.")
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending settings menu: %v", errSend)
+	}
+}
+
+// HandleSettingsCallback handles the buttons of the settings menu.
+func (h *SettingsHandler) HandleSettingsCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil || !strings.HasPrefix(update.CallbackQuery.Data, "settings_") {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	chatID := update.CallbackQuery.Message.Chat.ID
+	messageID := update.CallbackQuery.Message.MessageID
+	callbackMsg := "ذخیره شد"
+
+	var err error
+	switch {
+	case data == "settings_menu":
+		callbackMsg = "تنظیمات"
+
+	case data == "settings_tz":
+		h.editMenu(bot, chatID, messageID, "منطقه زمانی خود را انتخاب کنید:", timeZoneKeyboard())
+		h.answer(bot, update.CallbackQuery.ID, "منطقه زمانی")
+		return
+
+	case strings.HasPrefix(data, "settings_tz_"):
+		index, errParse := strconv.Atoi(strings.TrimPrefix(data, "settings_tz_"))
+		if errParse != nil || index < 0 || index >= len(settingsTimeZones) {
+			callbackMsg = "منطقه زمانی نامعتبر"
+			break
+		}
+		err = h.notificationService.SetTimeZone(userID, settingsTimeZones[index])
+
+	case strings.HasPrefix(data, "settings_digest_"):
+		err = h.notificationService.SetDigestFrequency(userID, models.DigestFrequency(strings.TrimPrefix(data, "settings_digest_")))
+
+	case data == "settings_hour":
+		h.editMenu(bot, chatID, messageID, "ساعت ارسال خلاصه را انتخاب کنید:", hourKeyboard("settings_hour_"))
+		h.answer(bot, update.CallbackQuery.ID, "ساعت خلاصه")
+		return
+
+	case strings.HasPrefix(data, "settings_hour_"):
+		hour, errParse := strconv.Atoi(strings.TrimPrefix(data, "settings_hour_"))
+		if errParse != nil {
+			callbackMsg = "ساعت نامعتبر"
+			break
+		}
+		err = h.notificationService.SetDigestHour(userID, hour)
+
+	case data == "settings_weekday":
+		h.editMenu(bot, chatID, messageID, "روز ارسال خلاصه‌ی هفتگی را انتخاب کنید:", digestWeekdayKeyboard())
+		h.answer(bot, update.CallbackQuery.ID, "روز خلاصه")
+		return
+
+	case strings.HasPrefix(data, "settings_weekday_"):
+		weekday, errParse := strconv.Atoi(strings.TrimPrefix(data, "settings_weekday_"))
+		if errParse != nil {
+			callbackMsg = "روز نامعتبر"
+			break
+		}
+		err = h.notificationService.SetDigestWeekday(userID, weekday)
+
+	case strings.HasPrefix(data, "settings_toggle_"):
+		event := models.NotificationEvent(strings.TrimPrefix(data, "settings_toggle_"))
+		settings, errGet := h.notificationService.GetSettings(userID)
+		if errGet != nil {
+			err = errGet
+			break
+		}
+		err = h.notificationService.SetDelivery(userID, event, nextDelivery(settings, event))
+
+	case data == "settings_quiet_toggle":
+		settings, errGet := h.notificationService.GetSettings(userID)
+		if errGet != nil {
+			err = errGet
+			break
+		}
+		err = h.notificationService.SetQuietHours(userID, !settings.QuietHours, settings.QuietStart, settings.QuietEnd)
+
+	case data == "settings_quiet_start":
+		h.editMenu(bot, chatID, messageID, "ساعات سکوت از چه ساعتی شروع شود؟", hourKeyboard("settings_qstart_"))
+		h.answer(bot, update.CallbackQuery.ID, "شروع ساعات سکوت")
+		return
+
+	case data == "settings_quiet_end":
+		h.editMenu(bot, chatID, messageID, "ساعات سکوت در چه ساعتی تمام شود؟", hourKeyboard("settings_qend_"))
+		h.answer(bot, update.CallbackQuery.ID, "پایان ساعات سکوت")
+		return
+
+	case strings.HasPrefix(data, "settings_qstart_"), strings.HasPrefix(data, "settings_qend_"):
+		hour, errParse := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+		settings, errGet := h.notificationService.GetSettings(userID)
+		if errParse != nil || errGet != nil {
+			callbackMsg = "ساعت نامعتبر"
+			break
+		}
+		start, end := settings.QuietStart, settings.QuietEnd
+		if strings.HasPrefix(data, "settings_qstart_") {
+			start = hour
+		} else {
+			end = hour
+		}
+		err = h.notificationService.SetQuietHours(userID, settings.QuietHours, start, end)
+
+	case data == "settings_preview":
+		digest, errBuild := h.digestService.BuildDigest(userID)
+		if errBuild != nil {
+			sendMessage(chatID, "خطا در ساخت خلاصه. لطفا دوباره تلاش کنید.")
+			callbackMsg = "خطا"
+		} else {
+			if _, errSend := bot.Send(tgbotapi.NewMessage(chatID, digest)); errSend != nil {
+				log.Printf("Error sending digest preview: %v", errSend)
+			}
+			callbackMsg = "پیش‌نمایش خلاصه"
+		}
+		h.answer(bot, update.CallbackQuery.ID, callbackMsg)
+		return
+
+	default:
+		callbackMsg = "عملیات نامشخص"
+	}
+
+	if err != nil {
+		sendMessage(chatID, "خطا در ذخیره تنظیمات: "+err.Error())
+		callbackMsg = "خطا"
+	}
+	if text, keyboard, errMenu := h.settingsMenu(userID); errMenu == nil {
+		h.editMenu(bot, chatID, messageID, text, keyboard)
+	}
+	h.answer(bot, update.CallbackQuery.ID, callbackMsg)
+}
+
+// settingsMenu describes the user's settings with buttons to change them
+func (h *SettingsHandler) settingsMenu(userID int64) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	settings, err := h.notificationService.GetSettings(userID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	location := h.notificationService.Location(settings)
+
+	var text strings.Builder
+	text.WriteString("⚙️ تنظیمات اعلان‌ها\n\n")
+	text.WriteString(fmt.Sprintf("🕒 منطقه زمانی: %s (اکنون %s)\n", location.String(), time.Now().In(location).Format("15:04")))
+	text.WriteString(fmt.Sprintf("📰 خلاصه: %s\n", digestTitle(settings)))
+	if settings.NextDigestAt != nil {
+		text.WriteString(fmt.Sprintf("   ارسال بعدی: %s\n", settings.NextDigestAt.In(location).Format("2006-01-02 15:04")))
+	}
+	text.WriteString("\n")
+	for _, event := range models.NotificationEvents {
+		text.WriteString(fmt.Sprintf("%s: %s\n", eventTitle(event), deliveryTitle(settings.Delivery(event))))
+	}
+	if settings.QuietHours {
+		text.WriteString(fmt.Sprintf("\n🌙 ساعات سکوت: %02d:00 تا %02d:00\n", settings.QuietStart, settings.QuietEnd))
+	} else {
+		text.WriteString("\n🌙 ساعات سکوت: خاموش\n")
+	}
+	text.WriteString("\nرویدادهای «در خلاصه» فوری ارسال نمی‌شوند و در خلاصه‌ی بعدی می‌آیند. اعلان‌هایی که در ساعات سکوت برسند پس از پایان آن ارسال می‌شوند؛ خطاها و کارهایی که مستقیما به شما سپرده می‌شوند همیشه فوری ارسال می‌شوند.")
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🕒 منطقه زمانی", "settings_tz")),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(checkedTitle("بدون خلاصه", settings.DigestFrequency == models.DigestOff), "settings_digest_off"),
+			tgbotapi.NewInlineKeyboardButtonData(checkedTitle("روزانه", settings.DigestFrequency == models.DigestDaily), "settings_digest_daily"),
+			tgbotapi.NewInlineKeyboardButtonData(checkedTitle("هفتگی", settings.DigestFrequency == models.DigestWeekly), "settings_digest_weekly"),
+		),
+	}
+	if settings.DigestFrequency != models.DigestOff {
+		digestRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⏰ ساعت خلاصه", "settings_hour"))
+		if settings.DigestFrequency == models.DigestWeekly {
+			digestRow = append(digestRow, tgbotapi.NewInlineKeyboardButtonData("📅 روز خلاصه", "settings_weekday"))
+		}
+		rows = append(rows, digestRow)
+	}
+	for _, event := range models.NotificationEvents {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			eventTitle(event)+": "+deliveryTitle(settings.Delivery(event)), "settings_toggle_"+string(event))))
+	}
+	quietRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(checkedTitle("🌙 ساعات سکوت", settings.QuietHours), "settings_quiet_toggle"))
+	if settings.QuietHours {
+		quietRow = append(quietRow,
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("از %02d:00", settings.QuietStart), "settings_quiet_start"),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("تا %02d:00", settings.QuietEnd), "settings_quiet_end"),
+		)
+	}
+	rows = append(rows, quietRow)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👁 پیش‌نمایش خلاصه", "settings_preview")))
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+func (h *SettingsHandler) editMenu(bot *tgbotapi.BotAPI, chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+	if _, errSend := bot.Send(edit); errSend != nil {
+		log.Printf("Error editing settings menu: %v", errSend)
+	}
+}
+
+func (h *SettingsHandler) answer(bot *tgbotapi.BotAPI, callbackQueryID string, text string) {
+	if _, err := bot.Request(tgbotapi.NewCallback(callbackQueryID, text)); err != nil {
+		log.Printf("Error answering callback query: %v", err)
+	}
+}
+
+func timeZoneKeyboard() tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(settingsTimeZones); i += 2 {
+		row := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(settingsTimeZones[i], fmt.Sprintf("settings_tz_%d", i)))
+		if i+1 < len(settingsTimeZones) {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(settingsTimeZones[i+1], fmt.Sprintf("settings_tz_%d", i+1)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("بازگشت", "settings_menu")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// hourKeyboard offers the hours of the day as buttons whose data is the prefix followed by the hour
+func hourKeyboard(prefix string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for hour := 0; hour < 24; hour += 6 {
+		var row []tgbotapi.InlineKeyboardButton
+		for h := hour; h < hour+6; h++ {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%02d", h), fmt.Sprintf("%s%d", prefix, h)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("بازگشت", "settings_menu")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func digestWeekdayKeyboard() tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	// Saturday first, as the Persian week starts
+	days := []time.Weekday{time.Saturday, time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	for i := 0; i < len(days); i += 4 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, day := range days[i:min(i+4, len(days))] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(weekdayTitles[day], fmt.Sprintf("settings_weekday_%d", day)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("بازگشت", "settings_menu")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func digestTitle(settings *models.NotificationSettings) string {
+	switch settings.DigestFrequency {
+	case models.DigestDaily:
+		return fmt.Sprintf("روزانه، ساعت %02d:00", settings.DigestHour)
+	case models.DigestWeekly:
+		return fmt.Sprintf("هفتگی، %s ساعت %02d:00", weekdayTitles[settings.DigestWeekday], settings.DigestHour)
+	}
+	return "خاموش"
+}
+
+func deliveryTitle(delivery models.NotificationDelivery) string {
+	switch delivery {
+	case models.NotificationDigest:
+		return "در خلاصه"
+	case models.NotificationOff:
+		return "خاموش"
+	}
+	return "فوری"
+}
+
+// nextDelivery returns the delivery the event's button switches to: instant, then the digest when the event
+// can be left to an active digest, then off
+func nextDelivery(settings *models.NotificationSettings, event models.NotificationEvent) models.NotificationDelivery {
+	switch settings.Delivery(event) {
+	case models.NotificationInstant:
+		if event.InDigest() && settings.DigestFrequency != models.DigestOff {
+			return models.NotificationDigest
+		}
+		return models.NotificationOff
+	case models.NotificationDigest:
+		return models.NotificationOff
+	}
+	return models.NotificationInstant
+}
+
+func eventTitle(event models.NotificationEvent) string {
+	switch event {
+	case models.NotificationEventTeamTask:
+		return "🙋 وظیفه‌ی جدید در تیم‌های من"
+	case models.NotificationEventTaskReassigned:
+		return "🔁 واگذاری وظیفه‌ی من به دیگری"
+	case models.NotificationEventProcessCompleted:
+		return "✅ پیشرفت و تکمیل فرایندهای من"
+	case models.NotificationEventReminder:
+		return "⏰ یادآوری مهلت‌ها"
+	}
+	return string(event)
+}
+
+func checkedTitle(title string, checked bool) string {
+	if checked {
+		return "✅ " + title
+	}
+	return title
+}
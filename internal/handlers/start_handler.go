@@ -24,7 +24,8 @@ func (h *StartHandler) HandleStartCommand(bot *tgbotapi.BotAPI, update tgbotapi.
 		return
 	}
 
-	if update.Message.Text == "/start" {
+	// Inline mode sends users here as "/start inline" when a search finds nothing
+	if update.Message.Command() == "start" {
 		welcomeMessage := `به ربات مدیریت فرآیندهای کسب و کار خوش آمدید! 👋
 
 برای شروع کار با ربات، می‌توانید از دستورات زیر استفاده کنید:
@@ -34,8 +35,17 @@ func (h *StartHandler) HandleStartCommand(bot *tgbotapi.BotAPI, update tgbotapi.
 • فرایند ها - مشاهده لیست فرآیندهای شما
 • شروع فرایند - شروع اجرای یک فرآیند
 • وظیفه جدید - ایجاد یک وظیفه جدید
+• وظایف من - مشاهده و انجام وظایف شما و تیم‌هایتان
 • تیم جدید - ایجاد یک تیم جدید
 • تیم ها - مشاهده لیست تیم‌ها
+• /link_team در گروه - اتصال گروه به تیم، تا وظایف تیم در گروه اعلام شوند و اعضای گروه عضو تیم شوند (/team و /sync_team و /unlink_team نیز در گروه کار می‌کنند)
+• جستجو - جستجو در فرایندها، وظایف، اجراها، توضیحات و نظرها
+• @نام‌ربات و یک عبارت در هر گفتگو - جستجوی فرایندها، وظایف باز و تیم‌ها و فرستادن کارت آن‌ها با دکمه‌های شروع، به عهده گرفتن یا پیوستن
+• وضعیت حضور - ثبت مرخصی و تعیین جانشین
+• تنظیمات - منطقه زمانی، خلاصه‌ی روزانه یا هفتگی و نحوه‌ی دریافت اعلان‌ها
+• /api_token - ساخت توکن برای اتصال سامانه‌های دیگر به ربات
+• /webhooks - ارسال رویدادهای فرایندها و وظایف به سامانه‌های دیگر
+• /outbox - مشاهده و ارسال دوباره‌ی پیام‌هایی که به اعضا نرسیدند
 • راهنما - مشاهده راهنمای کامل ربات
 
 برای اطلاعات بیشتر می‌توانید از دستور راهنما استفاده کنید.`
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleTaskAttachment stores the files the assignee of a task execution sends as evidence.
+func (h *TaskHandler) HandleTaskAttachment(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil {
+		return
+	}
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	// Files sent while filling a form are answers of file fields
+	if _, filling := h.formFillService.GetSession(userID); filling {
+		return
+	}
+	taskExecutionID, exists := h.attachmentSessions.GetSession(userID)
+	if !exists {
+		return
+	}
+	attachment := messageAttachment(update.Message)
+	if attachment == nil {
+		return
+	}
+	attachment.TaskExecutionID = taskExecutionID
+	attachment.UserID = userID
+
+	if err := h.attachmentService.AddAttachment(attachment); err != nil {
+		h.attachmentSessions.EndSession(userID)
+		sendMessage(chatID, "خطا در ثبت پیوست: "+err.Error())
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "پیوست ثبت شد. می‌توانید فایل دیگری ارسال کنید.")
+	msg.ReplyToMessageID = update.Message.MessageID
+	msg.ReplyMarkup = attachmentDoneKeyboard()
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending attachment confirmation: %v", errSend)
+	}
+}
+
+// handleAttachmentCallback handles the callbacks of sending task attachments.
+// It returns the callback answer, or an empty string when the data is not an attachment callback.
+func (h *TaskHandler) handleAttachmentCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, sendMessage func(chatID int64, text string)) string {
+	data := callbackQuery.Data
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	switch {
+	case strings.HasPrefix(data, "attach_task_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "attach_task_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			return "خطای شناسه"
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(uint(taskExecutionID))
+		if err != nil {
+			sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+			return "اجرای وظیفه یافت نشد"
+		}
+		if taskExec.Status != models.TaskStatusAssigned || taskExec.UserID == nil || *taskExec.UserID != userID {
+			sendMessage(chatID, "فقط انجام دهنده وظیفه می‌تواند پیوست اضافه کند.")
+			return "عدم دسترسی"
+		}
+
+		h.attachmentSessions.StartSession(userID, taskExec.ID)
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("فایل، عکس، ویدیو یا پیام صوتی مربوط به وظیفه «%s» را ارسال کنید.", taskExec.Task.Title))
+		msg.ReplyMarkup = attachmentDoneKeyboard()
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending attachment request: %v", errSend)
+		}
+		return "ارسال پیوست"
+
+	case data == "attach_done":
+		taskExecutionID, exists := h.attachmentSessions.GetSession(userID)
+		if !exists {
+			return "ارسال پیوست فعال نیست"
+		}
+		h.attachmentSessions.EndSession(userID)
+		sendAssignedTask(bot, chatID, taskExecutionID, "ارسال پیوست‌ها به پایان رسید.")
+		return "پایان ارسال پیوست"
+	}
+	return ""
+}
+
+// sendEarlierAttachments sends the attachments of the other tasks of the process execution a task execution belongs to
+func (h *TaskHandler) sendEarlierAttachments(bot *tgbotapi.BotAPI, chatID int64, taskExecutionID uint) {
+	sendEarlierAttachments(bot, h.taskService, h.attachmentService, chatID, taskExecutionID)
+}
+
+func sendEarlierAttachments(bot *tgbotapi.BotAPI, taskService service.TaskService, attachmentService service.AttachmentService, chatID int64, taskExecutionID uint) {
+	taskExec, err := taskService.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting task execution %d: %v", taskExecutionID, err)
+		return
+	}
+	attachments, err := attachmentService.GetProcessExecutionAttachments(taskExec.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting attachments of process execution %d: %v", taskExec.ProcessExecutionID, err)
+		return
+	}
+	taskExecs, err := taskService.GetTaskExecutionsByProcessExecutionID(taskExec.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting task executions of process execution %d: %v", taskExec.ProcessExecutionID, err)
+		return
+	}
+	titles := make(map[uint]string, len(taskExecs))
+	for _, te := range taskExecs {
+		if te.Task != nil {
+			titles[te.ID] = te.Task.Title
+		}
+	}
+
+	var earlier []models.TaskAttachment
+	for _, attachment := range attachments {
+		if attachment.TaskExecutionID != taskExecutionID {
+			earlier = append(earlier, attachment)
+		}
+	}
+	if len(earlier) == 0 {
+		return
+	}
+
+	header := tgbotapi.NewMessage(chatID, fmt.Sprintf("📎 پیوست‌های مراحل قبل این فرایند (%d مورد):", len(earlier)))
+	if _, errSend := bot.Send(header); errSend != nil {
+		log.Printf("Error sending earlier attachments header: %v", errSend)
+	}
+	for _, attachment := range earlier {
+		sendAttachment(bot, chatID, attachment, titles[attachment.TaskExecutionID])
+	}
+}
+
+// sendAttachment sends a stored attachment with the method matching its type
+func sendAttachment(bot *tgbotapi.BotAPI, chatID int64, attachment models.TaskAttachment, title string) {
+	caption := attachment.Caption
+	if title != "" {
+		caption = strings.TrimSpace(fmt.Sprintf("%s\n%s", title, caption))
+	}
+
+	file := tgbotapi.FileID(attachment.FileID)
+	var msg tgbotapi.Chattable
+	switch attachment.Type {
+	case models.AttachmentTypePhoto:
+		photo := tgbotapi.NewPhoto(chatID, file)
+		photo.Caption = caption
+		msg = photo
+	case models.AttachmentTypeVoice:
+		voice := tgbotapi.NewVoice(chatID, file)
+		voice.Caption = caption
+		msg = voice
+	case models.AttachmentTypeAudio:
+		audio := tgbotapi.NewAudio(chatID, file)
+		audio.Caption = caption
+		msg = audio
+	case models.AttachmentTypeVideo:
+		video := tgbotapi.NewVideo(chatID, file)
+		video.Caption = caption
+		msg = video
+	default:
+		document := tgbotapi.NewDocument(chatID, file)
+		document.Caption = caption
+		msg = document
+	}
+	if _, err := bot.Send(msg); err != nil {
+		log.Printf("Error sending attachment %d: %v", attachment.ID, err)
+	}
+}
+
+// messageAttachment returns the attachment carried by a message, or nil when it has no file
+func messageAttachment(message *tgbotapi.Message) *models.TaskAttachment {
+	attachment := &models.TaskAttachment{Caption: message.Caption}
+	switch {
+	case message.Document != nil:
+		attachment.Type = models.AttachmentTypeDocument
+		attachment.FileID = message.Document.FileID
+		attachment.FileUniqueID = message.Document.FileUniqueID
+		attachment.FileName = message.Document.FileName
+		attachment.MimeType = message.Document.MimeType
+		attachment.FileSize = message.Document.FileSize
+	case len(message.Photo) > 0:
+		photo := message.Photo[len(message.Photo)-1]
+		attachment.Type = models.AttachmentTypePhoto
+		attachment.FileID = photo.FileID
+		attachment.FileUniqueID = photo.FileUniqueID
+		attachment.FileSize = photo.FileSize
+	case message.Voice != nil:
+		attachment.Type = models.AttachmentTypeVoice
+		attachment.FileID = message.Voice.FileID
+		attachment.FileUniqueID = message.Voice.FileUniqueID
+		attachment.MimeType = message.Voice.MimeType
+		attachment.FileSize = message.Voice.FileSize
+	case message.Audio != nil:
+		attachment.Type = models.AttachmentTypeAudio
+		attachment.FileID = message.Audio.FileID
+		attachment.FileUniqueID = message.Audio.FileUniqueID
+		attachment.FileName = message.Audio.FileName
+		attachment.MimeType = message.Audio.MimeType
+		attachment.FileSize = message.Audio.FileSize
+	case message.Video != nil:
+		attachment.Type = models.AttachmentTypeVideo
+		attachment.FileID = message.Video.FileID
+		attachment.FileUniqueID = message.Video.FileUniqueID
+		attachment.FileName = message.Video.FileName
+		attachment.MimeType = message.Video.MimeType
+		attachment.FileSize = message.Video.FileSize
+	default:
+		return nil
+	}
+	return attachment
+}
+
+func attachmentDoneKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("پایان ارسال پیوست", "attach_done"),
+		),
+	)
+}
@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleTaskForm handles the text steps of defining form fields and filling task forms.
+func (h *TaskHandler) HandleTaskForm(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil {
+		return
+	}
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if session, exists := h.formFillService.GetSession(userID); exists {
+		if session.CurrentStep == "title" {
+			if update.Message.Text == "" {
+				return
+			}
+			h.formFillService.SetTitle(userID, strings.TrimSpace(update.Message.Text))
+			h.askFormField(bot, chatID, userID, sendMessage)
+			return
+		}
+		if session.CurrentStep == "note" {
+			if update.Message.Text == "" {
+				return
+			}
+			h.formFillService.SetNote(userID, update.Message.Text)
+			h.submitTaskForm(bot, chatID, userID, sendMessage)
+			return
+		}
+
+		field, ok := h.formFillService.CurrentField(userID)
+		if !ok {
+			return
+		}
+		var value string
+		if field.Type == models.FormFieldTypeFile {
+			value = messageFileID(update.Message)
+			if value == "" {
+				sendMessage(chatID, "لطفا فایل یا عکس ارسال کنید.")
+				return
+			}
+		} else {
+			if update.Message.Text == "" {
+				return
+			}
+			var err error
+			if value, err = service.ValidateFormInput(field, update.Message.Text); err != nil {
+				sendMessage(chatID, err.Error())
+				return
+			}
+		}
+		h.formFillService.Answer(userID, value)
+		h.askFormField(bot, chatID, userID, sendMessage)
+		return
+	}
+
+	builder, exists := h.formFieldBuilder.GetBuilder(userID)
+	if !exists || update.Message.Text == "" {
+		return
+	}
+
+	switch builder.CurrentStep {
+	case "label":
+		label := strings.TrimSpace(update.Message.Text)
+		h.formFieldBuilder.SetLabel(userID, label)
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("پاسخ این فیلد در کدام متغیر فرایند ذخیره شود؟ نام متغیر را وارد کنید (مثلا amount).\nدر توضیحات وظایف بعدی می‌توانید با {{amount}} به آن اشاره کنید.\nدر غیر این صورت از عنوان «%s» استفاده می‌شود.", label))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("استفاده از عنوان فیلد", "form_field_variable_default"),
+			),
+		)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending form field variable question: %v", errSend)
+		}
+
+	case "variable":
+		variable := strings.TrimSpace(update.Message.Text)
+		if !service.ValidVariableName(variable) {
+			sendMessage(chatID, "نام متغیر نباید فاصله یا آکولاد داشته باشد. لطفا دوباره وارد کنید:")
+			return
+		}
+		h.formFieldBuilder.SetVariable(userID, variable)
+		h.askFieldType(bot, chatID)
+
+	case "options":
+		field := models.TaskFormField{Options: update.Message.Text}
+		if len(field.Choices()) < 2 {
+			sendMessage(chatID, "فیلد چندگزینه‌ای باید حداقل دو گزینه داشته باشد. هر گزینه را در یک خط بنویسید:")
+			return
+		}
+		h.formFieldBuilder.SetOptions(userID, strings.Join(field.Choices(), "\n"))
+		h.askFieldRequired(bot, chatID)
+	}
+}
+
+// handleFormCallback handles the callbacks of form definition and form filling.
+// It returns the callback answer, or an empty string when the data is not a form callback.
+func (h *TaskHandler) handleFormCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, sendMessage func(chatID int64, text string)) string {
+	data := callbackQuery.Data
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	switch {
+	case strings.HasPrefix(data, "add_form_field_"):
+		taskID, err := strconv.ParseUint(strings.TrimPrefix(data, "add_form_field_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه.")
+			return "خطا در شناسه"
+		}
+		if !h.formService.IsProcessOwner(userID, uint(taskID)) {
+			sendMessage(chatID, "فقط مالک فرایند می‌تواند فرم وظیفه را تغییر دهد.")
+			return "عدم دسترسی"
+		}
+		h.formFieldBuilder.StartField(userID, uint(taskID))
+		sendMessage(chatID, "لطفا عنوان فیلد را وارد کنید (مثلا «مبلغ فاکتور»):")
+		return "افزودن فیلد"
+
+	case strings.HasPrefix(data, "add_start_field_"):
+		processID, err := strconv.ParseUint(strings.TrimPrefix(data, "add_start_field_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه فرایند.")
+			return "خطا در شناسه"
+		}
+		process, err := h.processService.GetProcessByID(uint(processID))
+		if err != nil || process.UserID != userID {
+			sendMessage(chatID, "فقط مالک فرایند می‌تواند فرم شروع را تغییر دهد.")
+			return "عدم دسترسی"
+		}
+		h.formFieldBuilder.StartProcessField(userID, uint(processID))
+		sendMessage(chatID, "لطفا عنوان فیلد فرم شروع را وارد کنید (مثلا «نام مشتری»):")
+		return "افزودن فیلد"
+
+	case strings.HasPrefix(data, "delete_form_field_"):
+		fieldID, err := strconv.ParseUint(strings.TrimPrefix(data, "delete_form_field_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه فیلد.")
+			return "خطا در شناسه"
+		}
+		field, err := h.formService.DeleteField(userID, uint(fieldID))
+		if err != nil {
+			sendMessage(chatID, "خطا در حذف فیلد: "+err.Error())
+			return "خطا در حذف"
+		}
+		sendMessage(chatID, fmt.Sprintf("فیلد «%s» حذف شد.", field.Label))
+		return "فیلد حذف شد"
+
+	case data == "form_field_variable_default":
+		if !h.formFieldBuilder.SetVariable(userID, "") {
+			return "خطا"
+		}
+		h.askFieldType(bot, chatID)
+		return "عنوان فیلد"
+
+	case strings.HasPrefix(data, "form_field_type_"):
+		fieldType := models.FormFieldType(strings.TrimPrefix(data, "form_field_type_"))
+		if !h.formFieldBuilder.SetType(userID, fieldType) {
+			sendMessage(chatID, "خطا در تنظیم نوع فیلد. لطفا دوباره تلاش کنید.")
+			return "خطا"
+		}
+		if fieldType == models.FormFieldTypeChoice {
+			sendMessage(chatID, "گزینه‌ها را وارد کنید، هر گزینه در یک خط:")
+		} else {
+			h.askFieldRequired(bot, chatID)
+		}
+		return "نوع فیلد انتخاب شد"
+
+	case strings.HasPrefix(data, "form_field_required_"):
+		field, ok := h.formFieldBuilder.CompleteField(userID, data == "form_field_required_true")
+		if !ok {
+			sendMessage(chatID, "خطا در ثبت فیلد. لطفا دوباره تلاش کنید.")
+			return "خطا"
+		}
+		if err := h.formService.AddField(userID, field); err != nil {
+			sendMessage(chatID, "خطا در ثبت فیلد: "+err.Error())
+			return "خطا در ثبت"
+		}
+		if field.TaskID == 0 {
+			sendMessage(chatID, fmt.Sprintf("فیلد «%s» به فرم شروع فرایند اضافه شد.", field.Label))
+			return "فیلد ثبت شد"
+		}
+		sendMessage(chatID, fmt.Sprintf("فیلد «%s» به فرم وظیفه اضافه شد.", field.Label))
+		if err := h.sendTaskDetails(bot, chatID, userID, field.TaskID); err != nil {
+			log.Printf("Error sending task details after adding form field: %v", err)
+		}
+		return "فیلد ثبت شد"
+
+	case strings.HasPrefix(data, "form_bool_"):
+		field, ok := h.formFillService.CurrentField(userID)
+		if !ok {
+			return "فرمی در حال تکمیل نیست"
+		}
+		// A button left from an earlier question must not answer the current one
+		if field.Type != models.FormFieldTypeBool {
+			return "گزینه نامعتبر"
+		}
+		value, err := service.ValidateFormInput(field, strings.TrimPrefix(data, "form_bool_"))
+		if err != nil {
+			return "گزینه نامعتبر"
+		}
+		h.formFillService.Answer(userID, value)
+		h.askFormField(bot, chatID, userID, sendMessage)
+		return "ثبت شد"
+
+	case strings.HasPrefix(data, "form_choice_"):
+		field, ok := h.formFillService.CurrentField(userID)
+		if !ok {
+			return "فرمی در حال تکمیل نیست"
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(data, "form_choice_"))
+		choices := field.Choices()
+		if err != nil || index < 0 || index >= len(choices) {
+			return "گزینه نامعتبر"
+		}
+		h.formFillService.Answer(userID, choices[index])
+		h.askFormField(bot, chatID, userID, sendMessage)
+		return "ثبت شد"
+
+	case data == "form_skip":
+		session, exists := h.formFillService.GetSession(userID)
+		if !exists {
+			return "فرمی در حال تکمیل نیست"
+		}
+		noteSkipped := session.CurrentStep == "note"
+		if !h.formFillService.Skip(userID) {
+			return "این فیلد الزامی است"
+		}
+		if noteSkipped {
+			h.submitTaskForm(bot, chatID, userID, sendMessage)
+		} else {
+			h.askFormField(bot, chatID, userID, sendMessage)
+		}
+		return "رد شد"
+
+	case data == "form_cancel":
+		session, exists := h.formFillService.GetSession(userID)
+		if !exists {
+			return "فرمی در حال تکمیل نیست"
+		}
+		h.formFillService.CancelForm(userID)
+		if session.ProcessID != 0 {
+			sendMessage(chatID, "شروع فرایند لغو شد.")
+		} else {
+			sendMessage(chatID, "تکمیل فرم لغو شد. وظیفه همچنان به عهده شماست.")
+		}
+		return "لغو شد"
+	}
+	return ""
+}
+
+// askFormField asks the next field of the form being filled, or the closing note after the last field
+func (h *TaskHandler) askFormField(bot *tgbotapi.BotAPI, chatID int64, userID int64, sendMessage func(chatID int64, text string)) {
+	session, exists := h.formFillService.GetSession(userID)
+	if !exists {
+		return
+	}
+
+	cancelButton := tgbotapi.NewInlineKeyboardButtonData("لغو", "form_cancel")
+	if session.CurrentStep == "note" && session.ProcessID != 0 {
+		h.submitTaskForm(bot, chatID, userID, sendMessage)
+		return
+	}
+	if session.CurrentStep == "note" {
+		msg := tgbotapi.NewMessage(chatID, "در صورت تمایل توضیحی برای تکمیل این وظیفه بنویسید:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("بدون توضیح", "form_skip"), cancelButton),
+		)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending form note question: %v", errSend)
+		}
+		return
+	}
+
+	field := session.Fields[session.Index]
+	text := fmt.Sprintf("(%d/%d) %s", session.Index+1, len(session.Fields), field.Label)
+	if !field.Required {
+		text += " (اختیاری)"
+	}
+
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	switch field.Type {
+	case models.FormFieldTypeBool:
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("بله", "form_bool_true"),
+			tgbotapi.NewInlineKeyboardButtonData("خیر", "form_bool_false"),
+		))
+	case models.FormFieldTypeChoice:
+		for i, choice := range field.Choices() {
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(choice, fmt.Sprintf("form_choice_%d", i)),
+			))
+		}
+	case models.FormFieldTypeNumber:
+		text += "\nیک عدد وارد کنید:"
+	case models.FormFieldTypeDate:
+		text += "\nتاریخ را به شکل 2025-01-31 وارد کنید:"
+	case models.FormFieldTypeFile:
+		text += "\nفایل یا عکس را ارسال کنید:"
+	default:
+		text += "\nپاسخ را وارد کنید:"
+	}
+
+	lastRow := []tgbotapi.InlineKeyboardButton{cancelButton}
+	if !field.Required {
+		lastRow = append([]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("رد شدن", "form_skip")}, lastRow...)
+	}
+	keyboardRows = append(keyboardRows, lastRow)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending form field question: %v", errSend)
+	}
+}
+
+// submitTaskForm stores the answers of a filled form and completes its task execution,
+// or starts the process of a filled start form
+func (h *TaskHandler) submitTaskForm(bot *tgbotapi.BotAPI, chatID int64, userID int64, sendMessage func(chatID int64, text string)) {
+	session, ok := h.formFillService.CompleteForm(userID)
+	if !ok {
+		return
+	}
+	if session.ProcessID != 0 {
+		h.launchProcess(bot, chatID, session, sendMessage)
+		return
+	}
+	taskExec, err := h.taskService.GetTaskExecutionByID(session.TaskExecutionID)
+	if err != nil {
+		sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+		return
+	}
+	h.completeTaskExecution(chatID, userID, taskExec, session.Answers, session.Note, sendMessage)
+}
+
+// launchProcess starts a process execution with the title and start form values of a filled start form
+func (h *TaskHandler) launchProcess(bot *tgbotapi.BotAPI, chatID int64, session *service.FormFillSession, sendMessage func(chatID int64, text string)) {
+	variables := make(map[string]string, len(session.Answers))
+	for _, answer := range session.Answers {
+		variables[answer.Field.VariableName()] = answer.Value
+	}
+
+	execution, started, err := h.taskService.StartProcessExecution(session.ProcessID, session.Title, variables)
+	if err != nil {
+		sendMessage(chatID, "خطا در شروع فرایند. لطفا دوباره تلاش کنید.")
+		log.Printf("Error starting process %d: %v", session.ProcessID, err)
+		if execution == nil {
+			return
+		}
+	}
+
+	var responseMsg string
+	if len(started) > 0 {
+		var startedTasksInfo strings.Builder
+		for _, taskExecution := range started {
+			startedTasksInfo.WriteString(fmt.Sprintf("- %s\n", taskExecution.Task.Title))
+		}
+		responseMsg = fmt.Sprintf("فرایند %s شروع شد.\nوظایف اولیه زیر آغاز شدند و به تیم‌های مربوطه اطلاع داده شد:\n%s", execution.DisplayName(), startedTasksInfo.String())
+	} else {
+		responseMsg = fmt.Sprintf("فرایند %s شروع شد، اما هیچ وظیفه اولیه‌ای با موفقیت آغاز نشد.", execution.DisplayName())
+	}
+	msg := tgbotapi.NewMessage(chatID, responseMsg)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("لغو فرایند اجرایی", fmt.Sprintf("cancel_execution_%d", execution.ID)),
+		),
+	)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending process start message: %v", errSend)
+	}
+}
+
+// sendTaskDetails sends a task definition with its form fields, and the form editing actions to the process owner
+func (h *TaskHandler) sendTaskDetails(bot *tgbotapi.BotAPI, chatID int64, userID int64, taskID uint) error {
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		return err
+	}
+	fields, err := h.formService.GetFields(taskID)
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("عنوان: %s\nتوضیحات: %s", task.Title, task.Description))
+	if task.IsTimer() {
+		text.WriteString("\n\n⏱ " + timerTitle(task))
+	}
+	if task.IsService() {
+		text.WriteString("\n\n🌐 وب‌هوک: " + task.WebhookURL)
+	}
+	if task.IsMultiInstance() {
+		threshold := "همه‌ی نمونه‌ها"
+		if task.CompletionThreshold > 0 {
+			threshold = fmt.Sprintf("%d نمونه", task.CompletionThreshold)
+		}
+		instances := "هر یک از اعضای تیم"
+		if task.MultiInstance == models.MultiInstanceList {
+			instances = fmt.Sprintf("هر مورد از فهرست {{%s}}", task.MultiInstanceVariable)
+		}
+		text.WriteString(fmt.Sprintf("\n\n👥 یک نمونه برای %s\nحد نصاب تکمیل: %s", instances, threshold))
+	}
+	if task.IsSubProcess() {
+		subProcessName := fmt.Sprintf("#%d", *task.SubProcessID)
+		if subProcess, err := h.processService.GetProcessByID(*task.SubProcessID); err == nil {
+			subProcessName = subProcess.Name
+		}
+		text.WriteString(fmt.Sprintf("\n\n🔗 زیرفرایند: %s\nمتغیرهای ورودی: %s\nمتغیرهای خروجی: %s",
+			subProcessName, variableMappingTitle(task.SubProcessInputs), variableMappingTitle(task.SubProcessOutputs)))
+	}
+	if len(fields) == 0 {
+		text.WriteString("\n\nاین وظیفه فرم ندارد.")
+	} else {
+		text.WriteString("\n\nفرم تکمیل وظیفه:")
+		for _, field := range fields {
+			text.WriteString(fmt.Sprintf("\n- %s (%s) ← {{%s}}", field.Label, formFieldTypeTitle(field.Type), field.VariableName()))
+			if !field.Required {
+				text.WriteString(" - اختیاری")
+			}
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	if h.formService.IsProcessOwner(userID, taskID) {
+		keyboardRows := [][]tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("➕ افزودن فیلد فرم", fmt.Sprintf("add_form_field_%d", taskID)),
+			),
+		}
+		for _, field := range fields {
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 حذف "+field.Label, fmt.Sprintf("delete_form_field_%d", field.ID)),
+			))
+		}
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	}
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending task details: %v", errSend)
+	}
+	return nil
+}
+
+func (h *TaskHandler) askFieldType(bot *tgbotapi.BotAPI, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "نوع فیلد را انتخاب کنید:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(formFieldTypeTitle(models.FormFieldTypeText), "form_field_type_text"),
+			tgbotapi.NewInlineKeyboardButtonData(formFieldTypeTitle(models.FormFieldTypeNumber), "form_field_type_number"),
+			tgbotapi.NewInlineKeyboardButtonData(formFieldTypeTitle(models.FormFieldTypeDate), "form_field_type_date"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(formFieldTypeTitle(models.FormFieldTypeBool), "form_field_type_bool"),
+			tgbotapi.NewInlineKeyboardButtonData(formFieldTypeTitle(models.FormFieldTypeChoice), "form_field_type_choice"),
+			tgbotapi.NewInlineKeyboardButtonData(formFieldTypeTitle(models.FormFieldTypeFile), "form_field_type_file"),
+		),
+	)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending form field types: %v", errSend)
+	}
+}
+
+func (h *TaskHandler) askFieldRequired(bot *tgbotapi.BotAPI, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "آیا پاسخ به این فیلد الزامی است؟")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("الزامی", "form_field_required_true"),
+			tgbotapi.NewInlineKeyboardButtonData("اختیاری", "form_field_required_false"),
+		),
+	)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending form field required question: %v", errSend)
+	}
+}
+
+// messageFileID returns the file_id of a document or the largest photo of a message
+func messageFileID(message *tgbotapi.Message) string {
+	if message.Document != nil {
+		return message.Document.FileID
+	}
+	if len(message.Photo) > 0 {
+		return message.Photo[len(message.Photo)-1].FileID
+	}
+	return ""
+}
+
+// sendFormFiles sends the files attached to file fields of a form
+func sendFormFiles(bot *tgbotapi.BotAPI, chatID int64, answers []models.TaskFormAnswer) {
+	for _, answer := range answers {
+		if answer.Field == nil || answer.Field.Type != models.FormFieldTypeFile {
+			continue
+		}
+		// Photos and documents share the file_id space, but each has to be sent with its own method
+		document := tgbotapi.NewDocument(chatID, tgbotapi.FileID(answer.Value))
+		document.Caption = answer.Field.Label
+		if _, err := bot.Send(document); err != nil {
+			photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(answer.Value))
+			photo.Caption = answer.Field.Label
+			if _, errPhoto := bot.Send(photo); errPhoto != nil {
+				log.Printf("Error sending form file %d: %v", answer.ID, errPhoto)
+			}
+		}
+	}
+}
+
+// formatFormAnswers lists form answers, one per line
+func formatFormAnswers(answers []models.TaskFormAnswer) string {
+	var text strings.Builder
+	for _, answer := range answers {
+		if answer.Field == nil {
+			continue
+		}
+		text.WriteString(fmt.Sprintf("\n- %s: %s", answer.Field.Label, answer.DisplayValue()))
+	}
+	return text.String()
+}
+
+func formFieldTypeTitle(fieldType models.FormFieldType) string {
+	switch fieldType {
+	case models.FormFieldTypeText:
+		return "متن"
+	case models.FormFieldTypeNumber:
+		return "عدد"
+	case models.FormFieldTypeDate:
+		return "تاریخ"
+	case models.FormFieldTypeBool:
+		return "بله/خیر"
+	case models.FormFieldTypeChoice:
+		return "چندگزینه‌ای"
+	case models.FormFieldTypeFile:
+		return "فایل"
+	}
+	return string(fieldType)
+}
+
+// variableMappingTitle describes a sub-process variable mapping on one line
+func variableMappingTitle(mapping string) string {
+	var entries []string
+	for _, line := range strings.Split(mapping, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if len(entries) == 0 {
+		return "همه‌ی متغیرها"
+	}
+	return strings.Join(entries, "، ")
+}
+
+// timerTitle describes how long a timer task waits
+func timerTitle(task *models.Task) string {
+	wait := formatDuration(time.Duration(task.TimerMinutes) * time.Minute)
+	switch task.TimerType {
+	case models.TimerTypeDuration:
+		return fmt.Sprintf("انتظار به مدت %s", wait)
+	case models.TimerTypeBusinessHours:
+		return fmt.Sprintf("انتظار به مدت %s و سپس تا اولین ساعت کاری", wait)
+	case models.TimerTypeUntilVariable:
+		return fmt.Sprintf("انتظار تا زمان {{%s}}", task.TimerVariable)
+	}
+	return string(task.TimerType)
+}
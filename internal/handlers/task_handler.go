@@ -17,6 +17,13 @@ type TaskHandler struct {
 	taskBuilderService *service.TaskBuilderService
 	processService     service.ProcessService
 	teamService        service.TeamService
+	formService        service.TaskFormService
+	formFieldBuilder   *service.FormFieldBuilderService
+	formFillService    *service.FormFillService
+	attachmentService  service.AttachmentService
+	attachmentSessions *service.AttachmentSessionService
+	notifications      service.NotificationService
+	location           *time.Location
 }
 
 func NewTaskHandler(
@@ -24,12 +31,29 @@ func NewTaskHandler(
 	taskBuilderService *service.TaskBuilderService,
 	processService service.ProcessService,
 	teamService service.TeamService,
+	formService service.TaskFormService,
+	formFieldBuilder *service.FormFieldBuilderService,
+	formFillService *service.FormFillService,
+	attachmentService service.AttachmentService,
+	attachmentSessions *service.AttachmentSessionService,
+	notifications service.NotificationService,
+	location *time.Location,
 ) *TaskHandler {
+	if location == nil {
+		location = time.Local
+	}
 	return &TaskHandler{
 		taskService:        taskService,
 		taskBuilderService: taskBuilderService,
 		processService:     processService,
 		teamService:        teamService,
+		formService:        formService,
+		formFieldBuilder:   formFieldBuilder,
+		formFillService:    formFillService,
+		attachmentService:  attachmentService,
+		attachmentSessions: attachmentSessions,
+		notifications:      notifications,
+		location:           location,
 	}
 }
 
@@ -125,6 +149,7 @@ func (h *TaskHandler) HandleTaskCreation(bot *tgbotapi.BotAPI, update tgbotapi.U
 				}
 				teamKeyboardRows = append(teamKeyboardRows, row)
 			}
+			teamKeyboardRows = append(teamKeyboardRows, specialTaskOptionRows()...)
 			msg := tgbotapi.NewMessage(chatID, "لطفا تیم مسئول این وظیفه را انتخاب کنید:")
 			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(teamKeyboardRows...)
 			if _, errSend := bot.Send(msg); errSend != nil {
@@ -137,12 +162,109 @@ func (h *TaskHandler) HandleTaskCreation(bot *tgbotapi.BotAPI, update tgbotapi.U
 				if !h.taskBuilderService.AddPrerequisite(userID, uint(prereqID)) {
 					sendMessage(chatID, "خطا در افزودن پیش‌نیاز با شناسه.")
 				} else {
-					sendMessage(chatID, "پیش‌نیاز افزوده شد. آیا وظیفه پیش‌نیاز دیگری دارد؟ (بله/خیر) یا شناسه بعدی را وارد کنید.")
+					askPrerequisiteCondition(bot, chatID)
 				}
 			} else {
 				sendMessage(chatID, "پاسخ نامعتبر. لطفا 'بله'، 'خیر'، 'skip' یا شناسه وظیفه پیش‌نیاز را وارد کنید.")
 			}
 		}
+	case "prerequisite_condition":
+		if err := service.ValidateCondition(update.Message.Text); err != nil {
+			sendMessage(chatID, err.Error())
+			return
+		}
+		if !h.taskBuilderService.SetPrerequisiteCondition(userID, update.Message.Text) {
+			sendMessage(chatID, "خطا در تنظیم شرط پیش‌نیاز.")
+			return
+		}
+		sendMessage(chatID, "شرط ثبت شد. آیا وظیفه پیش‌نیاز دیگری دارد؟ (بله/خیر) یا شناسه بعدی را وارد کنید یا از لیست بالا انتخاب کنید.")
+	case "timer_wait":
+		wait, err := service.ParseWaitDuration(update.Message.Text)
+		if err != nil {
+			sendMessage(chatID, err.Error())
+			return
+		}
+		if !h.taskBuilderService.SetTimerWait(userID, int(wait/time.Minute)) {
+			sendMessage(chatID, "خطا در تنظیم مدت انتظار.")
+			return
+		}
+		askIsFinal(bot, chatID)
+	case "timer_variable":
+		variable := strings.TrimSpace(update.Message.Text)
+		if !service.ValidVariableName(variable) {
+			sendMessage(chatID, "نام متغیر نباید خالی باشد و نباید فاصله یا آکولاد داشته باشد. دوباره وارد کنید:")
+			return
+		}
+		if !h.taskBuilderService.SetTimerVariable(userID, variable) {
+			sendMessage(chatID, "خطا در تنظیم متغیر زمان‌سنج.")
+			return
+		}
+		askIsFinal(bot, chatID)
+	case "webhook_url":
+		webhookURL := strings.TrimSpace(update.Message.Text)
+		if err := service.ValidateWebhookURL(webhookURL); err != nil {
+			sendMessage(chatID, err.Error()+". دوباره وارد کنید:")
+			return
+		}
+		if !h.taskBuilderService.SetWebhookURL(userID, webhookURL) {
+			sendMessage(chatID, "خطا در تنظیم آدرس وب‌هوک.")
+			return
+		}
+		sendMessage(chatID, "کلید امضای درخواست‌ها را وارد کنید تا بدنه هر درخواست با HMAC-SHA256 در سرآیند "+service.WebhookSignatureHeader+" امضا شود، یا 'skip' را بفرستید:")
+	case "webhook_secret":
+		secret := strings.TrimSpace(update.Message.Text)
+		if strings.EqualFold(secret, "skip") {
+			secret = ""
+		}
+		if !h.taskBuilderService.SetWebhookSecret(userID, secret) {
+			sendMessage(chatID, "خطا در تنظیم کلید وب‌هوک.")
+			return
+		}
+		askIsFinal(bot, chatID)
+	case "multi_variable":
+		variable := strings.TrimSpace(update.Message.Text)
+		if !service.ValidVariableName(variable) {
+			sendMessage(chatID, "نام متغیر نباید خالی باشد و نباید فاصله یا آکولاد داشته باشد. دوباره وارد کنید:")
+			return
+		}
+		if !h.taskBuilderService.SetMultiInstanceVariable(userID, variable) {
+			sendMessage(chatID, "خطا در تنظیم متغیر فهرست.")
+			return
+		}
+		askCompletionThreshold(bot, chatID)
+	case "multi_threshold":
+		threshold, err := strconv.Atoi(strings.TrimSpace(update.Message.Text))
+		if err != nil || threshold < 0 {
+			sendMessage(chatID, "لطفا یک عدد صحیح وارد کنید.")
+			return
+		}
+		if !h.taskBuilderService.SetCompletionThreshold(userID, threshold) {
+			sendMessage(chatID, "خطا در تنظیم حد نصاب تکمیل.")
+			return
+		}
+		askIsFinal(bot, chatID)
+	case "subprocess_inputs":
+		mapping, ok := parseVariableMapping(update.Message.Text)
+		if !ok {
+			sendMessage(chatID, "نگاشت متغیرها نامعتبر است. هر خط باید به صورت «نام» یا «نام=نام جدید» باشد.")
+			return
+		}
+		if !h.taskBuilderService.SetSubProcessInputs(userID, mapping) {
+			sendMessage(chatID, "خطا در تنظیم متغیرهای ورودی زیرفرایند.")
+			return
+		}
+		sendMessage(chatID, "پس از پایان زیرفرایند، کدام متغیرهای آن به این فرایند بازگردند؟ در هر خط به صورت «نام در زیرفرایند=نام» بنویسید، یا برای بازگرداندن همه‌ی متغیرها skip را بفرستید:")
+	case "subprocess_outputs":
+		mapping, ok := parseVariableMapping(update.Message.Text)
+		if !ok {
+			sendMessage(chatID, "نگاشت متغیرها نامعتبر است. هر خط باید به صورت «نام» یا «نام=نام جدید» باشد.")
+			return
+		}
+		if !h.taskBuilderService.SetSubProcessOutputs(userID, mapping) {
+			sendMessage(chatID, "خطا در تنظیم متغیرهای خروجی زیرفرایند.")
+			return
+		}
+		askIsFinal(bot, chatID)
 	default:
 		break
 	}
@@ -185,10 +307,19 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 			sendMessage(chatID, "خطا در افزودن پیش‌نیاز.")
 			callbackMsg = "خطا در افزودن پیش‌نیاز"
 		} else {
-			sendMessage(chatID, "پیش‌نیاز افزوده شد. برای افزودن مورد بعدی انتخاب کنید یا 'اتمام' را بزنید.")
+			askPrerequisiteCondition(bot, chatID)
 			callbackMsg = "پیش‌نیاز افزوده شد"
 		}
 
+	case data == "no_prerequisite_condition":
+		if !h.taskBuilderService.SetPrerequisiteCondition(userID, "") {
+			sendMessage(chatID, "خطا در تنظیم شرط پیش‌نیاز.")
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, "پیش‌نیاز بدون شرط افزوده شد. برای افزودن مورد بعدی انتخاب کنید یا 'اتمام' را بزنید.")
+		callbackMsg = "بدون شرط"
+
 	case data == "done_prerequisites":
 		if !h.taskBuilderService.SetHasMorePrerequisites(userID, false) {
 			sendMessage(chatID, "خطا در پردازش اتمام پیش‌نیازها.")
@@ -208,6 +339,7 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 			}
 			teamKeyboardRows = append(teamKeyboardRows, row)
 		}
+		teamKeyboardRows = append(teamKeyboardRows, specialTaskOptionRows()...)
 		msg := tgbotapi.NewMessage(chatID, "لطفا تیم مسئول این وظیفه را انتخاب کنید:")
 		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(teamKeyboardRows...)
 		if _, errSend := bot.Send(msg); errSend != nil {
@@ -229,17 +361,152 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 		}
 		keyboard := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("بله", "set_final_true"),
-				tgbotapi.NewInlineKeyboardButtonData("خیر", "set_final_false"),
+				tgbotapi.NewInlineKeyboardButtonData("یک نفر از تیم", "set_multi_none"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("همه‌ی اعضای تیم، هر کدام جداگانه", "set_multi_members"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("یک بار برای هر مورد از یک فهرست", "set_multi_list"),
 			),
 		)
-		msg := tgbotapi.NewMessage(chatID, "آیا این وظیفه پایانی است؟")
+		msg := tgbotapi.NewMessage(chatID, "این وظیفه چگونه انجام شود؟")
 		msg.ReplyMarkup = keyboard
 		if _, errSend := bot.Send(msg); errSend != nil {
-			log.Printf("Error sending final task confirmation: %v", errSend)
+			log.Printf("Error sending multi-instance selection: %v", errSend)
 		}
 		callbackMsg = "تیم انتخاب شد"
 
+	case strings.HasPrefix(data, "set_multi_"):
+		mode := models.MultiInstanceMode(strings.TrimPrefix(data, "set_multi_"))
+		if mode == "none" {
+			mode = models.MultiInstanceNone
+		}
+		if !h.taskBuilderService.SetMultiInstance(userID, mode) {
+			sendMessage(chatID, "خطا در تنظیم نحوه‌ی انجام وظیفه.")
+			callbackMsg = "خطا"
+			break
+		}
+		switch mode {
+		case models.MultiInstanceMembers:
+			askCompletionThreshold(bot, chatID)
+		case models.MultiInstanceList:
+			sendMessage(chatID, "نام متغیری که فهرست موارد را نگه می‌دارد وارد کنید (موارد در هر خط یا با ویرگول جدا شده‌اند):")
+		default:
+			askIsFinal(bot, chatID)
+		}
+		callbackMsg = "نحوه‌ی انجام انتخاب شد"
+
+	case strings.HasPrefix(data, "set_threshold_"):
+		threshold, err := strconv.Atoi(strings.TrimPrefix(data, "set_threshold_"))
+		if err != nil || !h.taskBuilderService.SetCompletionThreshold(userID, threshold) {
+			sendMessage(chatID, "خطا در تنظیم حد نصاب تکمیل.")
+			callbackMsg = "خطا"
+			break
+		}
+		askIsFinal(bot, chatID)
+		callbackMsg = "حد نصاب تنظیم شد"
+
+	case data == "select_timer_menu":
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("انتظار به مدت مشخص", "set_timer_duration"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("انتظار تا تاریخ یک متغیر", "set_timer_until_variable"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("انتظار تا ساعت کاری", "set_timer_business_hours"),
+			),
+		)
+		msg := tgbotapi.NewMessage(chatID, "این وظیفه تا چه زمانی منتظر بماند؟ پس از آن به طور خودکار تکمیل می‌شود.")
+		msg.ReplyMarkup = keyboard
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending timer selection: %v", errSend)
+		}
+		callbackMsg = "انتخاب زمان‌سنج"
+
+	case data == "select_webhook":
+		if !h.taskBuilderService.SetWebhook(userID) {
+			sendMessage(chatID, "خطا در تنظیم وب‌هوک برای وظیفه.")
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, "آدرس وب‌هوک را وارد کنید. اطلاعات اجرا و متغیرهای فرایند به صورت JSON به این آدرس ارسال می‌شود و فیلدهای پاسخ JSON به متغیرهای فرایند افزوده می‌شوند:")
+		callbackMsg = "وظیفه خودکار"
+
+	case strings.HasPrefix(data, "set_timer_"):
+		timerType := models.TimerType(strings.TrimPrefix(data, "set_timer_"))
+		if !h.taskBuilderService.SetTimer(userID, timerType) {
+			sendMessage(chatID, "خطا در تنظیم زمان‌سنج برای وظیفه.")
+			callbackMsg = "خطا"
+			break
+		}
+		switch timerType {
+		case models.TimerTypeUntilVariable:
+			sendMessage(chatID, "نام متغیری که تاریخ (2025-01-31) یا تاریخ و ساعت (2025-01-31 14:30) را نگه می‌دارد وارد کنید:")
+		case models.TimerTypeBusinessHours:
+			sendMessage(chatID, "پس از چه مدتی در اولین ساعت کاری ادامه دهد؟ (مثلا «1 روز»، «4 ساعت» یا 0 برای اولین ساعت کاری)")
+		default:
+			sendMessage(chatID, "مدت انتظار را وارد کنید (مثلا «3 روز»، «4 ساعت» یا «30 دقیقه»):")
+		}
+		callbackMsg = "زمان‌سنج انتخاب شد"
+
+	case data == "select_subprocess_menu":
+		builder, exists := h.taskBuilderService.GetBuilder(userID)
+		if !exists || builder.CurrentStep != "team" {
+			sendMessage(chatID, "ابتدا ساخت وظیفه را شروع کنید.")
+			callbackMsg = "خطا"
+			break
+		}
+		processes, err := h.processService.GetProcessesByUserID(userID)
+		if err != nil {
+			sendMessage(chatID, "خطا در دریافت فرآیندها. لطفا دوباره تلاش کنید.")
+			callbackMsg = "خطا"
+			break
+		}
+		var keyboardRows [][]tgbotapi.InlineKeyboardButton
+		for _, process := range processes {
+			if process.ID == builder.ProcessID {
+				continue
+			}
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(process.Name, fmt.Sprintf("select_subprocess_%d", process.ID)),
+			))
+		}
+		if len(keyboardRows) == 0 {
+			sendMessage(chatID, "فرایند دیگری برای اجرا به عنوان زیرفرایند ندارید.")
+			callbackMsg = "فرایندی یافت نشد"
+			break
+		}
+		msg := tgbotapi.NewMessage(chatID, "این وظیفه کدام فرایند را اجرا کند و منتظر پایان آن بماند؟")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending sub-process selection: %v", errSend)
+		}
+		callbackMsg = "انتخاب زیرفرایند"
+
+	case strings.HasPrefix(data, "select_subprocess_"):
+		processID, err := strconv.ParseUint(strings.TrimPrefix(data, "select_subprocess_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه فرآیند.")
+			callbackMsg = "خطا در شناسه"
+			break
+		}
+		process, err := h.processService.GetProcessByID(uint(processID))
+		if err != nil || process.UserID != userID {
+			sendMessage(chatID, "فرایند انتخاب شده یافت نشد.")
+			callbackMsg = "فرایند یافت نشد"
+			break
+		}
+		if !h.taskBuilderService.SetSubProcess(userID, process.ID) {
+			sendMessage(chatID, "خطا در تنظیم زیرفرایند برای وظیفه.")
+			callbackMsg = "خطا در تنظیم زیرفرایند"
+			break
+		}
+		sendMessage(chatID, fmt.Sprintf("این وظیفه فرایند «%s» را اجرا می‌کند.\n\nمتغیرهایی که به زیرفرایند داده می‌شوند را در هر خط به صورت «نام=نام در زیرفرایند» بنویسید، یا برای انتقال همه‌ی متغیرها skip را بفرستید:", process.Name))
+		callbackMsg = "زیرفرایند انتخاب شد"
+
 	case strings.HasPrefix(data, "set_final_"):
 		isFinal := strings.HasSuffix(data, "true")
 		if !h.taskBuilderService.SetIsFinal(userID, isFinal) {
@@ -247,25 +514,47 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 			callbackMsg = "خطا در وضعیت نهایی"
 			break
 		}
-		task, prerequisites, success := h.taskBuilderService.CompleteTask(userID)
-		if !success {
-			sendMessage(chatID, "خطا در تکمیل ایجاد وظیفه.")
-			callbackMsg = "خطا در تکمیل وظیفه"
+		// Call activities, timers and service tasks are completed by the engine, so they have no deadline of their own
+		if builder, exists := h.taskBuilderService.GetBuilder(userID); exists && (builder.Task.IsSubProcess() || builder.Task.IsTimer() || builder.Task.IsService()) {
+			if h.finishTaskCreation(chatID, userID, sendMessage) {
+				callbackMsg = "وظیفه ایجاد شد"
+			} else {
+				callbackMsg = "خطا در تکمیل وظیفه"
+			}
 			break
 		}
-		if err := h.taskService.CreateTask(task); err != nil {
-			sendMessage(chatID, fmt.Sprintf("خطا در ذخیره وظیفه: %s", err.Error()))
-			callbackMsg = "خطا در ذخیره وظیفه"
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("بدون مهلت", "set_due_0"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("۴ ساعت", "set_due_4"),
+				tgbotapi.NewInlineKeyboardButtonData("۱ روز", "set_due_24"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("۳ روز", "set_due_72"),
+				tgbotapi.NewInlineKeyboardButtonData("۱ هفته", "set_due_168"),
+			),
+		)
+		msg := tgbotapi.NewMessage(chatID, "مهلت انجام این وظیفه پس از فعال شدن چقدر است؟")
+		msg.ReplyMarkup = keyboard
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending due selection: %v", errSend)
+		}
+		callbackMsg = "انتخاب مهلت"
+
+	case strings.HasPrefix(data, "set_due_"):
+		dueHours, err := strconv.Atoi(strings.TrimPrefix(data, "set_due_"))
+		if err != nil || !h.taskBuilderService.SetDueHours(userID, dueHours) {
+			sendMessage(chatID, "خطا در تنظیم مهلت وظیفه.")
+			callbackMsg = "خطا در مهلت"
 			break
 		}
-		for _, prereqID := range prerequisites {
-			if err := h.taskService.AddPrerequisite(task.ID, prereqID); err != nil {
-				sendMessage(chatID, fmt.Sprintf("خطا در افزودن پیش‌نیاز %d به وظیفه %d: %s", prereqID, task.ID, err.Error()))
-				log.Printf("Error adding prerequisite %d to task %d: %v", prereqID, task.ID, err)
-			}
+		if h.finishTaskCreation(chatID, userID, sendMessage) {
+			callbackMsg = "وظیفه ایجاد شد"
+		} else {
+			callbackMsg = "خطا در تکمیل وظیفه"
 		}
-		sendMessage(chatID, fmt.Sprintf("وظیفه '%s' با موفقیت ایجاد شد.", task.Title))
-		callbackMsg = "وظیفه ایجاد شد"
 
 	case strings.HasPrefix(data, "take_task_"):
 		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "take_task_"), 10, 64)
@@ -274,24 +563,300 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 			callbackMsg = "خطای شناسه"
 			break
 		}
+		// Tasks claimed in a team's group go on in the member's private chat, so the group only sees the edited announcement
+		replyChatID := chatID
+		if !update.CallbackQuery.Message.Chat.IsPrivate() {
+			if team, err := h.teamService.GetTeamByGroupChatID(chatID); err == nil {
+				if _, err := h.teamService.AddGroupMember(team, userID); err != nil {
+					log.Printf("Error adding user %d to team %d of group %d: %v", userID, team.ID, chatID, err)
+				}
+			}
+			replyChatID = userID
+		}
 		err = h.taskService.AssignTask(uint(taskExecutionID), userID)
 		if err != nil {
-			sendMessage(chatID, "خطا در به عهده گرفتن وظیفه: "+err.Error())
+			sendMessage(replyChatID, "خطا در به عهده گرفتن وظیفه: "+err.Error())
 			callbackMsg = "خطا در تخصیص"
 			break
 		}
-		var keyboardRows [][]tgbotapi.InlineKeyboardButton
-		row := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(
-			"تکمیل وظیفه",
-			fmt.Sprintf("complete_task_%d", taskExecutionID),
-		)}
-		keyboardRows = append(keyboardRows, row)
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
-		msg := tgbotapi.NewMessage(chatID, "وظیفه با موفقیت به شما اختصاص داده شد.\n\nهنگامی که وظیفه را انجام دادید روی دکمه «تکمیل وظیفه» کلیک کنید.")
-		msg.ReplyMarkup = keyboard
+		sendAssignedTask(bot, replyChatID, uint(taskExecutionID), "وظیفه با موفقیت به شما اختصاص داده شد.")
+		h.sendEarlierAttachments(bot, replyChatID, uint(taskExecutionID))
+		callbackMsg = "وظیفه تخصیص داده شد"
+		if replyChatID != chatID {
+			callbackMsg = "وظیفه به شما تخصیص داده شد؛ ادامه‌ی کار در گفتگوی خصوصی با ربات است"
+		}
+
+	case strings.HasPrefix(data, "my_tasks_"):
+		if err := h.handleMyTasksPage(bot, update.CallbackQuery, strings.TrimPrefix(data, "my_tasks_")); err != nil {
+			log.Printf("Error paging tasks of user %d: %v", userID, err)
+			sendMessage(chatID, "خطا در دریافت وظایف. لطفا دوباره تلاش کنید.")
+			callbackMsg = "خطا در دریافت وظایف"
+			break
+		}
+		callbackMsg = "وظایف من"
+
+	case strings.HasPrefix(data, "task_details_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "task_details_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		if err := h.sendTaskExecutionDetails(bot, chatID, userID, uint(taskExecutionID)); err != nil {
+			sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+			callbackMsg = "اجرای وظیفه یافت نشد"
+			break
+		}
+		callbackMsg = "جزئیات وظیفه"
+
+	case strings.HasPrefix(data, "release_task_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "release_task_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		if err := h.taskService.ReleaseTask(uint(taskExecutionID), userID); err != nil {
+			sendMessage(chatID, "خطا در بازگرداندن وظیفه: "+err.Error())
+			callbackMsg = "خطا در بازگرداندن"
+			break
+		}
+		sendMessage(chatID, "وظیفه به تیم بازگردانده شد و دوباره برای اعضای تیم قابل به عهده گرفتن است.")
+		callbackMsg = "وظیفه بازگردانده شد"
+
+	case strings.HasPrefix(data, "delegate_task_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "delegate_task_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(uint(taskExecutionID))
+		if err != nil || taskExec.Task.TeamID == nil {
+			sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+			callbackMsg = "اجرای وظیفه یافت نشد"
+			break
+		}
+		members, err := h.teamService.GetTeamMembers(*taskExec.Task.TeamID)
+		if err != nil {
+			sendMessage(chatID, "خطا در دریافت اعضای تیم.")
+			callbackMsg = "خطا در اعضا"
+			break
+		}
+		var memberKeyboardRows [][]tgbotapi.InlineKeyboardButton
+		for _, member := range members {
+			if member.ID == userID {
+				continue
+			}
+			row := []tgbotapi.InlineKeyboardButton{
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s %s", member.FirstName, member.LastName),
+					fmt.Sprintf("delegate_to_%d_%d", taskExecutionID, member.ID),
+				),
+			}
+			memberKeyboardRows = append(memberKeyboardRows, row)
+		}
+		if len(memberKeyboardRows) == 0 {
+			sendMessage(chatID, "هم‌تیمی دیگری برای واگذاری این وظیفه وجود ندارد.")
+			callbackMsg = "هم‌تیمی یافت نشد"
+			break
+		}
+		msg := tgbotapi.NewMessage(chatID, "وظیفه را به کدام هم‌تیمی واگذار می‌کنید؟")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(memberKeyboardRows...)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending teammate selection: %v", errSend)
+		}
+		callbackMsg = "انتخاب هم‌تیمی"
+
+	case strings.HasPrefix(data, "delegate_to_"):
+		taskExecutionID, delegateID, err := parseTaskExecutionAndUser(strings.TrimPrefix(data, "delegate_to_"))
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش درخواست واگذاری.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		if err := h.taskService.RequestDelegation(taskExecutionID, userID, delegateID); err != nil {
+			sendMessage(chatID, "خطا در واگذاری وظیفه: "+err.Error())
+			callbackMsg = "خطا در واگذاری"
+			break
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(taskExecutionID)
+		if err != nil {
+			log.Printf("Error getting task execution %d for delegation: %v", taskExecutionID, err)
+			break
+		}
+		msg := tgbotapi.NewMessage(delegateID, fmt.Sprintf("%s %s می‌خواهد وظیفه «%s» از فرایند «%s» را به شما واگذار کند. آیا می‌پذیرید؟",
+			update.CallbackQuery.From.FirstName,
+			update.CallbackQuery.From.LastName,
+			taskExec.Task.Title,
+			taskExec.Task.Process.Name))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("می‌پذیرم", fmt.Sprintf("accept_delegation_%d", taskExecutionID)),
+				tgbotapi.NewInlineKeyboardButtonData("نمی‌پذیرم", fmt.Sprintf("decline_delegation_%d", taskExecutionID)),
+			),
+		)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending delegation request to user %d: %v", delegateID, errSend)
+		}
+		sendMessage(chatID, "درخواست واگذاری ارسال شد. تا زمان پذیرش، وظیفه همچنان به عهده‌ی شماست.")
+		callbackMsg = "درخواست ارسال شد"
+
+	case strings.HasPrefix(data, "accept_delegation_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "accept_delegation_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(uint(taskExecutionID))
+		if err != nil {
+			sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+			callbackMsg = "اجرای وظیفه یافت نشد"
+			break
+		}
+		if err := h.taskService.AcceptDelegation(uint(taskExecutionID), userID); err != nil {
+			sendMessage(chatID, "خطا در پذیرش وظیفه: "+err.Error())
+			callbackMsg = "خطا در پذیرش"
+			break
+		}
+		sendAssignedTask(bot, chatID, uint(taskExecutionID), "واگذاری پذیرفته شد و وظیفه به شما اختصاص داده شد.")
+		h.sendEarlierAttachments(bot, chatID, uint(taskExecutionID))
+		if taskExec.UserID != nil {
+			h.notify(*taskExec.UserID, models.NotificationEventTaskReassigned, fmt.Sprintf("وظیفه «%s» توسط %s %s پذیرفته شد و دیگر به عهده‌ی شما نیست.",
+				taskExec.Task.Title, update.CallbackQuery.From.FirstName, update.CallbackQuery.From.LastName))
+		}
+		callbackMsg = "وظیفه پذیرفته شد"
+
+	case strings.HasPrefix(data, "decline_delegation_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "decline_delegation_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		if err := h.taskService.DeclineDelegation(uint(taskExecutionID), userID); err != nil {
+			sendMessage(chatID, "خطا در رد واگذاری: "+err.Error())
+			callbackMsg = "خطا در رد واگذاری"
+			break
+		}
+		sendMessage(chatID, "درخواست واگذاری رد شد.")
+		if taskExec, err := h.taskService.GetTaskExecutionByID(uint(taskExecutionID)); err == nil && taskExec.UserID != nil {
+			sendMessage(*taskExec.UserID, fmt.Sprintf("%s %s واگذاری وظیفه «%s» را نپذیرفت و وظیفه همچنان به عهده‌ی شماست.",
+				update.CallbackQuery.From.FirstName, update.CallbackQuery.From.LastName, taskExec.Task.Title))
+		}
+		callbackMsg = "واگذاری رد شد"
+
+	case strings.HasPrefix(data, "team_tasks_"):
+		teamID, err := strconv.ParseUint(strings.TrimPrefix(data, "team_tasks_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه تیم.")
+			callbackMsg = "خطای شناسه تیم"
+			break
+		}
+		team, err := h.teamService.GetTeamByID(uint(teamID))
+		if err != nil || !h.teamService.IsManager(team, userID) {
+			sendMessage(chatID, "فقط مالک تیم یا مدیران گروه تیم می‌توانند وظایف باز تیم را مدیریت کنند.")
+			callbackMsg = "دسترسی ندارید"
+			break
+		}
+		taskExecs, err := h.taskService.GetOpenTeamTasks(team.ID)
+		if err != nil {
+			sendMessage(chatID, "خطا در دریافت وظایف باز تیم.")
+			callbackMsg = "خطا در وظایف"
+			break
+		}
+		if len(taskExecs) == 0 {
+			sendMessage(chatID, "این تیم وظیفه‌ی بازی ندارد.")
+			callbackMsg = "وظیفه‌ای نیست"
+			break
+		}
+		var taskKeyboardRows [][]tgbotapi.InlineKeyboardButton
+		for _, taskExec := range taskExecs {
+			assignee := "در انتظار"
+			if taskExec.User != nil {
+				assignee = fmt.Sprintf("%s %s", taskExec.User.FirstName, taskExec.User.LastName)
+			}
+			row := []tgbotapi.InlineKeyboardButton{
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s %s - %s", taskExec.Task.Title, processExecutionName(&taskExec), assignee),
+					fmt.Sprintf("reassign_task_%d", taskExec.ID),
+				),
+			}
+			taskKeyboardRows = append(taskKeyboardRows, row)
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("وظایف باز تیم %s؛ برای تخصیص مجدد روی وظیفه بزنید:", team.Name))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(taskKeyboardRows...)
+		if _, errSend := bot.Send(msg); errSend != nil {
+			log.Printf("Error sending team open tasks: %v", errSend)
+		}
+		callbackMsg = "وظایف باز تیم"
+
+	case strings.HasPrefix(data, "reassign_task_"):
+		taskExecutionID, err := strconv.ParseUint(strings.TrimPrefix(data, "reassign_task_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش شناسه وظیفه در حال اجرا.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(uint(taskExecutionID))
+		if err != nil || taskExec.Task.TeamID == nil {
+			sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+			callbackMsg = "اجرای وظیفه یافت نشد"
+			break
+		}
+		members, err := h.teamService.GetTeamMembers(*taskExec.Task.TeamID)
+		if err != nil {
+			sendMessage(chatID, "خطا در دریافت اعضای تیم.")
+			callbackMsg = "خطا در اعضا"
+			break
+		}
+		var memberKeyboardRows [][]tgbotapi.InlineKeyboardButton
+		for _, member := range members {
+			row := []tgbotapi.InlineKeyboardButton{
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s %s", member.FirstName, member.LastName),
+					fmt.Sprintf("reassign_to_%d_%d", taskExecutionID, member.ID),
+				),
+			}
+			memberKeyboardRows = append(memberKeyboardRows, row)
+		}
+		if len(memberKeyboardRows) == 0 {
+			sendMessage(chatID, "این تیم عضوی ندارد.")
+			callbackMsg = "عضوی یافت نشد"
+			break
+		}
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("وظیفه «%s» به چه کسی تخصیص داده شود؟", taskExec.Task.Title))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(memberKeyboardRows...)
 		if _, errSend := bot.Send(msg); errSend != nil {
-			log.Printf("Error sending complete task button: %v", errSend)
+			log.Printf("Error sending member selection: %v", errSend)
+		}
+		callbackMsg = "انتخاب عضو"
+
+	case strings.HasPrefix(data, "reassign_to_"):
+		taskExecutionID, newUserID, err := parseTaskExecutionAndUser(strings.TrimPrefix(data, "reassign_to_"))
+		if err != nil {
+			sendMessage(chatID, "خطا در پردازش درخواست تخصیص مجدد.")
+			callbackMsg = "خطای شناسه"
+			break
+		}
+		taskExec, err := h.taskService.GetTaskExecutionByID(taskExecutionID)
+		if err != nil {
+			sendMessage(chatID, "خطا: اطلاعات اجرای وظیفه یافت نشد.")
+			callbackMsg = "اجرای وظیفه یافت نشد"
+			break
+		}
+		if err := h.taskService.ReassignTask(taskExecutionID, userID, newUserID); err != nil {
+			sendMessage(chatID, "خطا در تخصیص مجدد وظیفه: "+err.Error())
+			callbackMsg = "خطا در تخصیص مجدد"
+			break
 		}
+		sendAssignedTask(bot, newUserID, taskExecutionID, fmt.Sprintf("وظیفه «%s» توسط مدیر تیم به شما اختصاص داده شد.", taskExec.Task.Title))
+		h.sendEarlierAttachments(bot, newUserID, taskExecutionID)
+		if taskExec.UserID != nil && *taskExec.UserID != newUserID {
+			h.notify(*taskExec.UserID, models.NotificationEventTaskReassigned, fmt.Sprintf("وظیفه «%s» توسط مدیر تیم به فرد دیگری اختصاص داده شد و دیگر به عهده‌ی شما نیست.", taskExec.Task.Title))
+		}
+		sendMessage(chatID, "وظیفه با موفقیت تخصیص داده شد.")
 		callbackMsg = "وظیفه تخصیص داده شد"
 
 	case strings.HasPrefix(data, "complete_task_"):
@@ -308,73 +873,31 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 			callbackMsg = "اجرای وظیفه یافت نشد"
 			break
 		}
+		if taskExec.Status != models.TaskStatusAssigned || taskExec.UserID == nil || *taskExec.UserID != userID {
+			sendMessage(chatID, "خطا در تکمیل وظیفه: task is not assigned to you")
+			callbackMsg = "خطا در تکمیل"
+			break
+		}
 
-		err = h.taskService.CompleteTask(uint(taskExecID), userID)
+		fields, err := h.formService.GetFields(taskExec.TaskID)
 		if err != nil {
-			sendMessage(chatID, "خطا در تکمیل وظیفه: "+err.Error())
-			callbackMsg = "خطا در تکمیل"
+			sendMessage(chatID, "خطا در دریافت فرم وظیفه.")
+			callbackMsg = "خطا در فرم"
 			break
 		}
-		sendMessage(chatID, "وظیفه با موفقیت تکمیل شد.")
-		callbackMsg = "وظیفه تکمیل شد"
-
-		fmt.Println("taskExec.Task: ", taskExec.Task)
-		fmt.Println("taskExec.Task.Process: ", taskExec.Task.Process)
-		fmt.Println("taskExec.Task.Process.UserID: ", taskExec.Task.Process.UserID)
-		ownerID := taskExec.Task.Process.UserID
-		if ownerID != chatID {
-			sendMessage(ownerID, fmt.Sprintf(`
-				☑️ اعلان تکمیل وظیفه
-					- فرایند: %s
-					- شماره فرایند اجرایی: %s
-					- وظیفه: %s
-					- انجام دهنده: %s %s
-					- تاریخ: %s
-				`,
-				taskExec.Task.Process.Name,
-				taskExec.ProcessExecutionID,
-				taskExec.Task.Title,
-				taskExec.User.FirstName,
-				taskExec.User.LastName,
-				taskExec.CompletedAt))
-		}
-
-		if isFinal, _ := h.taskService.IsFinalTask(taskExec.TaskID); isFinal {
-			processExec, _ := h.processService.GetProcessExecutionByID(taskExec.ProcessExecutionID)
-			processExec.Status = models.ProcessExecutionStatusCompleted
-			completedTime := time.Now()
-			processExec.CompletedAt = &completedTime
-			if errUpdate := h.processService.UpdateProcessExecution(processExec); errUpdate != nil {
-				sendMessage(chatID, "خطا در بروزرسانی وضعیت نهایی فرایند.")
-				log.Printf("Error updating process execution status to completed: %v", errUpdate)
-			} else {
-				sendMessage(chatID, "فرایند والد نیز با موفقیت تکمیل شد.")
-				if ownerID != chatID {
-					sendMessage(ownerID, fmt.Sprintf(
-						`✅ اعلان تکمیل فرایند
-							- فرایند: %s
-							- شماره فرایند اجرایی: %s
-							- تاریخ: %s
-						`,
-						taskExec.Task.Process.Name,
-						taskExec.ProcessExecutionID,
-						processExec.CompletedAt))
-				}
-			}
+		if len(fields) > 0 {
+			h.formFillService.StartForm(userID, taskExec.ID, fields)
+			sendMessage(chatID, fmt.Sprintf("برای تکمیل وظیفه «%s» لطفا به پرسش‌های زیر پاسخ دهید.", taskExec.Task.Title))
+			h.askFormField(bot, chatID, userID, sendMessage)
+			callbackMsg = "تکمیل فرم وظیفه"
+			break
 		}
 
-		DependentTasks, depErr := h.taskService.GetDependentTasks(taskExec.TaskID)
-		if depErr != nil {
-			log.Printf("Error getting dependent tasks for task %d: %v", taskExec.TaskID, depErr)
+		if h.completeTaskExecution(chatID, userID, taskExec, nil, "", sendMessage) {
+			callbackMsg = "وظیفه تکمیل شد"
 		} else {
-			for _, task := range DependentTasks {
-				if _, startErr := h.taskService.StartTaskExecution(taskExec.ProcessExecutionID, task.ID); startErr != nil {
-					sendMessage(chatID, fmt.Sprintf("خطا در شروع وظیفه وابسته %s: %s", task.Title, startErr.Error()))
-					log.Printf("Error starting dependent task %d: %v", task.ID, startErr)
-				}
-			}
+			callbackMsg = "خطا در تکمیل"
 		}
-		break
 
 	case strings.HasPrefix(data, "view_task_"):
 		taskID, err := strconv.ParseUint(strings.TrimPrefix(data, "view_task_"), 10, 64)
@@ -383,15 +906,18 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 			callbackMsg = "خطای شناسه"
 			break
 		}
-		task, err := h.taskService.GetTaskByID(uint(taskID))
-		if err != nil {
+		if err := h.sendTaskDetails(bot, chatID, userID, uint(taskID)); err != nil {
 			sendMessage(chatID, "خطا در دریافت اطلاعات وظیفه.")
 			callbackMsg = "خطا در دریافت وظیفه"
 			break
 		}
-		taskDetails := fmt.Sprintf("عنوان: %s\nتوضیحات: %s", task.Title, task.Description)
-		sendMessage(chatID, taskDetails)
 		callbackMsg = "جزئیات وظیفه"
+
+	default:
+		callbackMsg = h.handleFormCallback(bot, update.CallbackQuery, sendMessage)
+		if callbackMsg == "" {
+			callbackMsg = h.handleAttachmentCallback(bot, update.CallbackQuery, sendMessage)
+		}
 	}
 
 	if callbackMsg != "" {
@@ -399,3 +925,156 @@ func (h *TaskHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.
 		bot.Request(callback)
 	}
 }
+
+// completeTaskExecution completes a task execution with the answers to its form and tells the assignee; the task service
+// tells the process owner. It reports whether the task execution was completed.
+func (h *TaskHandler) completeTaskExecution(chatID int64, userID int64, taskExec *models.TaskExecution, answers []models.TaskFormAnswer, note string, sendMessage func(chatID int64, text string)) bool {
+	if err := h.taskService.CompleteTask(taskExec.ID, userID, answers, note); err != nil {
+		sendMessage(chatID, "خطا در تکمیل وظیفه: "+err.Error())
+		return false
+	}
+	h.attachmentSessions.EndSession(userID)
+	sendMessage(chatID, "وظیفه با موفقیت تکمیل شد.")
+
+	// Completing the final task completes the process execution as well, once every required instance is done
+	if isFinal, _ := h.taskService.IsFinalTask(taskExec.TaskID); isFinal {
+		processExec, err := h.processService.GetProcessExecutionByID(taskExec.ProcessExecutionID)
+		if err != nil {
+			sendMessage(chatID, "خطا در بروزرسانی وضعیت نهایی فرایند.")
+		} else if processExec.Status == models.ProcessExecutionStatusCompleted {
+			sendMessage(chatID, "فرایند والد نیز با موفقیت تکمیل شد.")
+		}
+	}
+	return true
+}
+
+// notify queues a notification about the event for the user by their notification settings
+func (h *TaskHandler) notify(userID int64, event models.NotificationEvent, text string) {
+	if err := h.notifications.Notify(userID, event, text); err != nil {
+		log.Printf("Error notifying user %d of %s: %v", userID, event, err)
+	}
+}
+
+// finishTaskCreation saves the task built by the user along with its prerequisites.
+// It reports whether the task was saved.
+func (h *TaskHandler) finishTaskCreation(chatID int64, userID int64, sendMessage func(chatID int64, text string)) bool {
+	task, prerequisites, success := h.taskBuilderService.CompleteTask(userID)
+	if !success {
+		sendMessage(chatID, "خطا در تکمیل ایجاد وظیفه.")
+		return false
+	}
+	if err := h.taskService.CreateTask(task); err != nil {
+		sendMessage(chatID, fmt.Sprintf("خطا در ذخیره وظیفه: %s", err.Error()))
+		return false
+	}
+	for _, prerequisite := range prerequisites {
+		if err := h.taskService.AddPrerequisite(task.ID, prerequisite.PrerequisiteID, prerequisite.Condition); err != nil {
+			sendMessage(chatID, fmt.Sprintf("خطا در افزودن پیش‌نیاز %d به وظیفه %d: %s", prerequisite.PrerequisiteID, task.ID, err.Error()))
+			log.Printf("Error adding prerequisite %d to task %d: %v", prerequisite.PrerequisiteID, task.ID, err)
+		}
+	}
+	sendMessage(chatID, fmt.Sprintf("وظیفه '%s' با موفقیت ایجاد شد.", task.Title))
+	return true
+}
+
+// askPrerequisiteCondition asks for the optional branch condition of the prerequisite added last
+func askPrerequisiteCondition(bot *tgbotapi.BotAPI, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "پیش‌نیاز افزوده شد. اگر این وظیفه فقط در صورت برقراری شرطی پس از آن شروع شود، شرط را بفرستید؛ مثلا {{amount}} > 1000 یا {{approved}} == true. در غیر این صورت «بدون شرط» را بزنید.")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("بدون شرط", "no_prerequisite_condition"),
+		),
+	)
+	if _, err := bot.Send(msg); err != nil {
+		log.Printf("Error sending prerequisite condition prompt: %v", err)
+	}
+}
+
+func askIsFinal(bot *tgbotapi.BotAPI, chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("بله", "set_final_true"),
+			tgbotapi.NewInlineKeyboardButtonData("خیر", "set_final_false"),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, "آیا این وظیفه پایانی است؟")
+	msg.ReplyMarkup = keyboard
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending final task confirmation: %v", errSend)
+	}
+}
+
+func askCompletionThreshold(bot *tgbotapi.BotAPI, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, "با تکمیل چند نمونه این مرحله تمام شود؟ عدد را وارد کنید یا «همه» را بزنید:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("همه", "set_threshold_0"),
+		),
+	)
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending completion threshold question: %v", errSend)
+	}
+}
+
+// specialTaskOptionRows offers running another process, waiting for a time or calling a webhook instead of choosing a team
+func specialTaskOptionRows() [][]tgbotapi.InlineKeyboardButton {
+	return [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔗 اجرای یک فرایند دیگر (زیرفرایند)", "select_subprocess_menu"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏱ انتظار (زمان‌سنج)", "select_timer_menu"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌐 فراخوانی وب‌هوک (خودکار)", "select_webhook"),
+		),
+	}
+}
+
+// parseVariableMapping reads a variable mapping sent by the user, where "skip" keeps every variable
+func parseVariableMapping(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if strings.EqualFold(text, "skip") {
+		return "", true
+	}
+	return text, service.ValidVariableMapping(text)
+}
+
+// sendAssignedTask sends the actions available to the assignee of a task execution
+func sendAssignedTask(bot *tgbotapi.BotAPI, chatID int64, taskExecutionID uint, text string) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("تکمیل وظیفه", fmt.Sprintf("complete_task_%d", taskExecutionID)),
+			tgbotapi.NewInlineKeyboardButtonData("📎 افزودن پیوست", fmt.Sprintf("attach_task_%d", taskExecutionID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("بازگرداندن به تیم", fmt.Sprintf("release_task_%d", taskExecutionID)),
+			tgbotapi.NewInlineKeyboardButtonData("واگذاری به هم‌تیمی", fmt.Sprintf("delegate_task_%d", taskExecutionID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💬 نظر", fmt.Sprintf("comment_task_%d", taskExecutionID)),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, text+"\n\nهنگامی که وظیفه را انجام دادید روی دکمه «تکمیل وظیفه» کلیک کنید.")
+	msg.ReplyMarkup = keyboard
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending complete task button: %v", errSend)
+	}
+}
+
+// parseTaskExecutionAndUser parses callback payloads in the form "<taskExecutionID>_<userID>"
+func parseTaskExecutionAndUser(payload string) (uint, int64, error) {
+	parts := strings.SplitN(payload, "_", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid payload: %s", payload)
+	}
+	taskExecutionID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	userID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint(taskExecutionID), userID, nil
+}
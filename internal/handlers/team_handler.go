@@ -176,7 +176,23 @@ func (h *TeamHandler) HandleTeamCallback(bot *tgbotapi.BotAPI, update tgbotapi.U
 								member.Username))
 						}
 					}
-					sendMessage(chatID, membersList.String())
+					if team.GroupChatID != nil {
+						membersList.WriteString(fmt.Sprintf("\nگروه تیم: %s\n", team.GroupTitle))
+					}
+					if h.teamService.IsManager(team, update.CallbackQuery.From.ID) {
+						msg := tgbotapi.NewMessage(chatID, membersList.String())
+						msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+							tgbotapi.NewInlineKeyboardRow(
+								tgbotapi.NewInlineKeyboardButtonData("وظایف باز تیم", fmt.Sprintf("team_tasks_%d", team.ID)),
+								tgbotapi.NewInlineKeyboardButtonData("📈 عملکرد تیم", fmt.Sprintf("team_report_%d", team.ID)),
+							),
+						)
+						if _, errSend := bot.Send(msg); errSend != nil {
+							log.Printf("Error sending team details: %v", errSend)
+						}
+					} else {
+						sendMessage(chatID, membersList.String())
+					}
 					callbackMsg = "اطلاعات تیم نمایش داده شد"
 				}
 			}
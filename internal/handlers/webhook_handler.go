@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bbb/internal/models"
+	service "bbb/internal/services"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookDeliveryLogSize is the number of deliveries shown in the delivery log of a subscription
+const webhookDeliveryLogSize = 10
+
+// WebhookHandler lets process and team owners subscribe webhooks to lifecycle events and inspect their deliveries.
+type WebhookHandler struct {
+	eventService    service.EventService
+	webhookSessions *service.WebhookSessionService
+	processService  service.ProcessService
+	teamService     service.TeamService
+	location        *time.Location
+}
+
+// NewWebhookHandler creates a new WebhookHandler.
+func NewWebhookHandler(
+	eventService service.EventService,
+	webhookSessions *service.WebhookSessionService,
+	processService service.ProcessService,
+	teamService service.TeamService,
+	location *time.Location,
+) *WebhookHandler {
+	if location == nil {
+		location = time.Local
+	}
+	return &WebhookHandler{
+		eventService:    eventService,
+		webhookSessions: webhookSessions,
+		processService:  processService,
+		teamService:     teamService,
+		location:        location,
+	}
+}
+
+// HandleWebhookCommands handles the /webhooks command and the URL of a new subscription.
+func (h *WebhookHandler) HandleWebhookCommands(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if update.Message.Text == "/webhooks" {
+		h.webhookSessions.EndSession(userID)
+		h.sendSubscriptionList(bot, chatID, userID)
+		return
+	}
+
+	subscription, exists := h.webhookSessions.GetSession(userID)
+	if !exists {
+		return
+	}
+	h.webhookSessions.EndSession(userID)
+	subscription.URL = strings.TrimSpace(update.Message.Text)
+	if err := h.eventService.Subscribe(&subscription); err != nil {
+		sendMessage(chatID, "خطا در ثبت وب‌هوک: "+err.Error())
+		return
+	}
+	sendMessage(chatID, fmt.Sprintf("✅ وب‌هوک ثبت شد.\n\nرویدادها به صورت JSON با روش POST به این آدرس ارسال می‌شوند و بدنه هر درخواست با HMAC-SHA256 و کلید زیر در سرآیند %s امضا می‌شود. این کلید فقط همین یک بار نمایش داده می‌شود:\n\n%s",
+		service.WebhookSignatureHeader, subscription.Secret))
+}
+
+// HandleWebhookCallback handles callback queries of the webhook subscription list.
+func (h *WebhookHandler) HandleWebhookCallback(bot *tgbotapi.BotAPI, update tgbotapi.Update, sendMessage func(chatID int64, text string)) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	data := update.CallbackQuery.Data
+	userID := update.CallbackQuery.From.ID
+	chatID := update.CallbackQuery.Message.Chat.ID
+	callbackMsg := ""
+
+	switch {
+	case data == "webhook_new_process":
+		processes, err := h.processService.GetProcessesByUserID(userID)
+		if err != nil || len(processes) == 0 {
+			sendMessage(chatID, "فرایندی برای ثبت وب‌هوک ندارید.")
+			callbackMsg = "فرایندی یافت نشد"
+			break
+		}
+		var keyboardRows [][]tgbotapi.InlineKeyboardButton
+		for _, process := range processes {
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(process.Name, fmt.Sprintf("webhook_process_%d", process.ID)),
+			))
+		}
+		h.sendKeyboard(bot, chatID, "رویدادهای کدام فرایند ارسال شوند؟", keyboardRows)
+		callbackMsg = "انتخاب فرایند"
+
+	case data == "webhook_new_team":
+		teams, err := h.teamService.GetTeamsByOwnerID(userID)
+		if err != nil || len(teams) == 0 {
+			sendMessage(chatID, "تیمی برای ثبت وب‌هوک ندارید.")
+			callbackMsg = "تیمی یافت نشد"
+			break
+		}
+		var keyboardRows [][]tgbotapi.InlineKeyboardButton
+		for _, team := range teams {
+			keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(team.Name, fmt.Sprintf("webhook_team_%d", team.ID)),
+			))
+		}
+		h.sendKeyboard(bot, chatID, "رویدادهای وظایف کدام تیم ارسال شوند؟", keyboardRows)
+		callbackMsg = "انتخاب تیم"
+
+	case strings.HasPrefix(data, "webhook_process_"), strings.HasPrefix(data, "webhook_team_"):
+		isProcess := strings.HasPrefix(data, "webhook_process_")
+		id, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(data, "webhook_process_"), "webhook_team_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "شناسه نامعتبر است.")
+			callbackMsg = "خطا"
+			break
+		}
+		targetID := uint(id)
+		subscription := models.WebhookSubscription{OwnerID: userID}
+		if isProcess {
+			subscription.ProcessID = &targetID
+		} else {
+			subscription.TeamID = &targetID
+		}
+		h.webhookSessions.StartSession(userID, subscription)
+		sendMessage(chatID, "آدرس وب‌هوک را وارد کنید (با http:// یا https://):")
+		callbackMsg = "آدرس وب‌هوک"
+
+	case strings.HasPrefix(data, "webhook_log_"):
+		subscriptionID, err := strconv.ParseUint(strings.TrimPrefix(data, "webhook_log_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "شناسه وب‌هوک نامعتبر است.")
+			callbackMsg = "خطا"
+			break
+		}
+		deliveries, err := h.eventService.GetDeliveries(uint(subscriptionID), userID, webhookDeliveryLogSize)
+		if err != nil {
+			sendMessage(chatID, "خطا: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, h.deliveryLogText(uint(subscriptionID), deliveries))
+		callbackMsg = "گزارش ارسال"
+
+	case strings.HasPrefix(data, "webhook_delete_"):
+		subscriptionID, err := strconv.ParseUint(strings.TrimPrefix(data, "webhook_delete_"), 10, 64)
+		if err != nil {
+			sendMessage(chatID, "شناسه وب‌هوک نامعتبر است.")
+			callbackMsg = "خطا"
+			break
+		}
+		if err := h.eventService.DeleteSubscription(uint(subscriptionID), userID); err != nil {
+			sendMessage(chatID, "خطا در حذف وب‌هوک: "+err.Error())
+			callbackMsg = "خطا"
+			break
+		}
+		sendMessage(chatID, "وب‌هوک حذف شد و دیگر رویدادی برای آن ارسال نمی‌شود.")
+		callbackMsg = "وب‌هوک حذف شد"
+	}
+
+	if callbackMsg != "" {
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, callbackMsg)
+		if _, err := bot.Request(callback); err != nil {
+			log.Printf("Error answering callback query: %v", err)
+		}
+	}
+}
+
+// sendSubscriptionList shows the webhook subscriptions of a user with buttons to inspect, delete or add them
+func (h *WebhookHandler) sendSubscriptionList(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	subscriptions, err := h.eventService.GetSubscriptions(userID)
+	if err != nil {
+		h.sendKeyboard(bot, chatID, "خطا در دریافت وب‌هوک‌ها. لطفا دوباره تلاش کنید.", nil)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("🌐 وب‌هوک‌های رویداد\nرویدادهای شروع، تکمیل و لغو فرایند و فعال شدن، به عهده گرفتن، تکمیل و تاخیر وظایف به آدرس‌های زیر ارسال می‌شوند.\n")
+	if len(subscriptions) == 0 {
+		text.WriteString("\nهنوز وب‌هوکی ثبت نکرده‌اید.")
+	}
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	for _, subscription := range subscriptions {
+		text.WriteString(fmt.Sprintf("\n#%d %s\n   %s", subscription.ID, subscriptionTargetTitle(&subscription), subscription.URL))
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 گزارش ارسال #%d", subscription.ID), fmt.Sprintf("webhook_log_%d", subscription.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 حذف #%d", subscription.ID), fmt.Sprintf("webhook_delete_%d", subscription.ID)),
+		))
+	}
+	keyboardRows = append(keyboardRows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ وب‌هوک برای یک فرایند", "webhook_new_process")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ وب‌هوک برای وظایف یک تیم", "webhook_new_team")),
+	)
+	h.sendKeyboard(bot, chatID, text.String(), keyboardRows)
+}
+
+// deliveryLogText lists the latest deliveries of a subscription
+func (h *WebhookHandler) deliveryLogText(subscriptionID uint, deliveries []models.WebhookDelivery) string {
+	if len(deliveries) == 0 {
+		return fmt.Sprintf("هنوز رویدادی برای وب‌هوک #%d ارسال نشده است.", subscriptionID)
+	}
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("📜 آخرین ارسال‌های وب‌هوک #%d:\n", subscriptionID))
+	for _, delivery := range deliveries {
+		text.WriteString(fmt.Sprintf("\n%s %s - %s", deliveryStatusIcon(delivery.Status), delivery.Event, formatTime(&delivery.CreatedAt, h.location)))
+		if delivery.Attempts > 0 {
+			text.WriteString(fmt.Sprintf("\n   تلاش‌ها: %d", delivery.Attempts))
+		}
+		if delivery.ResponseStatus != 0 {
+			text.WriteString(fmt.Sprintf("، پاسخ: %d", delivery.ResponseStatus))
+		}
+		if delivery.Status == models.WebhookDeliveryStatusPending && delivery.NextAttemptAt != nil && delivery.Attempts > 0 {
+			text.WriteString(fmt.Sprintf("\n   تلاش بعدی: %s", formatTime(delivery.NextAttemptAt, h.location)))
+		}
+		if delivery.LastError != "" {
+			text.WriteString("\n   خطا: " + delivery.LastError)
+		}
+	}
+	return text.String()
+}
+
+func (h *WebhookHandler) sendKeyboard(bot *tgbotapi.BotAPI, chatID int64, text string, keyboardRows [][]tgbotapi.InlineKeyboardButton) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	if len(keyboardRows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
+	}
+	if _, errSend := bot.Send(msg); errSend != nil {
+		log.Printf("Error sending webhook message: %v", errSend)
+	}
+}
+
+func subscriptionTargetTitle(subscription *models.WebhookSubscription) string {
+	switch {
+	case subscription.Process != nil:
+		return "فرایند: " + subscription.Process.Name
+	case subscription.Team != nil:
+		return "تیم: " + subscription.Team.Name
+	}
+	return "-"
+}
+
+func deliveryStatusIcon(status models.WebhookDeliveryStatus) string {
+	switch status {
+	case models.WebhookDeliveryStatusDelivered:
+		return "✅"
+	case models.WebhookDeliveryStatusFailed:
+		return "❌"
+	}
+	return "⏳"
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// APIToken lets other systems call the HTTP API on behalf of a user. Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int64      `gorm:"type:bigint;index" json:"user_id"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Prefix     string     `gorm:"type:varchar(12)" json:"prefix"` // The first characters of the token, to tell tokens apart
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type (
+	// AttachmentType represents the kind of Telegram/Bale file an attachment is
+	AttachmentType string
+
+	// TaskAttachment is a file sent by the assignee of a task execution as evidence of the work
+	TaskAttachment struct {
+		ID              uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+		TaskExecutionID uint           `gorm:"index" json:"task_execution_id"`
+		UserID          int64          `gorm:"type:bigint;index" json:"user_id"`
+		Type            AttachmentType `gorm:"type:varchar(20);not null" json:"type"`
+		FileID          string         `gorm:"type:varchar(255);not null" json:"file_id"`
+		FileUniqueID    string         `gorm:"type:varchar(255)" json:"file_unique_id"`
+		FileName        string         `gorm:"type:varchar(255)" json:"file_name"`
+		MimeType        string         `gorm:"type:varchar(100)" json:"mime_type"`
+		FileSize        int            `json:"file_size"`
+		Caption         string         `gorm:"type:text" json:"caption"`
+		CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	}
+)
+
+const (
+	AttachmentTypeDocument AttachmentType = "document"
+	AttachmentTypePhoto    AttachmentType = "photo"
+	AttachmentTypeVoice    AttachmentType = "voice"
+	AttachmentTypeAudio    AttachmentType = "audio"
+	AttachmentTypeVideo    AttachmentType = "video"
+)
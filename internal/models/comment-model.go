@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Comment is a message posted in the discussion of a process execution, or of one of its task executions
+type Comment struct {
+	ID                 uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProcessExecutionID uint      `gorm:"index" json:"process_execution_id"`
+	TaskExecutionID    *uint     `gorm:"index" json:"task_execution_id"` // Nil for comments on the process execution itself
+	UserID             int64     `gorm:"type:bigint;index" json:"user_id"`
+	User               *User     `json:"user"`
+	Text               string    `gorm:"type:text;not null" json:"text"`
+	CreatedAt          time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
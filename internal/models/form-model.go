@@ -0,0 +1,81 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type (
+	// FormFieldType represents the kind of input a form field accepts
+	FormFieldType string
+
+	// TaskFormField is one input the completer of a task, or the starter of a process, is asked to fill
+	TaskFormField struct {
+		ID        uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+		TaskID    uint          `gorm:"index" json:"task_id"`
+		ProcessID uint          `gorm:"index" json:"process_id"` // Set instead of TaskID for fields of the start form of a process
+		Position  int           `json:"position"`
+		Label     string        `gorm:"type:varchar(100);not null" json:"label"`
+		Type      FormFieldType `gorm:"type:varchar(20);not null" json:"type"`
+		Options   string        `gorm:"type:text" json:"options"`          // Choices of a choice field, one per line
+		Variable  string        `gorm:"type:varchar(100)" json:"variable"` // Process variable the answer is stored in, the label when empty
+		Required  bool          `gorm:"default:true" json:"required"`
+		CreatedAt time.Time     `gorm:"autoCreateTime" json:"created_at"`
+		UpdatedAt time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+	}
+
+	// TaskFormAnswer is the value given to a form field when completing a task execution
+	TaskFormAnswer struct {
+		ID              uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+		TaskExecutionID uint           `gorm:"index" json:"task_execution_id"`
+		FieldID         uint           `gorm:"index" json:"field_id"`
+		Field           *TaskFormField `json:"field"`
+		Value           string         `gorm:"type:text" json:"value"` // The file_id for file fields
+		CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	}
+)
+
+const (
+	FormFieldTypeText   FormFieldType = "text"
+	FormFieldTypeNumber FormFieldType = "number"
+	FormFieldTypeDate   FormFieldType = "date"
+	FormFieldTypeBool   FormFieldType = "bool"
+	FormFieldTypeChoice FormFieldType = "choice"
+	FormFieldTypeFile   FormFieldType = "file"
+)
+
+// Choices returns the options of a choice field
+func (f *TaskFormField) Choices() []string {
+	var choices []string
+	for _, option := range strings.Split(f.Options, "\n") {
+		if option = strings.TrimSpace(option); option != "" {
+			choices = append(choices, option)
+		}
+	}
+	return choices
+}
+
+// VariableName returns the name of the process variable the answers of the field are stored in
+func (f *TaskFormField) VariableName() string {
+	if f.Variable != "" {
+		return f.Variable
+	}
+	return f.Label
+}
+
+// DisplayValue returns the answer in a form suitable for messages
+func (a *TaskFormAnswer) DisplayValue() string {
+	if a.Field == nil {
+		return a.Value
+	}
+	switch a.Field.Type {
+	case FormFieldTypeBool:
+		if a.Value == "true" {
+			return "بله"
+		}
+		return "خیر"
+	case FormFieldTypeFile:
+		return "📎 فایل پیوست"
+	}
+	return a.Value
+}
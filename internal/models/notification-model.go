@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+type (
+	// NotificationSettings holds how a user is told about their work: the time zone of their digest,
+	// how often the digest is sent, which events are announced instantly, left to the digest or not sent at all,
+	// and the quiet hours during which notifications are held
+	NotificationSettings struct {
+		UserID           int64                `gorm:"primaryKey;type:bigint" json:"user_id"`
+		TimeZone         string               `gorm:"type:varchar(100)" json:"time_zone"` // IANA name, empty means the bot's time zone
+		DigestFrequency  DigestFrequency      `gorm:"type:varchar(20);default:'off'" json:"digest_frequency"`
+		DigestHour       int                  `gorm:"default:8" json:"digest_hour"`
+		DigestWeekday    int                  `gorm:"default:6" json:"digest_weekday"` // Day of weekly digests, 0 is Sunday
+		NextDigestAt     *time.Time           `gorm:"index" json:"next_digest_at"`
+		LastDigestAt     *time.Time           `json:"last_digest_at"`
+		TeamTaskDelivery NotificationDelivery `gorm:"type:varchar(20);default:'instant'" json:"team_task_delivery"`
+		ProcessDelivery  NotificationDelivery `gorm:"type:varchar(20);default:'instant'" json:"process_delivery"`
+		ReassignDelivery NotificationDelivery `gorm:"type:varchar(20);default:'instant'" json:"reassign_delivery"`
+		ReminderDelivery NotificationDelivery `gorm:"type:varchar(20);default:'instant'" json:"reminder_delivery"`
+		QuietHours       bool                 `gorm:"default:false" json:"quiet_hours"`
+		QuietStart       int                  `gorm:"default:22" json:"quiet_start"` // Hour quiet hours begin, in the user's time zone
+		QuietEnd         int                  `gorm:"default:7" json:"quiet_end"`    // Hour quiet hours end and held notifications are sent
+		CreatedAt        time.Time            `gorm:"autoCreateTime" json:"created_at"`
+		UpdatedAt        time.Time            `gorm:"autoUpdateTime" json:"updated_at"`
+	}
+
+	// DigestFrequency selects how often a user receives a digest of their work
+	DigestFrequency string
+
+	// NotificationDelivery selects whether an event is announced as it happens or only listed in the digest
+	NotificationDelivery string
+
+	// NotificationEvent is a kind of event users are notified about
+	NotificationEvent string
+)
+
+const (
+	DigestOff    DigestFrequency = "off"
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+const (
+	NotificationInstant NotificationDelivery = "instant"
+	NotificationDigest  NotificationDelivery = "digest"
+	NotificationOff     NotificationDelivery = "off"
+)
+
+const (
+	NotificationEventTeamTask         NotificationEvent = "team_task"         // A task is waiting to be claimed in one of the user's teams
+	NotificationEventProcessCompleted NotificationEvent = "process_completed" // A task or the whole execution of the user's process finished
+	NotificationEventTaskReassigned   NotificationEvent = "task_reassigned"   // A task of the user was handed to someone else
+	NotificationEventReminder         NotificationEvent = "reminder"          // A task of the user or their team passed its deadline
+)
+
+// NotificationEvents lists the events users choose how to receive, in the order of the settings menu
+var NotificationEvents = []NotificationEvent{
+	NotificationEventTeamTask,
+	NotificationEventTaskReassigned,
+	NotificationEventProcessCompleted,
+	NotificationEventReminder,
+}
+
+// InDigest reports whether the digest lists what the event is about, so the event can be left to the digest
+func (e NotificationEvent) InDigest() bool {
+	return e != NotificationEventTaskReassigned
+}
+
+// Delivery returns how the user wants to hear about an event. Events are only left to the digest while a digest is sent.
+func (s *NotificationSettings) Delivery(event NotificationEvent) NotificationDelivery {
+	delivery := *s.deliveryField(event)
+	if delivery == "" || (delivery == NotificationDigest && (s.DigestFrequency == DigestOff || s.DigestFrequency == "")) {
+		return NotificationInstant
+	}
+	return delivery
+}
+
+// SetDelivery changes how the user hears about an event
+func (s *NotificationSettings) SetDelivery(event NotificationEvent, delivery NotificationDelivery) {
+	*s.deliveryField(event) = delivery
+}
+
+func (s *NotificationSettings) deliveryField(event NotificationEvent) *NotificationDelivery {
+	switch event {
+	case NotificationEventProcessCompleted:
+		return &s.ProcessDelivery
+	case NotificationEventTaskReassigned:
+		return &s.ReassignDelivery
+	case NotificationEventReminder:
+		return &s.ReminderDelivery
+	}
+	return &s.TeamTaskDelivery
+}
+
+// InQuietHours reports whether the given hour of the user's day falls in their quiet hours, which may span midnight
+func (s *NotificationSettings) InQuietHours(hour int) bool {
+	if !s.QuietHours || s.QuietStart == s.QuietEnd {
+		return false
+	}
+	if s.QuietStart < s.QuietEnd {
+		return hour >= s.QuietStart && hour < s.QuietEnd
+	}
+	return hour >= s.QuietStart || hour < s.QuietEnd
+}
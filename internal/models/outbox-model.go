@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type (
+	OutboxStatus string
+
+	// OutboxMessage is a bot message to one recipient, stored in the same transaction as the change it announces
+	// and sent afterwards by the outbox dispatcher
+	OutboxMessage struct {
+		ID              uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+		TaskExecutionID *uint          `gorm:"index" json:"task_execution_id"` // Set for task announcements, which are recorded as task notifications once sent
+		TaskExecution   *TaskExecution `json:"task_execution"`
+		OwnerID         int64          `gorm:"type:bigint;index" json:"owner_id"` // Process owner who is told about messages that can't be delivered
+		ChatID          int64          `gorm:"type:bigint" json:"chat_id"`
+		Text            string         `gorm:"type:text" json:"text"`             // Empty for task announcements, which are rendered when sent
+		ReplyMarkup     string         `gorm:"type:text" json:"reply_markup"`     // Inline keyboard encoded as JSON
+		FileID          string         `gorm:"type:varchar(255)" json:"file_id"`  // Set for files, which are sent with the text as their caption
+		FileType        AttachmentType `gorm:"type:varchar(20)" json:"file_type"` // Empty for form files, whose kind isn't recorded
+		Status          OutboxStatus   `gorm:"type:varchar(20);index" json:"status"`
+		Attempts        int            `json:"attempts"`
+		NextAttemptAt   *time.Time     `gorm:"index" json:"next_attempt_at"`
+		LastError       string         `gorm:"type:text" json:"last_error"`
+		MessageID       int            `json:"message_id"`
+		SentAt          *time.Time     `json:"sent_at"`
+		CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`
+		UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	}
+)
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSent    OutboxStatus = "sent"
+	OutboxStatusDead    OutboxStatus = "dead"    // Gave up after repeated or permanent failures
+	OutboxStatusSkipped OutboxStatus = "skipped" // Task announcement of a task that was claimed or closed before it went out
+)
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type (
 	Process struct {
@@ -19,6 +22,8 @@ type (
 		ID                         uint                   `gorm:"primaryKey;autoIncrement" json:"id"`
 		ProcessID                  uint                   `gorm:"index" json:"process_id"`
 		Process                    *Process               `json:"process"`
+		Title                      string                 `gorm:"type:varchar(200)" json:"title"`
+		ParentTaskExecutionID      *uint                  `gorm:"index" json:"parent_task_execution_id"` // Set when the execution was started by a call activity
 		Status                     ProcessExecutionStatus `gorm:"type:varchar(50);default:'pending'" json:"status"`
 		PendingTaskExecutionIDs    []uint                 `gorm:"-" json:"pending_task_execution_ids"`
 		CompletedTaskExecutionIDs  []uint                 `gorm:"-" json:"completed_task_execution_ids"`
@@ -29,6 +34,16 @@ type (
 		UpdatedAt                  time.Time              `gorm:"autoUpdateTime" json:"updated_at"`
 	}
 
+	// ProcessVariable is a named value shared by the tasks of one process execution
+	ProcessVariable struct {
+		ID                 uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+		ProcessExecutionID uint      `gorm:"uniqueIndex:idx_process_variable_name" json:"process_execution_id"`
+		Name               string    `gorm:"type:varchar(100);uniqueIndex:idx_process_variable_name" json:"name"`
+		Value              string    `gorm:"type:text" json:"value"`
+		CreatedAt          time.Time `gorm:"autoCreateTime" json:"created_at"`
+		UpdatedAt          time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	}
+
 	// PendingTask represents a pending task execution in a process execution
 	PendingTask struct {
 		ProcessExecutionID uint      `gorm:"primaryKey;index" json:"process_execution_id"`
@@ -65,4 +80,13 @@ const (
 	ProcessExecutionStatusRunning   ProcessExecutionStatus = "running"
 	ProcessExecutionStatusCompleted ProcessExecutionStatus = "completed"
 	ProcessExecutionStatusFailed    ProcessExecutionStatus = "failed"
+	ProcessExecutionStatusCancelled ProcessExecutionStatus = "cancelled"
 )
+
+// DisplayName returns the title of the process execution along with its number
+func (e *ProcessExecution) DisplayName() string {
+	if e.Title == "" {
+		return fmt.Sprintf("#%d", e.ID)
+	}
+	return fmt.Sprintf("%s (#%d)", e.Title, e.ID)
+}
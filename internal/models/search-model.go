@@ -0,0 +1,24 @@
+package models
+
+type (
+	SearchResultKind string
+
+	// SearchResult is one match of a full-text search. ID is the record the result opens: the process, task, process
+	// execution or task execution it was found in.
+	SearchResult struct {
+		Kind    SearchResultKind
+		ID      uint
+		Title   string
+		Snippet string
+		Rank    float64
+	}
+)
+
+const (
+	SearchResultProcess          SearchResultKind = "process"
+	SearchResultTask             SearchResultKind = "task"
+	SearchResultExecution        SearchResultKind = "execution"
+	SearchResultNote             SearchResultKind = "note"              // What an assignee wrote when completing a task execution
+	SearchResultTaskComment      SearchResultKind = "task_comment"      // Opens the task execution
+	SearchResultExecutionComment SearchResultKind = "execution_comment" // Opens the process execution
+)
@@ -8,37 +8,80 @@ import (
 )
 
 type Task struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string    `gorm:"type:varchar(100);not null" json:"title"`
-	Description string    `gorm:"type:text" json:"description"`
-	ProcessID   uint      `gorm:"index" json:"process_id"`
-	Process     *Process  `json:"process"`
-	TeamID      *uint     `gorm:"index" json:"team_id"`
-	Team        *Team     `json:"team"`
-	IsFinal     bool      `gorm:"default:false" json:"is_final"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID          uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title       string   `gorm:"type:varchar(100);not null" json:"title"`
+	Description string   `gorm:"type:text" json:"description"`
+	ProcessID   uint     `gorm:"index" json:"process_id"`
+	Process     *Process `json:"process"`
+	TeamID      *uint    `gorm:"index" json:"team_id"`
+	Team        *Team    `json:"team"`
+	IsFinal     bool     `gorm:"default:false" json:"is_final"`
+	DueHours    int      `gorm:"default:0" json:"due_hours"` // Zero means the task has no deadline
+	// SubProcessID makes the task a call activity that starts an execution of another process and waits for it
+	SubProcessID      *uint  `gorm:"index" json:"sub_process_id"`
+	SubProcessInputs  string `gorm:"type:text" json:"sub_process_inputs"`  // Variable mapping into the sub-process, one "from=to" per line
+	SubProcessOutputs string `gorm:"type:text" json:"sub_process_outputs"` // Variable mapping back from the sub-process
+	// MultiInstance starts one task execution per team member or per item of a list variable instead of a single one
+	MultiInstance         MultiInstanceMode `gorm:"type:varchar(20);default:''" json:"multi_instance"`
+	MultiInstanceVariable string            `gorm:"type:varchar(100)" json:"multi_instance_variable"`
+	CompletionThreshold   int               `gorm:"default:0" json:"completion_threshold"` // Completed instances needed to finish the step, zero means all
+	// TimerType makes the task a wait that the engine completes on its own
+	TimerType     TimerType `gorm:"type:varchar(20);default:''" json:"timer_type"`
+	TimerMinutes  int       `gorm:"default:0" json:"timer_minutes"`
+	TimerVariable string    `gorm:"type:varchar(100)" json:"timer_variable"` // Date or time variable to wait for
+	// WebhookURL makes the task a service task that posts the execution to an outside system
+	WebhookURL    string    `gorm:"type:text" json:"webhook_url"`
+	WebhookSecret string    `gorm:"type:varchar(200)" json:"-"` // Key of the HMAC signature of webhook requests
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 type TaskExecution struct {
-	ID                 uint       `gorm:"primaryKey;autoIncrement" json:"id"`
-	TaskID             uint       `gorm:"index" json:"task_id"`
-	Task               *Task      `json:"task"`
-	ProcessExecutionID uint       `gorm:"index" json:"process_execution_id"`
-	Status             TaskStatus `gorm:"type:varchar(50);default:'pending'" json:"status"`
-	UserID             *int64     `gorm:"type:bigint;index" json:"user_id"`
-	User               *User      `json:"user"`
-	AssignedAt         *time.Time `json:"assigned_at"`
-	UserDescription    string     `json:"user_description"`
-	CompletedAt        *time.Time `json:"completed_at"`
-	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                 uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID             uint              `gorm:"index" json:"task_id"`
+	Task               *Task             `json:"task"`
+	ProcessExecutionID uint              `gorm:"index" json:"process_execution_id"`
+	ProcessExecution   *ProcessExecution `json:"process_execution"`
+	Status             TaskStatus        `gorm:"type:varchar(50);default:'pending'" json:"status"`
+	UserID             *int64            `gorm:"type:bigint;index" json:"user_id"`
+	User               *User             `json:"user"`
+	AssignedAt         *time.Time        `json:"assigned_at"`
+	DelegateUserID     *int64            `gorm:"type:bigint;index" json:"delegate_user_id"`
+	UserDescription    string            `json:"user_description"`
+	InstanceItem       string            `gorm:"type:text" json:"instance_item"` // The list item a multi-instance task execution works on
+	WakeAt             *time.Time        `gorm:"index" json:"wake_at"`           // When a waiting timer task execution completes
+	DueAt              *time.Time        `json:"due_at"`
+	OverdueAt          *time.Time        `json:"overdue_at"` // When the task execution was reported as overdue
+	CompletedAt        *time.Time        `json:"completed_at"`
+	CreatedAt          time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TaskNotification records a message sent to announce a task execution so it can be edited later
+type TaskNotification struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskExecutionID uint      `gorm:"index" json:"task_execution_id"`
+	ChatID          int64     `gorm:"type:bigint" json:"chat_id"`
+	MessageID       int       `json:"message_id"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TaskAssignmentLog records every change of the assignee of a task execution
+type TaskAssignmentLog struct {
+	ID              uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskExecutionID uint                 `gorm:"index" json:"task_execution_id"`
+	Action          TaskAssignmentAction `gorm:"type:varchar(50)" json:"action"`
+	FromUserID      *int64               `gorm:"type:bigint" json:"from_user_id"`
+	ToUserID        *int64               `gorm:"type:bigint" json:"to_user_id"`
+	ByUserID        int64                `gorm:"type:bigint" json:"by_user_id"`
+	CreatedAt       time.Time            `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // TaskPrerequisite represents prerequisite tasks that must be completed before a task can start
 type TaskPrerequisite struct {
 	TaskID         uint      `gorm:"primaryKey;index" json:"task_id"`         // References Task
 	PrerequisiteID uint      `gorm:"primaryKey;index" json:"prerequisite_id"` // References Task
+	Condition      string    `gorm:"type:varchar(255)" json:"condition"`      // Optional branch condition on the process variables, e.g. {{amount}} > 1000
 	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
@@ -56,15 +99,50 @@ const (
 	TaskStatusPending   TaskStatus = "pending"
 	TaskStatusAssigned  TaskStatus = "assigned"
 	TaskStatusCompleted TaskStatus = "completed"
+	TaskStatusCancelled TaskStatus = "cancelled"
+	TaskStatusWaiting   TaskStatus = "waiting" // Waiting for a sub-process, a timer or a webhook call
+	TaskStatusFailed    TaskStatus = "failed"  // A service task whose webhook call failed
+)
+
+// MultiInstanceMode selects how many task executions are started for a task
+type MultiInstanceMode string
+
+const (
+	MultiInstanceNone    MultiInstanceMode = ""
+	MultiInstanceMembers MultiInstanceMode = "members"
+	MultiInstanceList    MultiInstanceMode = "list"
+)
+
+// TimerType selects how long a timer task waits
+type TimerType string
+
+const (
+	TimerTypeNone          TimerType = ""
+	TimerTypeDuration      TimerType = "duration"       // Wait a fixed time
+	TimerTypeUntilVariable TimerType = "until_variable" // Wait until the date or time held by a process variable
+	TimerTypeBusinessHours TimerType = "business_hours" // Wait a fixed time, then until business hours
+)
+
+// TaskAssignmentAction represents the kind of change recorded in a TaskAssignmentLog
+type TaskAssignmentAction string
+
+const (
+	TaskAssignmentActionClaim           TaskAssignmentAction = "claim"
+	TaskAssignmentActionRelease         TaskAssignmentAction = "release"
+	TaskAssignmentActionDelegateRequest TaskAssignmentAction = "delegate_request"
+	TaskAssignmentActionDelegateAccept  TaskAssignmentAction = "delegate_accept"
+	TaskAssignmentActionDelegateDecline TaskAssignmentAction = "delegate_decline"
+	TaskAssignmentActionReassign        TaskAssignmentAction = "reassign"
 )
 
 // TaskBuilder manages the state of task creation
 type TaskBuilder struct {
 	UserID               int64
-	CurrentStep          string // "process", "title", "description", "prerequisites", "team"
+	CurrentStep          string // "process", "title", "description", "prerequisites", "prerequisite_condition", "team", "webhook_url", "webhook_secret", "timer_wait", "timer_variable", "multi_instance", "multi_variable", "multi_threshold", "subprocess_inputs", "subprocess_outputs", "is_final", "due"
 	ProcessID            uint
-	Task                 Task   `gorm:"-"` // GORM will ignore this field
-	Prerequisites        []uint // List of prerequisite task IDs
+	Task                 Task            `gorm:"-"` // GORM will ignore this field
+	Prerequisites        []uint          // List of prerequisite task IDs
+	Conditions           map[uint]string // Branch condition of the task after each prerequisite, keyed by the prerequisite ID
 	HasMorePrerequisites bool
 }
 
@@ -83,3 +161,39 @@ func (td *TaskDependency) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return nil
 }
+
+// IsSubProcess reports whether the task launches another process instead of being done by a team
+func (t *Task) IsSubProcess() bool {
+	return t.SubProcessID != nil
+}
+
+// IsService reports whether the task is done by calling a webhook
+func (t *Task) IsService() bool {
+	return t.WebhookURL != ""
+}
+
+// IsTimer reports whether the task is a wait completed by the engine
+func (t *Task) IsTimer() bool {
+	return t.TimerType != TimerTypeNone
+}
+
+// IsMultiInstance reports whether the task is done by several task executions at once
+func (t *Task) IsMultiInstance() bool {
+	return t.MultiInstance != MultiInstanceNone
+}
+
+// IsOpen reports whether the task execution still waits for someone or something to finish it
+func (te *TaskExecution) IsOpen() bool {
+	return te.Status == TaskStatusPending || te.Status == TaskStatusAssigned || te.Status == TaskStatusWaiting
+}
+
+// IsOverdue reports whether the task execution is still open after its deadline
+func (te *TaskExecution) IsOverdue(now time.Time) bool {
+	if te.DueAt == nil {
+		return false
+	}
+	if te.Status != TaskStatusPending && te.Status != TaskStatusAssigned {
+		return false
+	}
+	return now.After(*te.DueAt)
+}
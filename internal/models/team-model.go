@@ -4,12 +4,15 @@ import "time"
 
 type (
 	Team struct {
-		ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-		Name        string    `gorm:"type:varchar(100);not null" json:"name"`
-		Description string    `gorm:"type:text" json:"description"`
-		JoinKey     string    `gorm:"type:varchar(8);unique;not null" json:"join_key"`
-		Users       []User    `gorm:"many2many:user_teams;" json:"users"`
-		OwnerID     int64     `gorm:"type:bigint;" json:"owner_id"`
+		ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+		Name        string `gorm:"type:varchar(100);not null" json:"name"`
+		Description string `gorm:"type:text" json:"description"`
+		JoinKey     string `gorm:"type:varchar(8);unique;not null" json:"join_key"`
+		Users       []User `gorm:"many2many:user_teams;" json:"users"`
+		OwnerID     int64  `gorm:"type:bigint;" json:"owner_id"`
+		// GroupChatID is the group chat the team works in; its members join the team and its task notifications go there
+		GroupChatID *int64    `gorm:"type:bigint;uniqueIndex" json:"group_chat_id"`
+		GroupTitle  string    `gorm:"type:varchar(255)" json:"group_title"`
 		CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 		UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 	}
@@ -8,13 +8,16 @@ import (
 
 type (
 	User struct {
-		ID        int64     `gorm:"primaryKey;type:bigint" json:"id"`
-		Username  string    `gorm:"type:varchar(100)" json:"username"`
-		FirstName string    `gorm:"type:varchar(100);column:firstname" json:"firstname"`
-		LastName  string    `gorm:"type:varchar(100);column:lastname" json:"lastname"`
-		Teams     []Team    `gorm:"many2many:user_teams;" json:"teams"`
-		CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-		UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+		ID        int64  `gorm:"primaryKey;type:bigint" json:"id"`
+		Username  string `gorm:"type:varchar(100)" json:"username"`
+		FirstName string `gorm:"type:varchar(100);column:firstname" json:"firstname"`
+		LastName  string `gorm:"type:varchar(100);column:lastname" json:"lastname"`
+		Teams     []Team `gorm:"many2many:user_teams;" json:"teams"`
+		// AwayUntil is set while the user is out of office; work is routed to AwayDelegateID meanwhile
+		AwayUntil      *time.Time `json:"away_until"`
+		AwayDelegateID *int64     `gorm:"type:bigint" json:"away_delegate_id"`
+		CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+		UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 	}
 
 	UserTeams struct {
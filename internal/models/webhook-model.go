@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type (
+	// WebhookSubscription asks for the lifecycle events of a process, or of the tasks of a team, to be posted to a URL
+	WebhookSubscription struct {
+		ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+		OwnerID   int64     `gorm:"type:bigint;index" json:"owner_id"`
+		ProcessID *uint     `gorm:"index" json:"process_id"` // Set for subscriptions to the process and task events of a process
+		Process   *Process  `json:"process"`
+		TeamID    *uint     `gorm:"index" json:"team_id"` // Set for subscriptions to the task events of a team
+		Team      *Team     `json:"team"`
+		URL       string    `gorm:"type:text;not null" json:"url"`
+		Secret    string    `gorm:"type:varchar(100)" json:"-"` // Key of the HMAC-SHA256 signature of every event
+		CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	}
+
+	WebhookDeliveryStatus string
+
+	// WebhookDelivery is one event queued for a subscription. Deliveries are stored before they are sent,
+	// so events survive restarts and failed ones are retried with backoff.
+	WebhookDelivery struct {
+		ID             uint                  `gorm:"primaryKey;autoIncrement" json:"id"`
+		SubscriptionID uint                  `gorm:"index" json:"subscription_id"`
+		Subscription   *WebhookSubscription  `json:"subscription"`
+		Event          string                `gorm:"type:varchar(50)" json:"event"`
+		Payload        string                `gorm:"type:text" json:"payload"`
+		Status         WebhookDeliveryStatus `gorm:"type:varchar(20);index" json:"status"`
+		Attempts       int                   `json:"attempts"`
+		NextAttemptAt  *time.Time            `gorm:"index" json:"next_attempt_at"`
+		ResponseStatus int                   `json:"response_status"` // HTTP status of the last attempt, 0 when no response arrived
+		LastError      string                `gorm:"type:text" json:"last_error"`
+		DeliveredAt    *time.Time            `json:"delivered_at"`
+		CreatedAt      time.Time             `gorm:"autoCreateTime" json:"created_at"`
+		UpdatedAt      time.Time             `gorm:"autoUpdateTime" json:"updated_at"`
+	}
+)
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"bbb/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	APITokenRepository interface {
+		Save(req *models.APIToken) error
+		GetByHash(tokenHash string) (*models.APIToken, error)
+		GetByUserID(userID int64) ([]models.APIToken, error)
+		Delete(id uint, userID int64) error
+		SetLastUsed(id uint, usedAt time.Time) error
+	}
+
+	apiTokenRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewAPITokenRepository(db *gorm.DB) APITokenRepository {
+	return &apiTokenRepository{
+		db: db,
+	}
+}
+
+func (r *apiTokenRepository) Save(req *models.APIToken) error {
+	return r.db.Create(req).Error
+}
+
+func (r *apiTokenRepository) GetByHash(tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *apiTokenRepository) GetByUserID(userID int64) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete removes a token of a user, so tokens of other users can't be revoked by guessing their ID
+func (r *apiTokenRepository) Delete(id uint, userID int64) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *apiTokenRepository) SetLastUsed(id uint, usedAt time.Time) error {
+	return r.db.Model(&models.APIToken{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"bbb/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type (
+	AttachmentRepository interface {
+		Save(req *models.TaskAttachment) error
+		GetByTaskExecutionID(taskExecutionID uint) ([]models.TaskAttachment, error)
+		GetByProcessExecutionID(processExecutionID uint) ([]models.TaskAttachment, error)
+	}
+
+	attachmentRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{
+		db: db,
+	}
+}
+
+func (r *attachmentRepository) Save(req *models.TaskAttachment) error {
+	return r.db.Create(req).Error
+}
+
+func (r *attachmentRepository) GetByTaskExecutionID(taskExecutionID uint) ([]models.TaskAttachment, error) {
+	var attachments []models.TaskAttachment
+	if err := r.db.Where("task_execution_id = ?", taskExecutionID).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) GetByProcessExecutionID(processExecutionID uint) ([]models.TaskAttachment, error) {
+	var attachments []models.TaskAttachment
+	if err := r.db.
+		Joins("JOIN task_executions ON task_executions.id = task_attachments.task_execution_id").
+		Where("task_executions.process_execution_id = ?", processExecutionID).
+		Order("task_attachments.id").
+		Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"bbb/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type (
+	CommentRepository interface {
+		Save(req *models.Comment) error
+		GetByTaskExecutionID(taskExecutionID uint) ([]models.Comment, error)
+		GetByProcessExecutionID(processExecutionID uint) ([]models.Comment, error)
+	}
+
+	commentRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepository{
+		db: db,
+	}
+}
+
+func (r *commentRepository) Save(req *models.Comment) error {
+	return r.db.Omit("User").Create(req).Error
+}
+
+func (r *commentRepository) GetByTaskExecutionID(taskExecutionID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	if err := r.db.Preload("User").Where("task_execution_id = ?", taskExecutionID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// GetByProcessExecutionID returns every comment of a process execution, including those on its task executions
+func (r *commentRepository) GetByProcessExecutionID(processExecutionID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	if err := r.db.Preload("User").Where("process_execution_id = ?", processExecutionID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
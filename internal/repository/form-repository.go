@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"bbb/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type (
+	FormRepository interface {
+		SaveField(req *models.TaskFormField) error
+		GetFieldByID(fieldID uint) (*models.TaskFormField, error)
+		GetFieldsByTaskID(taskID uint) ([]models.TaskFormField, error)
+		GetFieldsByProcessID(processID uint) ([]models.TaskFormField, error)
+		DeleteField(fieldID uint) error
+		SaveAnswers(answers []models.TaskFormAnswer) error
+		GetAnswersByTaskExecutionID(taskExecutionID uint) ([]models.TaskFormAnswer, error)
+		GetAnswersByProcessExecutionID(processExecutionID uint) ([]models.TaskFormAnswer, error)
+	}
+
+	formRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewFormRepository(db *gorm.DB) FormRepository {
+	return &formRepository{
+		db: db,
+	}
+}
+
+func (r *formRepository) SaveField(req *models.TaskFormField) error {
+	if req.ID == 0 {
+		return r.db.Create(req).Error
+	}
+	return r.db.Save(req).Error
+}
+
+func (r *formRepository) GetFieldByID(fieldID uint) (*models.TaskFormField, error) {
+	var field models.TaskFormField
+	if err := r.db.First(&field, fieldID).Error; err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+func (r *formRepository) GetFieldsByTaskID(taskID uint) ([]models.TaskFormField, error) {
+	var fields []models.TaskFormField
+	if err := r.db.Where("task_id = ?", taskID).Order("position, id").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// GetFieldsByProcessID returns the fields of the start form of a process
+func (r *formRepository) GetFieldsByProcessID(processID uint) ([]models.TaskFormField, error) {
+	var fields []models.TaskFormField
+	if err := r.db.Where("process_id = ? AND task_id = 0", processID).Order("position, id").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (r *formRepository) DeleteField(fieldID uint) error {
+	return r.db.Delete(&models.TaskFormField{}, fieldID).Error
+}
+
+func (r *formRepository) SaveAnswers(answers []models.TaskFormAnswer) error {
+	if len(answers) == 0 {
+		return nil
+	}
+	return r.db.Omit("Field").Create(&answers).Error
+}
+
+func (r *formRepository) GetAnswersByTaskExecutionID(taskExecutionID uint) ([]models.TaskFormAnswer, error) {
+	var answers []models.TaskFormAnswer
+	if err := r.db.Preload("Field").Where("task_execution_id = ?", taskExecutionID).Order("id").Find(&answers).Error; err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
+
+func (r *formRepository) GetAnswersByProcessExecutionID(processExecutionID uint) ([]models.TaskFormAnswer, error) {
+	var answers []models.TaskFormAnswer
+	if err := r.db.Preload("Field").
+		Joins("JOIN task_executions ON task_executions.id = task_form_answers.task_execution_id").
+		Where("task_executions.process_execution_id = ?", processExecutionID).
+		Order("task_form_answers.id").
+		Find(&answers).Error; err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"bbb/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	NotificationRepository interface {
+		GetSettings(userID int64) (*models.NotificationSettings, error)
+		SaveSettings(req *models.NotificationSettings) error
+		GetDueDigests(now time.Time) ([]models.NotificationSettings, error)
+	}
+
+	notificationRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{
+		db: db,
+	}
+}
+
+// GetSettings returns the saved settings of the user, or unsaved empty settings when the user has none
+func (r *notificationRepository) GetSettings(userID int64) (*models.NotificationSettings, error) {
+	var settings models.NotificationSettings
+	if err := r.db.Where("user_id = ?", userID).FirstOrInit(&settings, models.NotificationSettings{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *notificationRepository) SaveSettings(req *models.NotificationSettings) error {
+	return r.db.Save(req).Error
+}
+
+// GetDueDigests returns the settings of the users whose digest should be sent by now
+func (r *notificationRepository) GetDueDigests(now time.Time) ([]models.NotificationSettings, error) {
+	var settings []models.NotificationSettings
+	if err := r.db.Where("digest_frequency <> ? AND next_digest_at IS NOT NULL AND next_digest_at <= ?", models.DigestOff, now).
+		Order("next_digest_at").
+		Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
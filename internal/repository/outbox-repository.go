@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"bbb/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	OutboxRepository interface {
+		Save(req *models.OutboxMessage) error
+		GetByID(id uint) (*models.OutboxMessage, error)
+		Update(req *models.OutboxMessage) error
+		GetDueMessages(now time.Time, limit int) ([]models.OutboxMessage, error)
+		GetDeadMessagesByOwnerID(ownerID int64, limit int) ([]models.OutboxMessage, error)
+	}
+
+	outboxRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+func (r *outboxRepository) GetByID(id uint) (*models.OutboxMessage, error) {
+	var message models.OutboxMessage
+	if err := r.db.First(&message, id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *outboxRepository) Save(req *models.OutboxMessage) error {
+	return r.db.Omit("TaskExecution").Create(req).Error
+}
+
+func (r *outboxRepository) Update(req *models.OutboxMessage) error {
+	return r.db.Omit("TaskExecution").Save(req).Error
+}
+
+// GetDueMessages returns the pending messages whose attempt is due, oldest first
+func (r *outboxRepository) GetDueMessages(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	if err := r.db.Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+		Order("id").Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetDeadMessagesByOwnerID returns the latest messages of the owner's processes that could not be delivered
+func (r *outboxRepository) GetDeadMessagesByOwnerID(ownerID int64, limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	if err := r.db.Preload("TaskExecution.Task").
+		Where("owner_id = ? AND status = ?", ownerID, models.OutboxStatusDead).
+		Order("id DESC").Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"bbb/internal/models"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
@@ -12,11 +14,18 @@ type (
 		GetAll() ([]models.Process, error)
 		GetByID(processID uint) (*models.Process, error)
 		GetByUserID(userID int64) ([]models.Process, error)
+		SearchByUserID(userID int64, query string, limit int) ([]models.Process, error)
 		SaveProcessExecution(execution *models.ProcessExecution) error
 		GetProcessExecutionByID(id uint) (*models.ProcessExecution, error)
 		GetProcessExecutionsByProcessID(processID uint) ([]models.ProcessExecution, error)
+		GetProcessExecutionByParentTaskExecutionID(taskExecutionID uint) (*models.ProcessExecution, error)
 		UpdateProcessExecution(execution *models.ProcessExecution) error
 		GetPendingProcessExecutions() ([]models.ProcessExecution, error)
+		SaveVariable(variable *models.ProcessVariable) error
+		GetVariables(processExecutionID uint) ([]models.ProcessVariable, error)
+		GetCompletedExecutionsByOwnerID(ownerID int64, since time.Time) ([]models.ProcessExecution, error)
+		GetStalledExecutionsByOwnerID(ownerID int64, idleSince time.Time) ([]models.ProcessExecution, error)
+		GetProcessExecutionsSince(processID uint, since time.Time) ([]models.ProcessExecution, error)
 	}
 
 	processRepository struct {
@@ -66,6 +75,18 @@ func (r *processRepository) GetByUserID(userID int64) ([]models.Process, error)
 	return processes, err
 }
 
+// SearchByUserID returns the user's processes whose name or description contains the query, latest first
+func (r *processRepository) SearchByUserID(userID int64, query string, limit int) ([]models.Process, error) {
+	db := r.db.Where("user_id = ?", userID)
+	if query != "" {
+		pattern := containsPattern(query)
+		db = db.Where("name ILIKE ? OR description ILIKE ?", pattern, pattern)
+	}
+	var processes []models.Process
+	err := db.Order("updated_at DESC").Limit(limit).Find(&processes).Error
+	return processes, err
+}
+
 func (r *processRepository) SaveProcessExecution(execution *models.ProcessExecution) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
@@ -138,12 +159,21 @@ func (r *processRepository) GetProcessExecutionByID(id uint) (*models.ProcessExe
 
 func (r *processRepository) GetProcessExecutionsByProcessID(processID uint) ([]models.ProcessExecution, error) {
 	var executions []models.ProcessExecution
-	if err := r.db.Where("process_id = ?", processID).Find(&executions).Error; err != nil {
+	if err := r.db.Where("process_id = ?", processID).Order("started_at DESC").Find(&executions).Error; err != nil {
 		return nil, err
 	}
 	return executions, nil
 }
 
+// GetProcessExecutionByParentTaskExecutionID returns the sub-process execution started by a call activity
+func (r *processRepository) GetProcessExecutionByParentTaskExecutionID(taskExecutionID uint) (*models.ProcessExecution, error) {
+	var execution models.ProcessExecution
+	if err := r.db.Where("parent_task_execution_id = ?", taskExecutionID).First(&execution).Error; err != nil {
+		return nil, err
+	}
+	return r.GetProcessExecutionByID(execution.ID)
+}
+
 func (r *processRepository) UpdateProcessExecution(execution *models.ProcessExecution) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
@@ -216,3 +246,61 @@ func (r *processRepository) GetPendingProcessExecutions() ([]models.ProcessExecu
 	}
 	return executions, nil
 }
+
+// SaveVariable creates a process variable or overwrites the value of an existing one with the same name
+func (r *processRepository) SaveVariable(variable *models.ProcessVariable) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "process_execution_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(variable).Error
+}
+
+func (r *processRepository) GetVariables(processExecutionID uint) ([]models.ProcessVariable, error) {
+	var variables []models.ProcessVariable
+	if err := r.db.Where("process_execution_id = ?", processExecutionID).Order("id").Find(&variables).Error; err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+// GetCompletedExecutionsByOwnerID returns the executions of the owner's processes that completed after the given time
+func (r *processRepository) GetCompletedExecutionsByOwnerID(ownerID int64, since time.Time) ([]models.ProcessExecution, error) {
+	var executions []models.ProcessExecution
+	if err := r.db.Preload("Process").
+		Joins("JOIN processes ON processes.id = process_executions.process_id").
+		Where("processes.user_id = ? AND process_executions.status = ? AND process_executions.completed_at > ?", ownerID, models.ProcessExecutionStatusCompleted, since).
+		Order("process_executions.completed_at").
+		Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// GetStalledExecutionsByOwnerID returns the open executions of the owner's processes with no task activity after the given time
+func (r *processRepository) GetStalledExecutionsByOwnerID(ownerID int64, idleSince time.Time) ([]models.ProcessExecution, error) {
+	var executions []models.ProcessExecution
+	if err := r.db.Preload("Process").
+		Joins("JOIN processes ON processes.id = process_executions.process_id").
+		Where("processes.user_id = ? AND process_executions.status IN ? AND process_executions.started_at <= ?", ownerID,
+			[]models.ProcessExecutionStatus{models.ProcessExecutionStatusPending, models.ProcessExecutionStatusRunning}, idleSince).
+		Where("NOT EXISTS (SELECT 1 FROM task_executions WHERE task_executions.process_execution_id = process_executions.id AND task_executions.updated_at > ?)", idleSince).
+		Order("process_executions.started_at").
+		Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// GetProcessExecutionsSince returns the executions of the process that started or completed after the given time,
+// along with those still open
+func (r *processRepository) GetProcessExecutionsSince(processID uint, since time.Time) ([]models.ProcessExecution, error) {
+	var executions []models.ProcessExecution
+	if err := r.db.Where("process_id = ?", processID).
+		Where("started_at > ? OR completed_at > ? OR status IN ?", since, since,
+			[]models.ProcessExecutionStatus{models.ProcessExecutionStatusPending, models.ProcessExecutionStatusRunning}).
+		Order("started_at").
+		Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
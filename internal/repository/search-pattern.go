@@ -0,0 +1,10 @@
+package repository
+
+import "strings"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern turns a search query into an ILIKE pattern matching text that contains it literally
+func containsPattern(query string) string {
+	return "%" + likeEscaper.Replace(query) + "%"
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"bbb/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// searchResultsCTE finds everything a user may read that matches the query @query, with the rank of each match:
+// the processes they own with their tasks and executions, and the completion notes and comments of task executions
+// in those processes, assigned to them or commented on by them. Texts go through search_normalize, which is created
+// with the database, on both sides of the match.
+const searchResultsCTE = `
+WITH q AS (
+	SELECT to_tsquery('simple', search_normalize(@query)) AS query
+), results AS (
+	SELECT 'process' AS kind, p.id, p.name AS title, p.description AS snippet,
+		ts_rank(to_tsvector('simple', search_normalize(concat_ws(' ', p.name, p.description))), q.query) AS rank
+	FROM processes p CROSS JOIN q
+	WHERE p.user_id = @user
+		AND to_tsvector('simple', search_normalize(concat_ws(' ', p.name, p.description))) @@ q.query
+
+	UNION ALL
+	SELECT 'task', t.id, t.title || ' — ' || p.name, t.description,
+		ts_rank(to_tsvector('simple', search_normalize(concat_ws(' ', t.title, t.description))), q.query)
+	FROM tasks t JOIN processes p ON p.id = t.process_id CROSS JOIN q
+	WHERE p.user_id = @user
+		AND to_tsvector('simple', search_normalize(concat_ws(' ', t.title, t.description))) @@ q.query
+
+	UNION ALL
+	SELECT 'execution', e.id, p.name || ' — ' || ` + executionDisplayName + `, coalesce(v.pairs, ''),
+		ts_rank(to_tsvector('simple', search_normalize(concat_ws(' ', e.title, v.vals))), q.query)
+	FROM process_executions e JOIN processes p ON p.id = e.process_id
+		LEFT JOIN LATERAL (
+			SELECT string_agg(pv.name || ': ' || pv.value, '، ' ORDER BY pv.name) AS pairs, string_agg(pv.value, ' ') AS vals
+			FROM process_variables pv WHERE pv.process_execution_id = e.id
+		) v ON true
+		CROSS JOIN q
+	WHERE p.user_id = @user
+		AND to_tsvector('simple', search_normalize(concat_ws(' ', e.title, v.vals))) @@ q.query
+
+	UNION ALL
+	SELECT 'note', te.id, t.title || ' — ' || ` + executionDisplayName + `, te.user_description,
+		ts_rank(to_tsvector('simple', search_normalize(te.user_description)), q.query)
+	FROM task_executions te JOIN tasks t ON t.id = te.task_id
+		JOIN process_executions e ON e.id = te.process_execution_id
+		JOIN processes p ON p.id = t.process_id
+		CROSS JOIN q
+	WHERE (p.user_id = @user OR te.user_id = @user)
+		AND to_tsvector('simple', search_normalize(te.user_description)) @@ q.query
+
+	UNION ALL
+	SELECT 'task_comment', te.id, t.title || ' — ' || ` + executionDisplayName + `, c.text,
+		ts_rank(to_tsvector('simple', search_normalize(c.text)), q.query)
+	FROM comments c JOIN task_executions te ON te.id = c.task_execution_id
+		JOIN tasks t ON t.id = te.task_id
+		JOIN process_executions e ON e.id = te.process_execution_id
+		JOIN processes p ON p.id = t.process_id
+		CROSS JOIN q
+	WHERE (p.user_id = @user OR te.user_id = @user OR c.user_id = @user)
+		AND to_tsvector('simple', search_normalize(c.text)) @@ q.query
+
+	UNION ALL
+	SELECT 'execution_comment', e.id, p.name || ' — ' || ` + executionDisplayName + `, c.text,
+		ts_rank(to_tsvector('simple', search_normalize(c.text)), q.query)
+	FROM comments c JOIN process_executions e ON e.id = c.process_execution_id
+		JOIN processes p ON p.id = e.process_id
+		CROSS JOIN q
+	WHERE c.task_execution_id IS NULL AND p.user_id = @user
+		AND to_tsvector('simple', search_normalize(c.text)) @@ q.query
+)`
+
+// executionDisplayName mirrors ProcessExecution.DisplayName for the process execution e
+const executionDisplayName = `CASE WHEN coalesce(e.title, '') = '' THEN '#' || e.id ELSE e.title || ' (#' || e.id || ')' END`
+
+type (
+	SearchRepository interface {
+		Search(userID int64, query string, offset, limit int) ([]models.SearchResult, int64, error)
+	}
+
+	searchRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &searchRepository{
+		db: db,
+	}
+}
+
+// Search runs a full-text search for the user, best matches first. The query is in to_tsquery syntax.
+func (r *searchRepository) Search(userID int64, query string, offset, limit int) ([]models.SearchResult, int64, error) {
+	params := map[string]interface{}{
+		"user":   userID,
+		"query":  query,
+		"offset": offset,
+		"limit":  limit,
+	}
+
+	var total int64
+	if err := r.db.Raw(searchResultsCTE+` SELECT count(*) FROM results`, params).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var results []models.SearchResult
+	if err := r.db.Raw(searchResultsCTE+`
+		SELECT kind, id, title, snippet, rank FROM results
+		ORDER BY rank DESC, kind, id DESC
+		LIMIT @limit OFFSET @offset`, params).
+		Scan(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
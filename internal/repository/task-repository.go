@@ -2,8 +2,10 @@ package repository
 
 import (
 	"bbb/internal/models"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
@@ -12,15 +14,36 @@ type (
 		GetByProcessID(processID uint) ([]models.Task, error)
 		GetByID(taskID uint) (*models.Task, error)
 		GetPrerequisites(taskID uint) ([]uint, error)
-		AddPrerequisite(taskID uint, prerequisiteID uint) error
+		AddPrerequisite(taskID uint, prerequisiteID uint, condition string) error
 		StartTaskExecution(taskID uint) (models.TaskExecution, error)
 		GetTaskExecutionsByTaskID(taskID uint) ([]models.TaskExecution, error)
 		GetTaskExecutionByID(taskExecutionID uint) (*models.TaskExecution, error)
 		GetTaskExecutionsByUserID(userID int64) ([]models.TaskExecution, error)
 		GetAllTaskExecutions() ([]models.TaskExecution, error)
 		UpdateTaskExecution(taskExecution *models.TaskExecution) error
+		TransitionTaskExecution(taskExecution *models.TaskExecution, from models.TaskStatus) (bool, error)
+		CompleteTaskExecution(taskExecution *models.TaskExecution, from models.TaskStatus, answers []models.TaskFormAnswer, variables []models.ProcessVariable) (bool, error)
+		CancelOpenTaskExecutions(processExecutionID uint, taskExecutionIDs []uint) ([]uint, error)
 		GetDependentTasks(taskID uint) ([]models.Task, error)
+		GetDependencies(taskID uint) ([]models.TaskPrerequisite, error)
 		SaveTaskExecution(req *models.TaskExecution) error
+		SaveTaskExecutionsWithOutbox(taskExecutions []models.TaskExecution, notify func(taskExecution *models.TaskExecution) []models.OutboxMessage) error
+		SaveTaskNotification(req *models.TaskNotification) error
+		GetTaskNotifications(taskExecutionID uint) ([]models.TaskNotification, error)
+		GetTaskNotificationByMessage(chatID int64, messageID int) (*models.TaskNotification, error)
+		UpdateTaskExecutionAssignment(taskExecution *models.TaskExecution) error
+		SaveAssignmentLog(req *models.TaskAssignmentLog) error
+		GetAssignmentLogs(taskExecutionID uint) ([]models.TaskAssignmentLog, error)
+		GetOpenTaskExecutionsByTeamID(teamID uint) ([]models.TaskExecution, error)
+		GetAssignedTaskExecutions(userID int64, offset, limit int) ([]models.TaskExecution, int64, error)
+		GetClaimableTaskExecutions(userID int64, offset, limit int) ([]models.TaskExecution, int64, error)
+		SearchOpenTaskExecutions(userID int64, query string, limit int) ([]models.TaskExecution, error)
+		GetCompletedTaskExecutions(userID int64, offset, limit int) ([]models.TaskExecution, int64, error)
+		GetTaskExecutionsByProcessExecutionID(processExecutionID uint) ([]models.TaskExecution, error)
+		GetDueScheduledTaskExecutions(now time.Time) ([]models.TaskExecution, error)
+		GetOverdueTaskExecutions(now time.Time) ([]models.TaskExecution, error)
+		GetTaskExecutionsByProcessIDSince(processID uint, since time.Time) ([]models.TaskExecution, error)
+		GetTaskExecutionsByTeamIDSince(teamID uint, since time.Time) ([]models.TaskExecution, error)
 	}
 
 	taskRepository struct {
@@ -76,10 +99,11 @@ func (r *taskRepository) GetPrerequisites(taskID uint) ([]uint, error) {
 	return prerequisiteIDs, nil
 }
 
-func (r *taskRepository) AddPrerequisite(taskID uint, prerequisiteID uint) error {
+func (r *taskRepository) AddPrerequisite(taskID uint, prerequisiteID uint, condition string) error {
 	prerequisite := models.TaskPrerequisite{
 		TaskID:         taskID,
 		PrerequisiteID: prerequisiteID,
+		Condition:      condition,
 	}
 	return r.db.Create(&prerequisite).Error
 }
@@ -107,7 +131,7 @@ func (r *taskRepository) GetTaskExecutionsByTaskID(taskID uint) ([]models.TaskEx
 
 func (r *taskRepository) GetTaskExecutionByID(taskExecutionID uint) (*models.TaskExecution, error) {
 	var taskExecution models.TaskExecution
-	if err := r.db.Preload("Task.Process").Preload("User").First(&taskExecution, taskExecutionID).Error; err != nil {
+	if err := r.db.Preload("Task.Process").Preload("ProcessExecution").Preload("User").First(&taskExecution, taskExecutionID).Error; err != nil {
 		return nil, err
 	}
 	return &taskExecution, nil
@@ -134,6 +158,103 @@ func (r *taskRepository) UpdateTaskExecution(taskExecution *models.TaskExecution
 	return r.db.Model(&models.TaskExecution{}).Where("id = ?", taskExecution.ID).Updates(taskExecution).Error
 }
 
+// TransitionTaskExecution updates a task execution only while it still has the given status, and reports whether it did.
+// Two users claiming the same task at once can then never both succeed.
+func (r *taskRepository) TransitionTaskExecution(taskExecution *models.TaskExecution, from models.TaskStatus) (bool, error) {
+	result := r.db.Model(&models.TaskExecution{}).
+		Where("id = ? AND status = ?", taskExecution.ID, from).
+		Omit(clause.Associations).
+		Updates(taskExecution)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CompleteTaskExecution completes a task execution that still has the given status and moves it to the completed list
+// of its process execution. The form answers and process variables it leaves behind are stored in the same transaction,
+// so a task is never completed without its answers, nor answered without being completed. It reports false, changing
+// nothing, when the task execution no longer has the given status.
+func (r *taskRepository) CompleteTaskExecution(taskExecution *models.TaskExecution, from models.TaskStatus, answers []models.TaskFormAnswer, variables []models.ProcessVariable) (bool, error) {
+	completed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TaskExecution{}).
+			Where("id = ? AND status = ?", taskExecution.ID, from).
+			Omit(clause.Associations).
+			Updates(taskExecution)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if len(answers) > 0 {
+			if err := tx.Omit("Field").Create(&answers).Error; err != nil {
+				return err
+			}
+		}
+		if len(variables) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "process_execution_id"}, {Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+			}).Create(&variables).Error; err != nil {
+				return err
+			}
+		}
+
+		state := "process_execution_id = ? AND task_execution_id = ?"
+		if err := tx.Where(state, taskExecution.ProcessExecutionID, taskExecution.ID).Delete(&models.PendingTask{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(state, taskExecution.ProcessExecutionID, taskExecution.ID).Delete(&models.InProgressTask{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.CompletedTask{ProcessExecutionID: taskExecution.ProcessExecutionID, TaskExecutionID: taskExecution.ID}).Error; err != nil {
+			return err
+		}
+		completed = true
+		return nil
+	})
+	return completed, err
+}
+
+// CancelOpenTaskExecutions cancels the given task executions that are still open and removes them from the pending and
+// in-progress lists of their process execution, in one transaction. Task executions that closed in the meantime are
+// left alone. It returns the IDs of the task executions it cancelled.
+func (r *taskRepository) CancelOpenTaskExecutions(processExecutionID uint, taskExecutionIDs []uint) ([]uint, error) {
+	var cancelled []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		cancelled = nil
+		open := []models.TaskStatus{models.TaskStatusPending, models.TaskStatusAssigned, models.TaskStatusWaiting}
+		for _, id := range taskExecutionIDs {
+			result := tx.Model(&models.TaskExecution{}).
+				Where("id = ? AND status IN ?", id, open).
+				Update("status", models.TaskStatusCancelled)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			state := "process_execution_id = ? AND task_execution_id = ?"
+			if err := tx.Where(state, processExecutionID, id).Delete(&models.PendingTask{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where(state, processExecutionID, id).Delete(&models.InProgressTask{}).Error; err != nil {
+				return err
+			}
+			cancelled = append(cancelled, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
 func (r *taskRepository) GetDependentTasks(taskID uint) ([]models.Task, error) {
 	var tasks []models.Task
 	if err := r.db.Joins("JOIN task_prerequisites ON tasks.id = task_prerequisites.task_id").
@@ -144,6 +265,15 @@ func (r *taskRepository) GetDependentTasks(taskID uint) ([]models.Task, error) {
 	return tasks, nil
 }
 
+// GetDependencies returns the edges to the tasks that have the given task as a prerequisite
+func (r *taskRepository) GetDependencies(taskID uint) ([]models.TaskPrerequisite, error) {
+	var dependencies []models.TaskPrerequisite
+	if err := r.db.Where("prerequisite_id = ?", taskID).Find(&dependencies).Error; err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
 func (r *taskRepository) SaveTaskExecution(req *models.TaskExecution) error {
 	if req.ID == 0 {
 		if err := r.db.Create(req).Error; err != nil {
@@ -156,3 +286,210 @@ func (r *taskRepository) SaveTaskExecution(req *models.TaskExecution) error {
 	}
 	return nil
 }
+
+// SaveTaskExecutionsWithOutbox creates task executions, adds them to the open lists of their process execution
+// and queues their announcements in one transaction, so a task is never started without its messages.
+// notify builds the messages of a task execution once it has its ID.
+func (r *taskRepository) SaveTaskExecutionsWithOutbox(taskExecutions []models.TaskExecution, notify func(taskExecution *models.TaskExecution) []models.OutboxMessage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range taskExecutions {
+			taskExecution := &taskExecutions[i]
+			if err := tx.Omit(clause.Associations).Create(taskExecution).Error; err != nil {
+				return err
+			}
+
+			var state interface{} = &models.PendingTask{ProcessExecutionID: taskExecution.ProcessExecutionID, TaskExecutionID: taskExecution.ID}
+			if taskExecution.Status == models.TaskStatusAssigned {
+				state = &models.InProgressTask{ProcessExecutionID: taskExecution.ProcessExecutionID, TaskExecutionID: taskExecution.ID}
+			}
+			if err := tx.Create(state).Error; err != nil {
+				return err
+			}
+
+			if messages := notify(taskExecution); len(messages) > 0 {
+				if err := tx.Omit(clause.Associations).Create(&messages).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (r *taskRepository) SaveTaskNotification(req *models.TaskNotification) error {
+	return r.db.Create(req).Error
+}
+
+func (r *taskRepository) GetTaskNotifications(taskExecutionID uint) ([]models.TaskNotification, error) {
+	var notifications []models.TaskNotification
+	if err := r.db.Where("task_execution_id = ?", taskExecutionID).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *taskRepository) GetTaskNotificationByMessage(chatID int64, messageID int) (*models.TaskNotification, error) {
+	var notification models.TaskNotification
+	if err := r.db.Where("chat_id = ? AND message_id = ?", chatID, messageID).First(&notification).Error; err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// UpdateTaskExecutionAssignment writes the assignment columns even when they are being cleared
+func (r *taskRepository) UpdateTaskExecutionAssignment(taskExecution *models.TaskExecution) error {
+	return r.db.Model(&models.TaskExecution{}).
+		Where("id = ?", taskExecution.ID).
+		Select("status", "user_id", "assigned_at", "delegate_user_id").
+		Updates(taskExecution).Error
+}
+
+func (r *taskRepository) SaveAssignmentLog(req *models.TaskAssignmentLog) error {
+	return r.db.Create(req).Error
+}
+
+func (r *taskRepository) GetAssignmentLogs(taskExecutionID uint) ([]models.TaskAssignmentLog, error) {
+	var logs []models.TaskAssignmentLog
+	if err := r.db.Where("task_execution_id = ?", taskExecutionID).Order("created_at").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *taskRepository) GetOpenTaskExecutionsByTeamID(teamID uint) ([]models.TaskExecution, error) {
+	var taskExecutions []models.TaskExecution
+	if err := r.db.Preload("Task").Preload("ProcessExecution").Preload("User").
+		Joins("JOIN tasks ON tasks.id = task_executions.task_id").
+		Where("tasks.team_id = ? AND task_executions.status IN ?", teamID, []models.TaskStatus{models.TaskStatusPending, models.TaskStatusAssigned}).
+		Order("task_executions.created_at").
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
+
+func (r *taskRepository) GetAssignedTaskExecutions(userID int64, offset, limit int) ([]models.TaskExecution, int64, error) {
+	query := r.db.Model(&models.TaskExecution{}).
+		Where("user_id = ? AND status = ?", userID, models.TaskStatusAssigned)
+	return r.paginateTaskExecutions(query, "assigned_at", offset, limit)
+}
+
+// GetClaimableTaskExecutions returns pending task executions of the teams the user is a member of
+func (r *taskRepository) GetClaimableTaskExecutions(userID int64, offset, limit int) ([]models.TaskExecution, int64, error) {
+	query := r.db.Model(&models.TaskExecution{}).
+		Joins("JOIN tasks ON tasks.id = task_executions.task_id").
+		Joins("JOIN user_teams ON user_teams.team_id = tasks.team_id").
+		Where("user_teams.user_id = ? AND user_teams.deleted_at IS NULL AND task_executions.status = ?", userID, models.TaskStatusPending)
+	return r.paginateTaskExecutions(query, "task_executions.created_at", offset, limit)
+}
+
+// SearchOpenTaskExecutions returns the open task executions the user can work on, those assigned to them and the
+// pending ones of their teams, whose task title, execution title or instance item contains the query, latest first
+func (r *taskRepository) SearchOpenTaskExecutions(userID int64, query string, limit int) ([]models.TaskExecution, error) {
+	teamIDs := r.db.Model(&models.UserTeams{}).Select("team_id").Where("user_id = ?", userID)
+	db := r.db.Model(&models.TaskExecution{}).
+		Joins("JOIN tasks ON tasks.id = task_executions.task_id").
+		Joins("JOIN process_executions ON process_executions.id = task_executions.process_execution_id").
+		Where("(task_executions.status = ? AND task_executions.user_id = ?) OR (task_executions.status = ? AND tasks.team_id IN (?))",
+			models.TaskStatusAssigned, userID, models.TaskStatusPending, teamIDs)
+	if query != "" {
+		pattern := containsPattern(query)
+		db = db.Where("tasks.title ILIKE ? OR process_executions.title ILIKE ? OR task_executions.instance_item ILIKE ?", pattern, pattern, pattern)
+	}
+	var taskExecutions []models.TaskExecution
+	if err := db.Preload("Task.Process").Preload("ProcessExecution").Preload("User").
+		Order("task_executions.created_at DESC").Limit(limit).
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
+
+func (r *taskRepository) GetCompletedTaskExecutions(userID int64, offset, limit int) ([]models.TaskExecution, int64, error) {
+	query := r.db.Model(&models.TaskExecution{}).
+		Where("user_id = ? AND status = ?", userID, models.TaskStatusCompleted)
+	return r.paginateTaskExecutions(query, "completed_at DESC", offset, limit)
+}
+
+func (r *taskRepository) paginateTaskExecutions(query *gorm.DB, order string, offset, limit int) ([]models.TaskExecution, int64, error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var taskExecutions []models.TaskExecution
+	if err := query.Preload("Task.Process").Preload("ProcessExecution").Preload("User").
+		Order(order).Offset(offset).Limit(limit).
+		Find(&taskExecutions).Error; err != nil {
+		return nil, 0, err
+	}
+	return taskExecutions, total, nil
+}
+
+func (r *taskRepository) GetTaskExecutionsByProcessExecutionID(processExecutionID uint) ([]models.TaskExecution, error) {
+	var taskExecutions []models.TaskExecution
+	if err := r.db.Preload("Task").Preload("ProcessExecution").Preload("User").
+		Where("process_execution_id = ?", processExecutionID).
+		Order("created_at").
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
+
+// GetDueScheduledTaskExecutions returns the waiting timer and service task executions whose time has come
+func (r *taskRepository) GetDueScheduledTaskExecutions(now time.Time) ([]models.TaskExecution, error) {
+	var taskExecutions []models.TaskExecution
+	if err := r.db.Preload("Task.Process").Preload("ProcessExecution").
+		Where("status = ? AND wake_at IS NOT NULL AND wake_at <= ?", models.TaskStatusWaiting, now).
+		Order("wake_at").
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
+
+// GetOverdueTaskExecutions returns the open task executions past their deadline that were not reported as overdue yet
+func (r *taskRepository) GetOverdueTaskExecutions(now time.Time) ([]models.TaskExecution, error) {
+	var taskExecutions []models.TaskExecution
+	if err := r.db.Preload("Task.Process").Preload("ProcessExecution").Preload("User").
+		Where("status IN ? AND due_at IS NOT NULL AND due_at <= ? AND overdue_at IS NULL",
+			[]models.TaskStatus{models.TaskStatusPending, models.TaskStatusAssigned}, now).
+		Order("due_at").
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
+
+// GetTaskExecutionsByProcessIDSince returns the task executions of the process's tasks that were assigned or completed
+// after the given time, along with those still open
+func (r *taskRepository) GetTaskExecutionsByProcessIDSince(processID uint, since time.Time) ([]models.TaskExecution, error) {
+	var taskExecutions []models.TaskExecution
+	if err := r.db.Preload("Task").
+		Joins("JOIN tasks ON tasks.id = task_executions.task_id").
+		Where("tasks.process_id = ?", processID).
+		Where("task_executions.assigned_at > ? OR task_executions.completed_at > ? OR task_executions.status IN ?", since, since,
+			[]models.TaskStatus{models.TaskStatusPending, models.TaskStatusAssigned, models.TaskStatusWaiting}).
+		Order("task_executions.created_at").
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
+
+// GetTaskExecutionsByTeamIDSince returns the task executions of the team's tasks that were assigned or completed after
+// the given time, along with those still open
+func (r *taskRepository) GetTaskExecutionsByTeamIDSince(teamID uint, since time.Time) ([]models.TaskExecution, error) {
+	var taskExecutions []models.TaskExecution
+	if err := r.db.Preload("Task.Process").Preload("ProcessExecution").Preload("User").
+		Joins("JOIN tasks ON tasks.id = task_executions.task_id").
+		Where("tasks.team_id = ?", teamID).
+		Where("task_executions.assigned_at > ? OR task_executions.completed_at > ? OR task_executions.status IN ?", since, since,
+			[]models.TaskStatus{models.TaskStatusPending, models.TaskStatusAssigned}).
+		Order("task_executions.created_at").
+		Find(&taskExecutions).Error; err != nil {
+		return nil, err
+	}
+	return taskExecutions, nil
+}
@@ -2,21 +2,25 @@ package repository
 
 import (
 	"bbb/internal/models"
+	"errors"
 
 	"gorm.io/gorm"
 )
 
 type (
 	TeamRepository interface {
-		Save(req models.Team) error
+		Save(req *models.Team) error
 		GetAll() ([]models.Team, error)
 		GetByJoinKey(joinKey string) (*models.Team, error)
+		GetByGroupChatID(chatID int64) (*models.Team, error)
 		GetMembers(teamID uint) ([]models.User, error)
 		GetByID(id uint) (*models.Team, error)
 		AddMember(teamID uint, userID int64) error
 		RemoveMember(teamID uint, userID int64) error
+		EnsureMember(teamID uint, userID int64) (bool, error)
 		SaveUserTeam(req models.UserTeams) error
 		GetTeamsByOwnerID(ownerID int64) ([]*models.Team, error)
+		SearchByUserID(userID int64, query string, limit int) ([]models.Team, error)
 	}
 
 	teamRepository struct {
@@ -30,13 +34,13 @@ func NewTeamRepository(db *gorm.DB) TeamRepository {
 	}
 }
 
-func (r *teamRepository) Save(req models.Team) error {
+func (r *teamRepository) Save(req *models.Team) error {
 	if req.ID == 0 {
-		if err := r.db.Create(&req).Error; err != nil {
+		if err := r.db.Create(req).Error; err != nil {
 			return err
 		}
 	} else {
-		if err := r.db.Save(&req).Error; err != nil {
+		if err := r.db.Save(req).Error; err != nil {
 			return err
 		}
 	}
@@ -60,6 +64,14 @@ func (r *teamRepository) GetByJoinKey(joinKey string) (*models.Team, error) {
 	return &team, nil
 }
 
+func (r *teamRepository) GetByGroupChatID(chatID int64) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.Where("group_chat_id = ?", chatID).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
 func (r *teamRepository) GetMembers(teamID uint) ([]models.User, error) {
 	var users []models.User
 	err := r.db.Joins("JOIN user_teams ON user_teams.user_id = users.id").
@@ -88,6 +100,25 @@ func (r *teamRepository) RemoveMember(teamID uint, userID int64) error {
 	return r.db.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.UserTeams{}).Error
 }
 
+// EnsureMember adds the user to the team unless they are already a member, bringing back a membership that was
+// removed before. It reports whether the user was added.
+func (r *teamRepository) EnsureMember(teamID uint, userID int64) (bool, error) {
+	var userTeam models.UserTeams
+	err := r.db.Unscoped().Where("team_id = ? AND user_id = ?", teamID, userID).First(&userTeam).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, r.AddMember(teamID, userID)
+	}
+	if err != nil {
+		return false, err
+	}
+	if !userTeam.DeletedAt.Valid {
+		return false, nil
+	}
+	return true, r.db.Unscoped().Model(&models.UserTeams{}).
+		Where("team_id = ? AND user_id = ?", teamID, userID).
+		Update("deleted_at", nil).Error
+}
+
 func (r *teamRepository) SaveUserTeam(req models.UserTeams) error {
 	return r.db.Create(&req).Error
 }
@@ -99,3 +130,15 @@ func (r *teamRepository) GetTeamsByOwnerID(ownerID int64) ([]*models.Team, error
 	}
 	return teams, nil
 }
+
+// SearchByUserID returns the teams the user owns or is a member of whose name contains the query
+func (r *teamRepository) SearchByUserID(userID int64, query string, limit int) ([]models.Team, error) {
+	memberTeamIDs := r.db.Model(&models.UserTeams{}).Select("team_id").Where("user_id = ?", userID)
+	db := r.db.Where("owner_id = ? OR id IN (?)", userID, memberTeamIDs)
+	if query != "" {
+		db = db.Where("name ILIKE ?", containsPattern(query))
+	}
+	var teams []models.Team
+	err := db.Order("name").Limit(limit).Find(&teams).Error
+	return teams, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"bbb/internal/models"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -12,6 +13,8 @@ type (
 		SaveUserTeam(req models.UserTeams) error
 		GetByID(userID int64) (*models.User, error)
 		Update(req *models.User) error
+		GetTeammates(userID int64) ([]models.User, error)
+		GetExpiredAway(now time.Time) ([]models.User, error)
 	}
 
 	userRepository struct {
@@ -47,3 +50,20 @@ func (r *userRepository) GetByID(userID int64) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// GetTeammates returns every user that shares at least one team with the given user
+func (r *userRepository) GetTeammates(userID int64) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Distinct("users.*").
+		Joins("JOIN user_teams AS member ON member.user_id = users.id").
+		Joins("JOIN user_teams AS self ON self.team_id = member.team_id").
+		Where("self.user_id = ? AND users.id <> ?", userID, userID).
+		Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) GetExpiredAway(now time.Time) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Where("away_until IS NOT NULL AND away_until <= ?", now).Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"bbb/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	WebhookRepository interface {
+		SaveSubscription(req *models.WebhookSubscription) error
+		GetSubscriptionByID(id uint) (*models.WebhookSubscription, error)
+		GetSubscriptionsByOwnerID(ownerID int64) ([]models.WebhookSubscription, error)
+		GetSubscriptionsForEvent(processID uint, teamID *uint) ([]models.WebhookSubscription, error)
+		DeleteSubscription(id uint) error
+		SaveDelivery(req *models.WebhookDelivery) error
+		UpdateDelivery(req *models.WebhookDelivery) error
+		GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+		GetDeliveriesBySubscriptionID(subscriptionID uint, limit int) ([]models.WebhookDelivery, error)
+	}
+
+	webhookRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
+
+func (r *webhookRepository) SaveSubscription(req *models.WebhookSubscription) error {
+	return r.db.Omit("Process", "Team").Create(req).Error
+}
+
+func (r *webhookRepository) GetSubscriptionByID(id uint) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := r.db.Preload("Process").Preload("Team").First(&subscription, id).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *webhookRepository) GetSubscriptionsByOwnerID(ownerID int64) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := r.db.Preload("Process").Preload("Team").Where("owner_id = ?", ownerID).Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// GetSubscriptionsForEvent returns the subscriptions to a process along with those to a team, when a team is given
+func (r *webhookRepository) GetSubscriptionsForEvent(processID uint, teamID *uint) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	query := r.db.Where("process_id = ?", processID)
+	if teamID != nil {
+		query = query.Or("team_id = ?", *teamID)
+	}
+	if err := query.Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a subscription along with its delivery log
+func (r *webhookRepository) DeleteSubscription(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.WebhookSubscription{}, id).Error
+	})
+}
+
+func (r *webhookRepository) SaveDelivery(req *models.WebhookDelivery) error {
+	return r.db.Omit("Subscription").Create(req).Error
+}
+
+func (r *webhookRepository) UpdateDelivery(req *models.WebhookDelivery) error {
+	return r.db.Omit("Subscription").Save(req).Error
+}
+
+// GetDueDeliveries returns the pending deliveries whose next attempt is due, oldest first
+func (r *webhookRepository) GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.Preload("Subscription").
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryStatusPending, now).
+		Order("id").Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetDeliveriesBySubscriptionID returns the latest deliveries of a subscription, newest first
+func (r *webhookRepository) GetDeliveriesBySubscriptionID(subscriptionID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.Where("subscription_id = ?", subscriptionID).Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// apiTokenPrefix marks the tokens issued by the bot, so leaked ones are easy to recognize
+const apiTokenPrefix = "bbb_"
+
+type (
+	APITokenService interface {
+		IssueToken(userID int64) (string, *models.APIToken, error)
+		Authenticate(token string) (int64, error)
+		GetUserTokens(userID int64) ([]models.APIToken, error)
+		RevokeToken(tokenID uint, userID int64) error
+	}
+
+	apiTokenService struct {
+		repo repository.APITokenRepository
+	}
+)
+
+func NewAPITokenService(repo repository.APITokenRepository) APITokenService {
+	return &apiTokenService{repo: repo}
+}
+
+// IssueToken creates a new API token for a user. The token itself is only returned here and can't be recovered later.
+func (s *apiTokenService) IssueToken(userID int64) (string, *models.APIToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(secret)
+
+	apiToken := &models.APIToken{
+		UserID:    userID,
+		TokenHash: hashAPIToken(token),
+		Prefix:    token[:len(apiTokenPrefix)+6],
+	}
+	if err := s.repo.Save(apiToken); err != nil {
+		return "", nil, err
+	}
+	return token, apiToken, nil
+}
+
+// Authenticate returns the user an API token was issued for
+func (s *apiTokenService) Authenticate(token string) (int64, error) {
+	if token == "" {
+		return 0, errors.New("api token is required")
+	}
+	apiToken, err := s.repo.GetByHash(hashAPIToken(token))
+	if err != nil {
+		return 0, errors.New("invalid api token")
+	}
+	if err := s.repo.SetLastUsed(apiToken.ID, time.Now()); err != nil {
+		log.Printf("Error updating last use of api token %d: %v", apiToken.ID, err)
+	}
+	return apiToken.UserID, nil
+}
+
+func (s *apiTokenService) GetUserTokens(userID int64) ([]models.APIToken, error) {
+	return s.repo.GetByUserID(userID)
+}
+
+func (s *apiTokenService) RevokeToken(tokenID uint, userID int64) error {
+	return s.repo.Delete(tokenID, userID)
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"errors"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type (
+	AttachmentService interface {
+		AddAttachment(attachment *models.TaskAttachment) error
+		GetAttachments(taskExecutionID uint) ([]models.TaskAttachment, error)
+		GetProcessExecutionAttachments(processExecutionID uint) ([]models.TaskAttachment, error)
+	}
+
+	attachmentService struct {
+		repo     repository.AttachmentRepository
+		taskRepo repository.TaskRepository
+	}
+)
+
+func NewAttachmentService(repo repository.AttachmentRepository, taskRepo repository.TaskRepository) AttachmentService {
+	return &attachmentService{
+		repo:     repo,
+		taskRepo: taskRepo,
+	}
+}
+
+// AddAttachment stores a file sent by the assignee of a task execution
+func (s *attachmentService) AddAttachment(attachment *models.TaskAttachment) error {
+	taskExecution, err := s.taskRepo.GetTaskExecutionByID(attachment.TaskExecutionID)
+	if err != nil {
+		return err
+	}
+	if taskExecution.Status != models.TaskStatusAssigned || taskExecution.UserID == nil || *taskExecution.UserID != attachment.UserID {
+		return errors.New("task is not assigned to you")
+	}
+	if attachment.FileID == "" {
+		return errors.New("file id is required")
+	}
+	return s.repo.Save(attachment)
+}
+
+func (s *attachmentService) GetAttachments(taskExecutionID uint) ([]models.TaskAttachment, error) {
+	return s.repo.GetByTaskExecutionID(taskExecutionID)
+}
+
+func (s *attachmentService) GetProcessExecutionAttachments(processExecutionID uint) ([]models.TaskAttachment, error) {
+	return s.repo.GetByProcessExecutionID(processExecutionID)
+}
+
+// sendFile sends a stored file to a chat with the method of its type. Form answers don't record what kind of file
+// they hold, so files without a type are sent as documents, and as photos when that fails: both share the file_id
+// space, but each has to be sent with its own method.
+func sendFile(bot *tgbotapi.BotAPI, chatID int64, fileType models.AttachmentType, fileID string, caption string) (tgbotapi.Message, error) {
+	file := tgbotapi.FileID(fileID)
+	switch fileType {
+	case "":
+		document := tgbotapi.NewDocument(chatID, file)
+		document.Caption = caption
+		sent, err := bot.Send(document)
+		if err == nil {
+			return sent, nil
+		}
+		photo := tgbotapi.NewPhoto(chatID, file)
+		photo.Caption = caption
+		return bot.Send(photo)
+	case models.AttachmentTypePhoto:
+		photo := tgbotapi.NewPhoto(chatID, file)
+		photo.Caption = caption
+		return bot.Send(photo)
+	case models.AttachmentTypeVoice:
+		voice := tgbotapi.NewVoice(chatID, file)
+		voice.Caption = caption
+		return bot.Send(voice)
+	case models.AttachmentTypeAudio:
+		audio := tgbotapi.NewAudio(chatID, file)
+		audio.Caption = caption
+		return bot.Send(audio)
+	case models.AttachmentTypeVideo:
+		video := tgbotapi.NewVideo(chatID, file)
+		video.Caption = caption
+		return bot.Send(video)
+	default:
+		document := tgbotapi.NewDocument(chatID, file)
+		document.Caption = caption
+		return bot.Send(document)
+	}
+}
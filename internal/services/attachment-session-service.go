@@ -0,0 +1,37 @@
+package service
+
+import "sync"
+
+// AttachmentSessionService keeps track of the task executions users are sending attachments for
+type AttachmentSessionService struct {
+	sessions map[int64]uint
+	mu       sync.RWMutex
+}
+
+func NewAttachmentSessionService() *AttachmentSessionService {
+	return &AttachmentSessionService{
+		sessions: make(map[int64]uint),
+	}
+}
+
+func (s *AttachmentSessionService) StartSession(userID int64, taskExecutionID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = taskExecutionID
+}
+
+func (s *AttachmentSessionService) GetSession(userID int64) (uint, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	taskExecutionID, exists := s.sessions[userID]
+	return taskExecutionID, exists
+}
+
+func (s *AttachmentSessionService) EndSession(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+}
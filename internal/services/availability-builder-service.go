@@ -0,0 +1,73 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// AvailabilityBuilderService handles the out-of-office setup flow
+type AvailabilityBuilderService struct {
+	builders map[int64]*AvailabilityBuilder
+	mu       sync.RWMutex
+}
+
+// AvailabilityBuilder represents the state of an away period being set
+type AvailabilityBuilder struct {
+	CurrentStep string // "until", "delegate"
+	Until       time.Time
+}
+
+// NewAvailabilityBuilderService creates a new AvailabilityBuilderService
+func NewAvailabilityBuilderService() *AvailabilityBuilderService {
+	return &AvailabilityBuilderService{
+		builders: make(map[int64]*AvailabilityBuilder),
+	}
+}
+
+// StartAway starts asking the user for the end of the away period
+func (s *AvailabilityBuilderService) StartAway(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builders[userID] = &AvailabilityBuilder{CurrentStep: "until"}
+}
+
+// GetBuilder returns the builder for a user if it exists
+func (s *AvailabilityBuilderService) GetBuilder(userID int64) (*AvailabilityBuilder, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	builder, exists := s.builders[userID]
+	return builder, exists
+}
+
+// SetUntil sets the end of the away period and moves on to choosing a delegate
+func (s *AvailabilityBuilderService) SetUntil(userID int64, until time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	builder, exists := s.builders[userID]
+	if !exists {
+		builder = &AvailabilityBuilder{}
+		s.builders[userID] = builder
+	}
+	builder.Until = until
+	builder.CurrentStep = "delegate"
+	return true
+}
+
+// CompleteAway returns the chosen end of the away period and clears the builder
+func (s *AvailabilityBuilderService) CompleteAway(userID int64) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	builder, exists := s.builders[userID]
+	if !exists || builder.CurrentStep != "delegate" {
+		return time.Time{}, false
+	}
+	delete(s.builders, userID)
+	return builder.Until, true
+}
+
+// Cancel drops any availability flow in progress for the user
+func (s *AvailabilityBuilderService) Cancel(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.builders, userID)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const maxCommentLength = 2000
+
+type (
+	CommentService interface {
+		AddTaskComment(taskExecutionID uint, userID int64, text string) (*models.Comment, error)
+		AddProcessExecutionComment(processExecutionID uint, userID int64, text string) (*models.Comment, error)
+		GetTaskComments(taskExecutionID uint, userID int64) ([]models.Comment, error)
+		GetProcessExecutionComments(processExecutionID uint, userID int64) ([]models.Comment, error)
+		GetNotifiedTaskExecutionID(chatID int64, messageID int) (uint, bool)
+	}
+
+	commentService struct {
+		repo           repository.CommentRepository
+		taskRepo       repository.TaskRepository
+		processService ProcessService
+		teamService    TeamService
+		userService    UserService
+		outboxService  OutboxService
+	}
+)
+
+func NewCommentService(
+	repo repository.CommentRepository,
+	taskRepo repository.TaskRepository,
+	processService ProcessService,
+	teamService TeamService,
+	userService UserService,
+	outboxService OutboxService,
+) CommentService {
+	return &commentService{
+		repo:           repo,
+		taskRepo:       taskRepo,
+		processService: processService,
+		teamService:    teamService,
+		userService:    userService,
+		outboxService:  outboxService,
+	}
+}
+
+// AddTaskComment posts a comment on a task execution and relays it to the assignee, the team and the process owner
+func (s *commentService) AddTaskComment(taskExecutionID uint, userID int64, text string) (*models.Comment, error) {
+	text, err := validateComment(text)
+	if err != nil {
+		return nil, err
+	}
+	taskExecution, participants, err := s.taskParticipants(taskExecutionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &models.Comment{
+		ProcessExecutionID: taskExecution.ProcessExecutionID,
+		TaskExecutionID:    &taskExecution.ID,
+		UserID:             userID,
+		Text:               text,
+	}
+	if err := s.repo.Save(comment); err != nil {
+		return nil, err
+	}
+
+	executionName := fmt.Sprintf("#%d", taskExecution.ProcessExecutionID)
+	if taskExecution.ProcessExecution != nil {
+		executionName = taskExecution.ProcessExecution.DisplayName()
+	}
+	relay := fmt.Sprintf("💬 نظر جدید درباره وظیفه «%s»\nفرایند: %s — %s\nاز طرف %s:\n\n%s",
+		taskExecution.Task.Title, taskExecution.Task.Process.Name, executionName, s.authorName(userID), text)
+	s.relay(participants, userID, relay, fmt.Sprintf("comment_task_%d", taskExecution.ID))
+	return comment, nil
+}
+
+// AddProcessExecutionComment posts a comment on a process execution and relays it to the process owner
+// and everyone who works on its tasks
+func (s *commentService) AddProcessExecutionComment(processExecutionID uint, userID int64, text string) (*models.Comment, error) {
+	text, err := validateComment(text)
+	if err != nil {
+		return nil, err
+	}
+	execution, process, participants, err := s.processParticipants(processExecutionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &models.Comment{
+		ProcessExecutionID: execution.ID,
+		UserID:             userID,
+		Text:               text,
+	}
+	if err := s.repo.Save(comment); err != nil {
+		return nil, err
+	}
+
+	relay := fmt.Sprintf("💬 نظر جدید درباره فرایند %s — %s\nاز طرف %s:\n\n%s",
+		process.Name, execution.DisplayName(), s.authorName(userID), text)
+	s.relay(participants, userID, relay, fmt.Sprintf("comment_execution_%d", execution.ID))
+	return comment, nil
+}
+
+func (s *commentService) GetTaskComments(taskExecutionID uint, userID int64) ([]models.Comment, error) {
+	if _, _, err := s.taskParticipants(taskExecutionID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByTaskExecutionID(taskExecutionID)
+}
+
+func (s *commentService) GetProcessExecutionComments(processExecutionID uint, userID int64) ([]models.Comment, error) {
+	if _, _, _, err := s.processParticipants(processExecutionID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByProcessExecutionID(processExecutionID)
+}
+
+// GetNotifiedTaskExecutionID returns the task execution a notification message was sent for
+func (s *commentService) GetNotifiedTaskExecutionID(chatID int64, messageID int) (uint, bool) {
+	notification, err := s.taskRepo.GetTaskNotificationByMessage(chatID, messageID)
+	if err != nil {
+		return 0, false
+	}
+	return notification.TaskExecutionID, true
+}
+
+// taskParticipants returns the assignee, the team members and the process owner of a task execution,
+// failing when the user is not one of them
+func (s *commentService) taskParticipants(taskExecutionID uint, userID int64) (*models.TaskExecution, map[int64]bool, error) {
+	taskExecution, err := s.taskRepo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	participants := map[int64]bool{taskExecution.Task.Process.UserID: true}
+	if taskExecution.UserID != nil {
+		participants[*taskExecution.UserID] = true
+	}
+	s.addTeamMembers(participants, taskExecution.Task.TeamID)
+	if !participants[userID] {
+		return nil, nil, errors.New("شما به گفتگوی این وظیفه دسترسی ندارید")
+	}
+	return taskExecution, participants, nil
+}
+
+// processParticipants returns the process owner and everyone who works on the tasks of a process execution,
+// failing when the user is not one of them
+func (s *commentService) processParticipants(processExecutionID uint, userID int64) (*models.ProcessExecution, *models.Process, map[int64]bool, error) {
+	execution, err := s.processService.GetProcessExecutionByID(processExecutionID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	process, err := s.processService.GetProcessByID(execution.ProcessID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	participants := map[int64]bool{process.UserID: true}
+	tasks, err := s.taskRepo.GetByProcessID(process.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, task := range tasks {
+		s.addTeamMembers(participants, task.TeamID)
+	}
+	taskExecutions, err := s.taskRepo.GetTaskExecutionsByProcessExecutionID(execution.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, taskExecution := range taskExecutions {
+		if taskExecution.UserID != nil {
+			participants[*taskExecution.UserID] = true
+		}
+	}
+	if !participants[userID] {
+		return nil, nil, nil, errors.New("شما به گفتگوی این فرایند دسترسی ندارید")
+	}
+	return execution, process, participants, nil
+}
+
+func (s *commentService) addTeamMembers(participants map[int64]bool, teamID *uint) {
+	if teamID == nil {
+		return
+	}
+	members, err := s.teamService.GetTeamMembers(*teamID)
+	if err != nil {
+		log.Printf("Error getting members of team %d: %v", *teamID, err)
+		return
+	}
+	for _, member := range members {
+		participants[member.ID] = true
+	}
+}
+
+// relay queues the comment for every participant but its author, so posting it never waits for the sends
+func (s *commentService) relay(participants map[int64]bool, authorID int64, text string, replyData string) {
+	keyboard, err := json.Marshal(tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ پاسخ", replyData),
+		),
+	))
+	if err != nil {
+		log.Printf("Error encoding comment keyboard: %v", err)
+		return
+	}
+	for userID := range participants {
+		if userID == authorID {
+			continue
+		}
+		message := models.OutboxMessage{ChatID: userID, Text: text, ReplyMarkup: string(keyboard), Status: models.OutboxStatusPending}
+		if err := s.outboxService.Enqueue(&message); err != nil {
+			log.Printf("Error relaying comment to user %d: %v", userID, err)
+		}
+	}
+}
+
+func (s *commentService) authorName(userID int64) string {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return fmt.Sprintf("%d", userID)
+	}
+	return userFullName(user)
+}
+
+func validateComment(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("متن نظر نمی‌تواند خالی باشد")
+	}
+	if utf8.RuneCountInString(text) > maxCommentLength {
+		return "", fmt.Errorf("متن نظر نباید بیشتر از %d نویسه باشد", maxCommentLength)
+	}
+	return text, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import "sync"
+
+// CommentSessionService keeps track of users writing a comment
+type CommentSessionService struct {
+	sessions map[int64]CommentTarget
+	mu       sync.RWMutex
+}
+
+// CommentTarget is the task execution, or the process execution when TaskExecutionID is zero, a comment is written on
+type CommentTarget struct {
+	TaskExecutionID    uint
+	ProcessExecutionID uint
+}
+
+func NewCommentSessionService() *CommentSessionService {
+	return &CommentSessionService{
+		sessions: make(map[int64]CommentTarget),
+	}
+}
+
+func (s *CommentSessionService) StartSession(userID int64, target CommentTarget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = target
+}
+
+func (s *CommentSessionService) GetSession(userID int64) (CommentTarget, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	target, exists := s.sessions[userID]
+	return target, exists
+}
+
+func (s *CommentSessionService) EndSession(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// digestListSize is the number of items listed in each section of a digest
+	digestListSize = 10
+	// digestTaskLimit is the number of open task executions checked for overdue items
+	digestTaskLimit = 100
+	// digestStalledAfter is how long an open process execution may go without task activity before the digest reports it
+	digestStalledAfter = 72 * time.Hour
+)
+
+type (
+	// DigestService sends users a scheduled summary of their open work instead of, or along with, instant notifications
+	DigestService interface {
+		BuildDigest(userID int64) (string, error)
+		SendDueDigests() error
+	}
+
+	digestService struct {
+		notificationService NotificationService
+		taskService         TaskService
+		processService      ProcessService
+		bot                 *tgbotapi.BotAPI
+	}
+)
+
+func NewDigestService(notificationService NotificationService, taskService TaskService, processService ProcessService, bot *tgbotapi.BotAPI) DigestService {
+	return &digestService{
+		notificationService: notificationService,
+		taskService:         taskService,
+		processService:      processService,
+		bot:                 bot,
+	}
+}
+
+// SendDueDigests sends the digests whose time has come and schedules the next ones
+func (s *digestService) SendDueDigests() error {
+	now := time.Now()
+	due, err := s.notificationService.GetDueDigests(now)
+	if err != nil {
+		return err
+	}
+	for i := range due {
+		settings := &due[i]
+		text, err := s.buildDigest(settings, now)
+		if err != nil {
+			log.Printf("Error building digest of user %d: %v", settings.UserID, err)
+			continue
+		}
+		// A digest that failed to send stays due, so the next tick sends it with its period intact
+		if _, err := s.bot.Send(tgbotapi.NewMessage(settings.UserID, text)); err != nil {
+			log.Printf("Error sending digest to user %d: %v", settings.UserID, err)
+			continue
+		}
+		if err := s.notificationService.MarkDigestSent(settings, now); err != nil {
+			log.Printf("Error scheduling next digest of user %d: %v", settings.UserID, err)
+		}
+	}
+	return nil
+}
+
+// BuildDigest returns the digest the user would receive now
+func (s *digestService) BuildDigest(userID int64) (string, error) {
+	settings, err := s.notificationService.GetSettings(userID)
+	if err != nil {
+		return "", err
+	}
+	return s.buildDigest(settings, time.Now())
+}
+
+// buildDigest lists the user's assigned tasks, the tasks waiting to be claimed in their teams, the overdue ones among them,
+// and the processes they own that completed since the last digest or stalled
+func (s *digestService) buildDigest(settings *models.NotificationSettings, now time.Time) (string, error) {
+	location := s.notificationService.Location(settings)
+	since := now.Add(-24 * time.Hour)
+	title := "📰 خلاصه‌ی روزانه"
+	if settings.DigestFrequency == models.DigestWeekly {
+		since = now.AddDate(0, 0, -7)
+		title = "📰 خلاصه‌ی هفتگی"
+	}
+	if settings.LastDigestAt != nil {
+		since = *settings.LastDigestAt
+	}
+
+	assigned, assignedTotal, err := s.taskService.ListUserTasks(settings.UserID, UserTaskFilterAssigned, 0, digestTaskLimit)
+	if err != nil {
+		return "", err
+	}
+	available, availableTotal, err := s.taskService.ListUserTasks(settings.UserID, UserTaskFilterAvailable, 0, digestTaskLimit)
+	if err != nil {
+		return "", err
+	}
+	completed, err := s.processService.GetCompletedExecutionsByOwnerID(settings.UserID, since)
+	if err != nil {
+		return "", err
+	}
+	stalled, err := s.processService.GetStalledExecutionsByOwnerID(settings.UserID, now.Add(-digestStalledAfter))
+	if err != nil {
+		return "", err
+	}
+
+	var overdue []models.TaskExecution
+	for _, taskExecutions := range [][]models.TaskExecution{assigned, available} {
+		for _, taskExecution := range taskExecutions {
+			if taskExecution.IsOverdue(now) {
+				overdue = append(overdue, taskExecution)
+			}
+		}
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("%s — %s\n", title, now.In(location).Format("2006-01-02")))
+	if assignedTotal == 0 && availableTotal == 0 && len(completed) == 0 && len(stalled) == 0 {
+		text.WriteString("\n✅ کار بازی برای شما نمانده است.")
+		return text.String(), nil
+	}
+
+	if assignedTotal > 0 {
+		text.WriteString(fmt.Sprintf("\n📌 وظایف در دست شما (%d):\n", assignedTotal))
+		writeDigestTasks(&text, assigned, int(assignedTotal), nil)
+	}
+	if availableTotal > 0 {
+		text.WriteString(fmt.Sprintf("\n🙋 وظایف منتظر برداشتن در تیم‌های شما (%d):\n", availableTotal))
+		writeDigestTasks(&text, available, int(availableTotal), nil)
+	}
+	if len(overdue) > 0 {
+		text.WriteString(fmt.Sprintf("\n⏰ کارهای عقب‌افتاده (%d):\n", len(overdue)))
+		writeDigestTasks(&text, overdue, len(overdue), location)
+	}
+	if len(completed) > 0 {
+		text.WriteString(fmt.Sprintf("\n✅ فرایندهای تکمیل شده از خلاصه‌ی قبلی (%d):\n", len(completed)))
+		writeDigestExecutions(&text, completed)
+	}
+	if len(stalled) > 0 {
+		text.WriteString(fmt.Sprintf("\n⚠️ فرایندهای بدون فعالیت در %d روز گذشته (%d):\n", int(digestStalledAfter.Hours()/24), len(stalled)))
+		writeDigestExecutions(&text, stalled)
+	}
+	text.WriteString("\nبرای دیدن و انجام وظایف از «وظایف من» استفاده کنید.")
+	return text.String(), nil
+}
+
+// writeDigestTasks lists task executions up to the digest list size. The deadline is shown when a location is given.
+func writeDigestTasks(text *strings.Builder, taskExecutions []models.TaskExecution, total int, location *time.Location) {
+	for i, taskExecution := range taskExecutions {
+		if i == digestListSize {
+			break
+		}
+		title := taskExecution.Task.Title
+		if taskExecution.InstanceItem != "" {
+			title += " — " + taskExecution.InstanceItem
+		}
+		line := fmt.Sprintf("• %s (%s)", title, executionName(&taskExecution))
+		if location != nil && taskExecution.DueAt != nil {
+			line += " — مهلت: " + taskExecution.DueAt.In(location).Format("2006-01-02 15:04")
+		}
+		text.WriteString(line + "\n")
+	}
+	if total > digestListSize {
+		text.WriteString(fmt.Sprintf("و %d مورد دیگر\n", total-digestListSize))
+	}
+}
+
+func writeDigestExecutions(text *strings.Builder, executions []models.ProcessExecution) {
+	for i, execution := range executions {
+		if i == digestListSize {
+			text.WriteString(fmt.Sprintf("و %d مورد دیگر\n", len(executions)-digestListSize))
+			break
+		}
+		processName := fmt.Sprintf("#%d", execution.ProcessID)
+		if execution.Process != nil {
+			processName = execution.Process.Name
+		}
+		text.WriteString(fmt.Sprintf("• %s — %s\n", processName, execution.DisplayName()))
+	}
+}
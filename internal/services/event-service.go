@@ -0,0 +1,327 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxDeliveryAttempts is how many times an event is sent before its delivery is given up
+	maxDeliveryAttempts = 8
+	// deliveryBackoff is the wait before the first retry of a failed delivery, doubled before every further retry
+	deliveryBackoff = 30 * time.Second
+	// deliveryBatchSize limits how many deliveries are sent on each run
+	deliveryBatchSize = 50
+	// maxDeliveryErrorLength limits how much of a failure is kept in the delivery log
+	maxDeliveryErrorLength = 500
+)
+
+// Lifecycle events posted to webhook subscriptions
+const (
+	EventProcessStarted   = "process.started"
+	EventProcessCompleted = "process.completed"
+	EventProcessCancelled = "process.cancelled"
+	EventProcessFailed    = "process.failed"
+	EventTaskActivated    = "task.activated"
+	EventTaskClaimed      = "task.claimed"
+	EventTaskCompleted    = "task.completed"
+	EventTaskOverdue      = "task.overdue"
+)
+
+type (
+	EventService interface {
+		PublishProcessEvent(event string, processExecution *models.ProcessExecution)
+		PublishTaskEvent(event string, taskExecution *models.TaskExecution)
+		Subscribe(subscription *models.WebhookSubscription) error
+		GetSubscriptions(ownerID int64) ([]models.WebhookSubscription, error)
+		DeleteSubscription(subscriptionID uint, ownerID int64) error
+		GetDeliveries(subscriptionID uint, ownerID int64, limit int) ([]models.WebhookDelivery, error)
+		DeliverDue() error
+	}
+
+	eventService struct {
+		repo           repository.WebhookRepository
+		processService ProcessService
+		teamService    TeamService
+		formService    TaskFormService
+		webhookService WebhookService
+	}
+
+	// EventPayload is the body posted to webhook subscriptions for every event
+	EventPayload struct {
+		Event              string                        `json:"event"`
+		OccurredAt         time.Time                     `json:"occurred_at"`
+		ProcessID          uint                          `json:"process_id"`
+		ProcessName        string                        `json:"process_name"`
+		ProcessExecutionID uint                          `json:"process_execution_id"`
+		ExecutionTitle     string                        `json:"execution_title"`
+		ExecutionStatus    models.ProcessExecutionStatus `json:"execution_status"`
+		Variables          map[string]string             `json:"variables"`
+		Task               *TaskEventData                `json:"task,omitempty"`
+	}
+
+	// TaskEventData carries the same information about a task execution as the messages announcing it
+	TaskEventData struct {
+		TaskID          uint              `json:"task_id"`
+		TaskExecutionID uint              `json:"task_execution_id"`
+		Title           string            `json:"title"`
+		Description     string            `json:"description"`
+		Status          models.TaskStatus `json:"status"`
+		TeamID          *uint             `json:"team_id"`
+		InstanceItem    string            `json:"instance_item,omitempty"`
+		AssigneeID      *int64            `json:"assignee_id"`
+		AssigneeName    string            `json:"assignee_name,omitempty"`
+		DueAt           *time.Time        `json:"due_at"`
+		CompletedAt     *time.Time        `json:"completed_at"`
+		Answers         map[string]string `json:"answers"` // Form answers given so far in the process execution, by field label
+	}
+)
+
+func NewEventService(
+	repo repository.WebhookRepository,
+	processService ProcessService,
+	teamService TeamService,
+	formService TaskFormService,
+	webhookService WebhookService,
+) EventService {
+	return &eventService{
+		repo:           repo,
+		processService: processService,
+		teamService:    teamService,
+		formService:    formService,
+		webhookService: webhookService,
+	}
+}
+
+// PublishProcessEvent queues an event of a process execution for the subscriptions to its process
+func (s *eventService) PublishProcessEvent(event string, processExecution *models.ProcessExecution) {
+	payload, err := s.processPayload(event, processExecution)
+	if err != nil {
+		log.Printf("Error building %s event of process execution %d: %v", event, processExecution.ID, err)
+		return
+	}
+	s.enqueue(payload, nil)
+}
+
+// PublishTaskEvent queues an event of a task execution for the subscriptions to its process and to its team.
+// The task execution is expected to come with its task and process execution.
+func (s *eventService) PublishTaskEvent(event string, taskExecution *models.TaskExecution) {
+	if taskExecution.Task == nil {
+		log.Printf("Error building %s event of task execution %d: task not loaded", event, taskExecution.ID)
+		return
+	}
+	processExecution := taskExecution.ProcessExecution
+	if processExecution == nil {
+		var err error
+		if processExecution, err = s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID); err != nil {
+			log.Printf("Error getting process execution %d: %v", taskExecution.ProcessExecutionID, err)
+			return
+		}
+	}
+	payload, err := s.processPayload(event, processExecution)
+	if err != nil {
+		log.Printf("Error building %s event of task execution %d: %v", event, taskExecution.ID, err)
+		return
+	}
+
+	task := taskExecution.Task
+	data := &TaskEventData{
+		TaskID:          task.ID,
+		TaskExecutionID: taskExecution.ID,
+		Title:           RenderVariables(task.Title, payload.Variables),
+		Description:     RenderVariables(task.Description, payload.Variables),
+		Status:          taskExecution.Status,
+		TeamID:          task.TeamID,
+		InstanceItem:    taskExecution.InstanceItem,
+		AssigneeID:      taskExecution.UserID,
+		DueAt:           taskExecution.DueAt,
+		CompletedAt:     taskExecution.CompletedAt,
+		Answers:         make(map[string]string),
+	}
+	if taskExecution.User != nil {
+		data.AssigneeName = userFullName(taskExecution.User)
+	}
+	answers, err := s.formService.GetProcessExecutionAnswers(processExecution.ID)
+	if err != nil {
+		log.Printf("Error getting form answers of process execution %d: %v", processExecution.ID, err)
+	}
+	for i := range answers {
+		if answers[i].Field != nil {
+			data.Answers[answers[i].Field.Label] = answers[i].DisplayValue()
+		}
+	}
+	payload.Task = data
+	s.enqueue(payload, task.TeamID)
+}
+
+func (s *eventService) processPayload(event string, processExecution *models.ProcessExecution) (*EventPayload, error) {
+	process := processExecution.Process
+	if process == nil {
+		var err error
+		if process, err = s.processService.GetProcessByID(processExecution.ProcessID); err != nil {
+			return nil, err
+		}
+	}
+	variables, err := s.processService.GetVariables(processExecution.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &EventPayload{
+		Event:              event,
+		OccurredAt:         time.Now(),
+		ProcessID:          process.ID,
+		ProcessName:        process.Name,
+		ProcessExecutionID: processExecution.ID,
+		ExecutionTitle:     processExecution.Title,
+		ExecutionStatus:    processExecution.Status,
+		Variables:          variables,
+	}, nil
+}
+
+// enqueue stores a delivery of the payload for every matching subscription; they are sent by DeliverDue
+func (s *eventService) enqueue(payload *EventPayload, teamID *uint) {
+	subscriptions, err := s.repo.GetSubscriptionsForEvent(payload.ProcessID, teamID)
+	if err != nil {
+		log.Printf("Error getting webhook subscriptions for %s: %v", payload.Event, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", payload.Event, err)
+		return
+	}
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			Event:          payload.Event,
+			Payload:        string(body),
+			Status:         models.WebhookDeliveryStatusPending,
+			NextAttemptAt:  &now,
+		}
+		if err := s.repo.SaveDelivery(delivery); err != nil {
+			log.Printf("Error queuing %s event for subscription %d: %v", payload.Event, subscription.ID, err)
+		}
+	}
+}
+
+// Subscribe registers a subscription to a process or a team owned by the subscriber. A signing secret is
+// generated for the subscription, to be shown to the owner once.
+func (s *eventService) Subscribe(subscription *models.WebhookSubscription) error {
+	if (subscription.ProcessID == nil) == (subscription.TeamID == nil) {
+		return errors.New("اشتراک باید برای یک فرایند یا یک تیم باشد")
+	}
+	if err := ValidateWebhookURL(subscription.URL); err != nil {
+		return err
+	}
+
+	if subscription.ProcessID != nil {
+		process, err := s.processService.GetProcessByID(*subscription.ProcessID)
+		if err != nil || process == nil || process.UserID != subscription.OwnerID {
+			return errors.New("فقط مالک فرایند می‌تواند برای آن وب‌هوک ثبت کند")
+		}
+	} else {
+		team, err := s.teamService.GetTeamByID(*subscription.TeamID)
+		if err != nil || team == nil || team.OwnerID != subscription.OwnerID {
+			return errors.New("فقط مالک تیم می‌تواند برای آن وب‌هوک ثبت کند")
+		}
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	subscription.Secret = hex.EncodeToString(secret)
+	return s.repo.SaveSubscription(subscription)
+}
+
+func (s *eventService) GetSubscriptions(ownerID int64) ([]models.WebhookSubscription, error) {
+	return s.repo.GetSubscriptionsByOwnerID(ownerID)
+}
+
+func (s *eventService) DeleteSubscription(subscriptionID uint, ownerID int64) error {
+	if _, err := s.ownedSubscription(subscriptionID, ownerID); err != nil {
+		return err
+	}
+	return s.repo.DeleteSubscription(subscriptionID)
+}
+
+// GetDeliveries returns the latest deliveries of a subscription of the owner, newest first
+func (s *eventService) GetDeliveries(subscriptionID uint, ownerID int64, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.ownedSubscription(subscriptionID, ownerID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveriesBySubscriptionID(subscriptionID, limit)
+}
+
+func (s *eventService) ownedSubscription(subscriptionID uint, ownerID int64) (*models.WebhookSubscription, error) {
+	subscription, err := s.repo.GetSubscriptionByID(subscriptionID)
+	if err != nil || subscription.OwnerID != ownerID {
+		return nil, errors.New("اشتراک وب‌هوک یافت نشد")
+	}
+	return subscription, nil
+}
+
+// DeliverDue sends the queued events whose attempt is due. Failed deliveries are retried with a doubling wait
+// until maxDeliveryAttempts is reached.
+func (s *eventService) DeliverDue() error {
+	deliveries, err := s.repo.GetDueDeliveries(time.Now(), deliveryBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if delivery.Subscription == nil {
+			delivery.Status = models.WebhookDeliveryStatusFailed
+			delivery.LastError = "subscription not found"
+		} else {
+			s.attemptDelivery(delivery)
+		}
+		if err := s.repo.UpdateDelivery(delivery); err != nil {
+			log.Printf("Error updating webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *eventService) attemptDelivery(delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := s.webhookService.Deliver(delivery.Subscription.URL, delivery.Subscription.Secret, []byte(delivery.Payload))
+	delivery.ResponseStatus = statusCode
+	now := time.Now()
+	if err == nil {
+		delivery.Status = models.WebhookDeliveryStatusDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = truncateRunes(err.Error(), maxDeliveryErrorLength)
+	if delivery.Attempts >= maxDeliveryAttempts {
+		delivery.Status = models.WebhookDeliveryStatusFailed
+		delivery.NextAttemptAt = nil
+		log.Printf("Giving up webhook delivery %d after %d attempts: %v", delivery.ID, delivery.Attempts, err)
+		return
+	}
+	next := now.Add(deliveryBackoff << (delivery.Attempts - 1))
+	delivery.NextAttemptAt = &next
+}
+
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit]) + "…"
+}
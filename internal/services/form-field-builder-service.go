@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"sync"
+)
+
+// FormFieldBuilderService manages the state of form fields being added to tasks
+type FormFieldBuilderService struct {
+	builders map[int64]*FormFieldBuilder
+	mu       sync.RWMutex
+}
+
+// FormFieldBuilder represents a form field being defined by a process owner
+type FormFieldBuilder struct {
+	CurrentStep string // "label", "variable", "type", "options", "required"
+	Field       models.TaskFormField
+}
+
+func NewFormFieldBuilderService() *FormFieldBuilderService {
+	return &FormFieldBuilderService{
+		builders: make(map[int64]*FormFieldBuilder),
+	}
+}
+
+func (s *FormFieldBuilderService) StartField(userID int64, taskID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.builders[userID] = &FormFieldBuilder{
+		CurrentStep: "label",
+		Field:       models.TaskFormField{TaskID: taskID, Required: true},
+	}
+}
+
+// StartProcessField starts defining a field of the start form of a process
+func (s *FormFieldBuilderService) StartProcessField(userID int64, processID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.builders[userID] = &FormFieldBuilder{
+		CurrentStep: "label",
+		Field:       models.TaskFormField{ProcessID: processID, Required: true},
+	}
+}
+
+func (s *FormFieldBuilderService) GetBuilder(userID int64) (*FormFieldBuilder, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	builder, exists := s.builders[userID]
+	return builder, exists
+}
+
+func (s *FormFieldBuilderService) SetLabel(userID int64, label string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "label" {
+		builder.Field.Label = label
+		builder.CurrentStep = "variable"
+		return true
+	}
+	return false
+}
+
+// SetVariable sets the process variable the answers are stored in, an empty name keeps the label
+func (s *FormFieldBuilderService) SetVariable(userID int64, variable string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "variable" {
+		builder.Field.Variable = variable
+		builder.CurrentStep = "type"
+		return true
+	}
+	return false
+}
+
+func (s *FormFieldBuilderService) SetType(userID int64, fieldType models.FormFieldType) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "type" {
+		builder.Field.Type = fieldType
+		if fieldType == models.FormFieldTypeChoice {
+			builder.CurrentStep = "options"
+		} else {
+			builder.CurrentStep = "required"
+		}
+		return true
+	}
+	return false
+}
+
+func (s *FormFieldBuilderService) SetOptions(userID int64, options string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "options" {
+		builder.Field.Options = options
+		builder.CurrentStep = "required"
+		return true
+	}
+	return false
+}
+
+func (s *FormFieldBuilderService) CompleteField(userID int64, required bool) (*models.TaskFormField, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "required" {
+		field := builder.Field
+		field.Required = required
+		delete(s.builders, userID)
+		return &field, true
+	}
+	return nil, false
+}
+
+func (s *FormFieldBuilderService) CancelField(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.builders, userID)
+}
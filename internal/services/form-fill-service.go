@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"sync"
+)
+
+// FormFillService keeps track of users walking through the form of a task they are completing,
+// or the start form of a process they are starting
+type FormFillService struct {
+	sessions map[int64]*FormFillSession
+	mu       sync.RWMutex
+}
+
+// FormFillSession represents a task form, or a process start form, being filled in chat
+type FormFillSession struct {
+	TaskExecutionID uint
+	ProcessID       uint // Set instead of TaskExecutionID for start forms
+	Title           string
+	Fields          []models.TaskFormField
+	Answers         []models.TaskFormAnswer
+	Index           int    // Index of the field currently being asked
+	CurrentStep     string // "title", "field", "note"; start forms are complete when they reach "note"
+	Note            string
+}
+
+func NewFormFillService() *FormFillService {
+	return &FormFillService{
+		sessions: make(map[int64]*FormFillSession),
+	}
+}
+
+func (s *FormFillService) StartForm(userID int64, taskExecutionID uint, fields []models.TaskFormField) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = &FormFillSession{
+		TaskExecutionID: taskExecutionID,
+		Fields:          fields,
+		Answers:         make([]models.TaskFormAnswer, 0, len(fields)),
+		CurrentStep:     "field",
+	}
+}
+
+// StartProcessForm starts asking the execution title and the start form of a process
+func (s *FormFillService) StartProcessForm(userID int64, processID uint, fields []models.TaskFormField) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = &FormFillSession{
+		ProcessID:   processID,
+		Fields:      fields,
+		Answers:     make([]models.TaskFormAnswer, 0, len(fields)),
+		CurrentStep: "title",
+	}
+}
+
+func (s *FormFillService) SetTitle(userID int64, title string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, exists := s.sessions[userID]; exists && session.CurrentStep == "title" {
+		session.Title = title
+		s.startFields(session)
+		return true
+	}
+	return false
+}
+
+func (s *FormFillService) GetSession(userID int64) (*FormFillSession, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[userID]
+	return session, exists
+}
+
+// CurrentField returns the field the user is being asked, if any
+func (s *FormFillService) CurrentField(userID int64) (*models.TaskFormField, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[userID]
+	if !exists || session.CurrentStep != "field" || session.Index >= len(session.Fields) {
+		return nil, false
+	}
+	return &session.Fields[session.Index], true
+}
+
+// Answer records the value of the current field and moves on, switching to the note step after the last field
+func (s *FormFillService) Answer(userID int64, value string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[userID]
+	if !exists || session.CurrentStep != "field" || session.Index >= len(session.Fields) {
+		return false
+	}
+	field := session.Fields[session.Index]
+	session.Answers = append(session.Answers, models.TaskFormAnswer{FieldID: field.ID, Field: &field, Value: value})
+	s.advance(session)
+	return true
+}
+
+// Skip moves past the current field when it is optional, or finishes the note step
+func (s *FormFillService) Skip(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[userID]
+	if !exists {
+		return false
+	}
+	switch session.CurrentStep {
+	case "note":
+		return true
+	case "title":
+		s.startFields(session)
+		return true
+	}
+	if session.Index >= len(session.Fields) || session.Fields[session.Index].Required {
+		return false
+	}
+	s.advance(session)
+	return true
+}
+
+func (s *FormFillService) SetNote(userID int64, note string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, exists := s.sessions[userID]; exists && session.CurrentStep == "note" {
+		session.Note = note
+		return true
+	}
+	return false
+}
+
+func (s *FormFillService) CompleteForm(userID int64) (*FormFillSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[userID]
+	if !exists || session.CurrentStep != "note" {
+		return nil, false
+	}
+	delete(s.sessions, userID)
+	return session, true
+}
+
+func (s *FormFillService) CancelForm(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+}
+
+func (s *FormFillService) startFields(session *FormFillSession) {
+	if len(session.Fields) == 0 {
+		session.CurrentStep = "note"
+	} else {
+		session.CurrentStep = "field"
+	}
+}
+
+func (s *FormFillService) advance(session *FormFillSession) {
+	session.Index++
+	if session.Index >= len(session.Fields) {
+		session.CurrentStep = "note"
+	}
+}
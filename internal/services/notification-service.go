@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"errors"
+	"time"
+)
+
+type (
+	NotificationService interface {
+		GetSettings(userID int64) (*models.NotificationSettings, error)
+		SetTimeZone(userID int64, timeZone string) error
+		SetDigestFrequency(userID int64, frequency models.DigestFrequency) error
+		SetDigestHour(userID int64, hour int) error
+		SetDigestWeekday(userID int64, weekday int) error
+		SetDelivery(userID int64, event models.NotificationEvent, delivery models.NotificationDelivery) error
+		SetQuietHours(userID int64, enabled bool, start int, end int) error
+		Schedule(userID int64, event models.NotificationEvent, now time.Time) (time.Time, bool)
+		DeliverNow(userID int64, event models.NotificationEvent) bool
+		Notify(userID int64, event models.NotificationEvent, text string) error
+		Location(settings *models.NotificationSettings) *time.Location
+		GetDueDigests(now time.Time) ([]models.NotificationSettings, error)
+		MarkDigestSent(settings *models.NotificationSettings, sentAt time.Time) error
+	}
+
+	notificationService struct {
+		repo          repository.NotificationRepository
+		outboxService OutboxService
+		location      *time.Location
+	}
+)
+
+// NewNotificationService creates the notification settings service. Notifications are queued in the outbox,
+// and users without a time zone of their own use location.
+func NewNotificationService(repo repository.NotificationRepository, outboxService OutboxService, location *time.Location) NotificationService {
+	if location == nil {
+		location = time.Local
+	}
+	return &notificationService{
+		repo:          repo,
+		outboxService: outboxService,
+		location:      location,
+	}
+}
+
+// GetSettings returns the user's settings, or the defaults when the user never changed them
+func (s *notificationService) GetSettings(userID int64) (*models.NotificationSettings, error) {
+	settings, err := s.repo.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.CreatedAt.IsZero() {
+		settings.DigestFrequency = models.DigestOff
+		settings.DigestHour = 8
+		settings.DigestWeekday = int(time.Saturday)
+		settings.TeamTaskDelivery = models.NotificationInstant
+		settings.ProcessDelivery = models.NotificationInstant
+		settings.ReassignDelivery = models.NotificationInstant
+		settings.ReminderDelivery = models.NotificationInstant
+		settings.QuietStart = 22
+		settings.QuietEnd = 7
+	}
+	return settings, nil
+}
+
+func (s *notificationService) SetTimeZone(userID int64, timeZone string) error {
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return errors.New("منطقه زمانی نامعتبر است")
+	}
+	return s.update(userID, func(settings *models.NotificationSettings) {
+		settings.TimeZone = timeZone
+	})
+}
+
+func (s *notificationService) SetDigestFrequency(userID int64, frequency models.DigestFrequency) error {
+	if frequency != models.DigestOff && frequency != models.DigestDaily && frequency != models.DigestWeekly {
+		return errors.New("دوره‌ی خلاصه نامعتبر است")
+	}
+	return s.update(userID, func(settings *models.NotificationSettings) {
+		settings.DigestFrequency = frequency
+	})
+}
+
+func (s *notificationService) SetDigestHour(userID int64, hour int) error {
+	if hour < 0 || hour > 23 {
+		return errors.New("ساعت خلاصه باید بین ۰ تا ۲۳ باشد")
+	}
+	return s.update(userID, func(settings *models.NotificationSettings) {
+		settings.DigestHour = hour
+	})
+}
+
+func (s *notificationService) SetDigestWeekday(userID int64, weekday int) error {
+	if weekday < 0 || weekday > 6 {
+		return errors.New("روز خلاصه نامعتبر است")
+	}
+	return s.update(userID, func(settings *models.NotificationSettings) {
+		settings.DigestWeekday = weekday
+	})
+}
+
+func (s *notificationService) SetDelivery(userID int64, event models.NotificationEvent, delivery models.NotificationDelivery) error {
+	if !knownNotificationEvent(event) {
+		return errors.New("نوع اعلان نامعتبر است")
+	}
+	switch delivery {
+	case models.NotificationInstant, models.NotificationOff:
+	case models.NotificationDigest:
+		if !event.InDigest() {
+			return errors.New("این اعلان در خلاصه نمی‌آید")
+		}
+	default:
+		return errors.New("نحوه‌ی اعلان نامعتبر است")
+	}
+	return s.update(userID, func(settings *models.NotificationSettings) {
+		settings.SetDelivery(event, delivery)
+	})
+}
+
+func (s *notificationService) SetQuietHours(userID int64, enabled bool, start int, end int) error {
+	if start < 0 || start > 23 || end < 0 || end > 23 {
+		return errors.New("ساعت باید بین ۰ تا ۲۳ باشد")
+	}
+	if enabled && start == end {
+		return errors.New("شروع و پایان ساعات سکوت نمی‌توانند یکی باشند")
+	}
+	return s.update(userID, func(settings *models.NotificationSettings) {
+		settings.QuietHours = enabled
+		settings.QuietStart = start
+		settings.QuietEnd = end
+	})
+}
+
+// Schedule returns when a notification of the event should reach the user: now, or when their quiet hours end.
+// It reports false when the user turned the event off or left it to the digest. Users are notified right away
+// when their settings can't be read, so nothing is lost to a database error.
+func (s *notificationService) Schedule(userID int64, event models.NotificationEvent, now time.Time) (time.Time, bool) {
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return now, true
+	}
+	if settings.Delivery(event) != models.NotificationInstant {
+		return time.Time{}, false
+	}
+
+	local := now.In(s.Location(settings))
+	if !settings.InQuietHours(local.Hour()) {
+		return now, true
+	}
+	end := time.Date(local.Year(), local.Month(), local.Day(), settings.QuietEnd, 0, 0, 0, local.Location())
+	if !end.After(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, true
+}
+
+// DeliverNow reports whether a notification of the event may be sent to the user right now
+func (s *notificationService) DeliverNow(userID int64, event models.NotificationEvent) bool {
+	now := time.Now()
+	deliverAt, ok := s.Schedule(userID, event, now)
+	return ok && !deliverAt.After(now)
+}
+
+// Notify queues a message about the event for the user by their settings: sent now, held until their quiet hours end,
+// or dropped when they turned the event off or left it to the digest
+func (s *notificationService) Notify(userID int64, event models.NotificationEvent, text string) error {
+	deliverAt, ok := s.Schedule(userID, event, time.Now())
+	if !ok {
+		return nil
+	}
+	return s.outboxService.Enqueue(&models.OutboxMessage{
+		ChatID:        userID,
+		Text:          text,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: &deliverAt,
+	})
+}
+
+// Location returns the time zone of the user's settings
+func (s *notificationService) Location(settings *models.NotificationSettings) *time.Location {
+	if settings.TimeZone != "" {
+		if location, err := time.LoadLocation(settings.TimeZone); err == nil {
+			return location
+		}
+	}
+	return s.location
+}
+
+func (s *notificationService) GetDueDigests(now time.Time) ([]models.NotificationSettings, error) {
+	return s.repo.GetDueDigests(now)
+}
+
+// MarkDigestSent records a sent digest and schedules the next one
+func (s *notificationService) MarkDigestSent(settings *models.NotificationSettings, sentAt time.Time) error {
+	settings.LastDigestAt = &sentAt
+	s.scheduleDigest(settings, sentAt)
+	return s.repo.SaveSettings(settings)
+}
+
+// update changes the user's settings and schedules the next digest by the new settings
+func (s *notificationService) update(userID int64, change func(settings *models.NotificationSettings)) error {
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return err
+	}
+	change(settings)
+	s.scheduleDigest(settings, time.Now())
+	return s.repo.SaveSettings(settings)
+}
+
+// scheduleDigest sets when the next digest after the given time is due, at the digest hour of the user's time zone
+func (s *notificationService) scheduleDigest(settings *models.NotificationSettings, after time.Time) {
+	if settings.DigestFrequency == models.DigestOff {
+		settings.NextDigestAt = nil
+		return
+	}
+	local := after.In(s.Location(settings))
+	next := time.Date(local.Year(), local.Month(), local.Day(), settings.DigestHour, 0, 0, 0, local.Location())
+	for !next.After(after) || (settings.DigestFrequency == models.DigestWeekly && next.Weekday() != time.Weekday(settings.DigestWeekday)) {
+		next = next.AddDate(0, 0, 1)
+	}
+	settings.NextDigestAt = &next
+}
+
+func knownNotificationEvent(event models.NotificationEvent) bool {
+	for _, known := range models.NotificationEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
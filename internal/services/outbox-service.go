@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxOutboxAttempts is how many times a message is sent before it is moved to the dead letters
+	maxOutboxAttempts = 6
+	// outboxBackoff is the wait before the first retry of a failed message, doubled before every further retry
+	outboxBackoff = 10 * time.Second
+	// outboxBatchSize limits how many messages are sent on each run
+	outboxBatchSize = 100
+)
+
+type (
+	OutboxService interface {
+		Enqueue(message *models.OutboxMessage) error
+		Wake()
+		Pending() <-chan struct{}
+		Dispatch() error
+		GetDeadMessages(ownerID int64, limit int) ([]models.OutboxMessage, error)
+		Retry(messageID uint, ownerID int64) error
+		SetTaskRenderer(renderer TaskNotificationRenderer)
+	}
+
+	// TaskNotificationRenderer builds the announcement of a task execution for a chat. Announcements are rendered
+	// when they are sent rather than when they are queued, so they show the task as it is by then. It reports false
+	// when the announcement is not worth sending anymore.
+	TaskNotificationRenderer interface {
+		RenderTaskNotification(taskExecutionID uint, chatID int64) (tgbotapi.MessageConfig, bool, error)
+	}
+
+	outboxService struct {
+		repo     repository.OutboxRepository
+		taskRepo repository.TaskRepository
+		renderer TaskNotificationRenderer
+		bot      *tgbotapi.BotAPI
+		pending  chan struct{}
+	}
+)
+
+func NewOutboxService(repo repository.OutboxRepository, taskRepo repository.TaskRepository, bot *tgbotapi.BotAPI) OutboxService {
+	return &outboxService{
+		repo:     repo,
+		taskRepo: taskRepo,
+		bot:      bot,
+		pending:  make(chan struct{}, 1),
+	}
+}
+
+// SetTaskRenderer sets what renders the task announcements. The task service queues its announcements here,
+// so it can only be set once both exist.
+func (s *outboxService) SetTaskRenderer(renderer TaskNotificationRenderer) {
+	s.renderer = renderer
+}
+
+// Enqueue queues a message on its own, outside of the transaction of a state change.
+// Messages without a delivery time are sent right away.
+func (s *outboxService) Enqueue(message *models.OutboxMessage) error {
+	if message.NextAttemptAt == nil {
+		now := time.Now()
+		message.NextAttemptAt = &now
+	}
+	if err := s.repo.Save(message); err != nil {
+		return err
+	}
+	if !message.NextAttemptAt.After(time.Now()) {
+		s.Wake()
+	}
+	return nil
+}
+
+// Wake tells the dispatcher that new messages were queued, so they are sent without waiting for the next run
+func (s *outboxService) Wake() {
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+}
+
+// Pending receives a value whenever Wake is called
+func (s *outboxService) Pending() <-chan struct{} {
+	return s.pending
+}
+
+// Dispatch sends the queued messages whose attempt is due. Failed messages are retried with a doubling wait;
+// messages that keep failing, or that can never be delivered, become dead letters and the process owner is told.
+func (s *outboxService) Dispatch() error {
+	messages, err := s.repo.GetDueMessages(time.Now(), outboxBatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		message := &messages[i]
+		s.send(message)
+		if err := s.repo.Update(message); err != nil {
+			log.Printf("Error updating outbox message %d: %v", message.ID, err)
+			continue
+		}
+		if message.Status == models.OutboxStatusDead {
+			s.notifyOwner(message)
+		}
+	}
+	return nil
+}
+
+func (s *outboxService) send(message *models.OutboxMessage) {
+	message.Attempts++
+	sent, ok, err := s.deliver(message)
+	now := time.Now()
+	if err == nil && !ok {
+		message.Status = models.OutboxStatusSkipped
+		message.NextAttemptAt = nil
+		return
+	}
+	if err == nil {
+		message.Status = models.OutboxStatusSent
+		message.MessageID = sent.MessageID
+		message.SentAt = &now
+		message.NextAttemptAt = nil
+		message.LastError = ""
+		if message.TaskExecutionID != nil {
+			notification := models.TaskNotification{
+				TaskExecutionID: *message.TaskExecutionID,
+				ChatID:          message.ChatID,
+				MessageID:       sent.MessageID,
+			}
+			if err := s.taskRepo.SaveTaskNotification(&notification); err != nil {
+				log.Printf("Error saving notification of task execution %d for user %d: %v", *message.TaskExecutionID, message.ChatID, err)
+			}
+		}
+		return
+	}
+
+	message.LastError = truncateRunes(err.Error(), maxDeliveryErrorLength)
+	wait := outboxBackoff << (message.Attempts - 1)
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		// The recipient blocked the bot or never started it, so retrying can't help
+		case apiErr.Code == 400 || apiErr.Code == 403:
+			message.Attempts = maxOutboxAttempts
+		case apiErr.RetryAfter > 0:
+			wait = time.Duration(apiErr.RetryAfter) * time.Second
+		}
+	}
+	if message.Attempts >= maxOutboxAttempts {
+		message.Status = models.OutboxStatusDead
+		message.NextAttemptAt = nil
+		log.Printf("Outbox message %d to %d is dead after %d attempts: %v", message.ID, message.ChatID, message.Attempts, err)
+		return
+	}
+	next := now.Add(wait)
+	message.NextAttemptAt = &next
+}
+
+// deliver sends a message to its chat. Task announcements are rendered from the task execution as it is now,
+// and are not sent at all when the task was claimed or closed while they were queued, e.g. during quiet hours.
+// It reports false when nothing was sent.
+func (s *outboxService) deliver(message *models.OutboxMessage) (tgbotapi.Message, bool, error) {
+	if message.TaskExecutionID != nil && s.renderer != nil {
+		msg, ok, err := s.renderer.RenderTaskNotification(*message.TaskExecutionID, message.ChatID)
+		if err != nil || !ok {
+			return tgbotapi.Message{}, false, err
+		}
+		sent, err := s.bot.Send(msg)
+		return sent, true, err
+	}
+	if message.FileID != "" {
+		sent, err := sendFile(s.bot, message.ChatID, message.FileType, message.FileID, message.Text)
+		return sent, true, err
+	}
+
+	msg := tgbotapi.NewMessage(message.ChatID, message.Text)
+	if message.ReplyMarkup != "" {
+		var keyboard tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(message.ReplyMarkup), &keyboard); err != nil {
+			log.Printf("Error decoding keyboard of outbox message %d: %v", message.ID, err)
+		} else {
+			msg.ReplyMarkup = keyboard
+		}
+	}
+	sent, err := s.bot.Send(msg)
+	return sent, true, err
+}
+
+// notifyOwner tells the process owner that a message could not be delivered. The owner is messaged directly,
+// since queuing the notice could fail the same way.
+func (s *outboxService) notifyOwner(message *models.OutboxMessage) {
+	if message.OwnerID == 0 || message.OwnerID == message.ChatID {
+		return
+	}
+	text := fmt.Sprintf("⚠️ پیامی به کاربر %d پس از %d تلاش ارسال نشد.\nخطا: %s\n\nبرای دیدن پیام‌های ارسال نشده و ارسال دوباره‌ی آن‌ها /outbox را بفرستید.",
+		message.ChatID, message.Attempts, message.LastError)
+	if _, err := s.bot.Send(tgbotapi.NewMessage(message.OwnerID, text)); err != nil {
+		log.Printf("Error telling owner %d about dead outbox message %d: %v", message.OwnerID, message.ID, err)
+	}
+}
+
+// GetDeadMessages returns the latest messages of the owner's processes that could not be delivered
+func (s *outboxService) GetDeadMessages(ownerID int64, limit int) ([]models.OutboxMessage, error) {
+	return s.repo.GetDeadMessagesByOwnerID(ownerID, limit)
+}
+
+// Retry queues a dead message of the owner to be sent again
+func (s *outboxService) Retry(messageID uint, ownerID int64) error {
+	message, err := s.repo.GetByID(messageID)
+	if err != nil || message.OwnerID != ownerID {
+		return errors.New("پیام یافت نشد")
+	}
+	if message.Status != models.OutboxStatusDead {
+		return errors.New("این پیام در صف ارسال است یا قبلا ارسال شده است")
+	}
+	now := time.Now()
+	message.Status = models.OutboxStatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = &now
+	if err := s.repo.Update(message); err != nil {
+		return err
+	}
+	s.Wake()
+	return nil
+}
@@ -4,21 +4,33 @@ import (
 	"bbb/internal/models"
 	"bbb/internal/repository"
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// variablePattern matches variable references such as {{amount}} in task titles and descriptions
+var variablePattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
 type ProcessService interface {
 	CreateProcess(process *models.Process) error
 	GetProcessByID(id uint) (*models.Process, error)
 	GetProcessesByUserID(userID int64) ([]models.Process, error)
 	GetAllProcesses() ([]models.Process, error)
-	StartProcessExecution(processID uint) (*models.ProcessExecution, error)
+	StartProcessExecution(processID uint, title string, parentTaskExecutionID *uint) (*models.ProcessExecution, error)
 	GetProcessExecutionByID(id uint) (*models.ProcessExecution, error)
+	GetSubProcessExecution(taskExecutionID uint) (*models.ProcessExecution, error)
 	GetProcessExecutionsByProcessID(processID uint) ([]models.ProcessExecution, error)
 	UpdateProcessExecution(execution *models.ProcessExecution) error
 	GetPendingProcessExecutions() ([]models.ProcessExecution, error)
 	AddPendingTask(executionID uint, taskExecutionID uint) error
 	RemovePendingTask(executionID uint, taskExecutionID uint) error
+	SetVariables(executionID uint, variables map[string]string) error
+	GetVariables(executionID uint) (map[string]string, error)
+	GetCompletedExecutionsByOwnerID(ownerID int64, since time.Time) ([]models.ProcessExecution, error)
+	GetStalledExecutionsByOwnerID(ownerID int64, idleSince time.Time) ([]models.ProcessExecution, error)
 }
 
 type processService struct {
@@ -53,7 +65,9 @@ func (s *processService) GetAllProcesses() ([]models.Process, error) {
 	return s.repo.GetAll()
 }
 
-func (s *processService) StartProcessExecution(processID uint) (*models.ProcessExecution, error) {
+// StartProcessExecution creates an execution of a process. parentTaskExecutionID is set when the execution
+// is started by a call activity of another process.
+func (s *processService) StartProcessExecution(processID uint, title string, parentTaskExecutionID *uint) (*models.ProcessExecution, error) {
 	process, err := s.repo.GetByID(processID)
 	if err != nil {
 		return nil, err
@@ -64,6 +78,8 @@ func (s *processService) StartProcessExecution(processID uint) (*models.ProcessE
 
 	execution := &models.ProcessExecution{
 		ProcessID:               processID,
+		Title:                   strings.TrimSpace(title),
+		ParentTaskExecutionID:   parentTaskExecutionID,
 		Status:                  models.ProcessExecutionStatusPending,
 		PendingTaskExecutionIDs: make([]uint, 0),
 		StartedAt:               time.Now(),
@@ -80,6 +96,10 @@ func (s *processService) GetProcessExecutionByID(id uint) (*models.ProcessExecut
 	return s.repo.GetProcessExecutionByID(id)
 }
 
+func (s *processService) GetSubProcessExecution(taskExecutionID uint) (*models.ProcessExecution, error) {
+	return s.repo.GetProcessExecutionByParentTaskExecutionID(taskExecutionID)
+}
+
 func (s *processService) GetProcessExecutionsByProcessID(processID uint) ([]models.ProcessExecution, error) {
 	return s.repo.GetProcessExecutionsByProcessID(processID)
 }
@@ -126,3 +146,204 @@ func (s *processService) RemovePendingTask(executionID uint, taskExecutionID uin
 	execution.PendingTaskExecutionIDs = newPendingTasks
 	return s.repo.UpdateProcessExecution(execution)
 }
+
+// SetVariables stores the given values in the variables of a process execution, overwriting existing ones
+func (s *processService) SetVariables(executionID uint, variables map[string]string) error {
+	for name, value := range variables {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		variable := &models.ProcessVariable{ProcessExecutionID: executionID, Name: name, Value: value}
+		if err := s.repo.SaveVariable(variable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *processService) GetVariables(executionID uint) (map[string]string, error) {
+	variables, err := s.repo.GetVariables(executionID)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		values[variable.Name] = variable.Value
+	}
+	return values, nil
+}
+
+// RenderVariables replaces {{name}} references in a text with the values of the variables.
+// References to unknown variables are left untouched.
+func RenderVariables(text string, variables map[string]string) string {
+	if len(variables) == 0 {
+		return text
+	}
+	return variablePattern.ReplaceAllStringFunc(text, func(reference string) string {
+		name := variablePattern.FindStringSubmatch(reference)[1]
+		if value, exists := variables[name]; exists {
+			return value
+		}
+		return reference
+	})
+}
+
+// MapVariables selects and renames variables according to a mapping with one "from=to" or "name" entry per line.
+// An empty mapping passes every variable through unchanged.
+func MapVariables(variables map[string]string, mapping string) map[string]string {
+	if strings.TrimSpace(mapping) == "" {
+		return variables
+	}
+	mapped := make(map[string]string)
+	for _, line := range strings.Split(mapping, "\n") {
+		from, to, found := strings.Cut(line, "=")
+		from = strings.TrimSpace(from)
+		to = strings.TrimSpace(to)
+		if !found {
+			to = from
+		}
+		if value, exists := variables[from]; exists && to != "" {
+			mapped[to] = value
+		}
+	}
+	return mapped
+}
+
+// SplitListVariable splits the value of a list variable into its items, one per line or separated by commas
+func SplitListVariable(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == ',' || r == '،'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ValidVariableMapping reports whether every line of a variable mapping names its variables.
+// Names may contain spaces since variables without an explicit name are called after their field label.
+func ValidVariableMapping(mapping string) bool {
+	for _, line := range strings.Split(mapping, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		from, to, found := strings.Cut(line, "=")
+		if strings.TrimSpace(from) == "" || (found && strings.TrimSpace(to) == "") || strings.ContainsAny(line, "{}") {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidVariableName reports whether a name can be used to reference a process variable
+func ValidVariableName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "{} \t\n")
+}
+
+// conditionOperators lists the comparison operators of branch conditions, two-character ones first
+var conditionOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// ValidateCondition checks that a branch condition is empty or a comparison such as "{{amount}} > 1000"
+func ValidateCondition(condition string) error {
+	if strings.TrimSpace(condition) == "" {
+		return nil
+	}
+	_, _, _, err := splitCondition(condition)
+	return err
+}
+
+// EvaluateCondition reports whether a branch condition such as "{{amount}} > 1000" holds for the variables.
+// Operands are compared as numbers when both are numbers and as text otherwise. An empty condition always holds.
+func EvaluateCondition(condition string, variables map[string]string) (bool, error) {
+	if strings.TrimSpace(condition) == "" {
+		return true, nil
+	}
+	left, operator, right, err := splitCondition(condition)
+	if err != nil {
+		return false, err
+	}
+	left = conditionOperand(RenderVariables(left, variables))
+	right = conditionOperand(RenderVariables(right, variables))
+	for _, operand := range []string{left, right} {
+		if reference := variablePattern.FindString(operand); reference != "" {
+			return false, fmt.Errorf("متغیر %s مقدار ندارد", reference)
+		}
+	}
+
+	leftNumber, leftErr := strconv.ParseFloat(NormalizeDigits(left), 64)
+	rightNumber, rightErr := strconv.ParseFloat(NormalizeDigits(right), 64)
+	if leftErr == nil && rightErr == nil {
+		switch operator {
+		case "==":
+			return leftNumber == rightNumber, nil
+		case "!=":
+			return leftNumber != rightNumber, nil
+		case ">=":
+			return leftNumber >= rightNumber, nil
+		case "<=":
+			return leftNumber <= rightNumber, nil
+		case ">":
+			return leftNumber > rightNumber, nil
+		default:
+			return leftNumber < rightNumber, nil
+		}
+	}
+	switch operator {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+	return false, fmt.Errorf("مقایسه «%s %s %s» فقط برای اعداد ممکن است", left, operator, right)
+}
+
+// splitCondition splits a branch condition into its operands and comparison operator.
+// Operators inside {{...}} references are ignored.
+func splitCondition(condition string) (string, string, string, error) {
+	depth := 0
+	for i := 0; i < len(condition); i++ {
+		switch condition[i] {
+		case '{':
+			depth++
+			continue
+		case '}':
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		for _, operator := range conditionOperators {
+			if strings.HasPrefix(condition[i:], operator) {
+				left := strings.TrimSpace(condition[:i])
+				right := strings.TrimSpace(condition[i+len(operator):])
+				if left == "" || right == "" {
+					return "", "", "", errors.New("دو طرف شرط باید مقدار داشته باشند")
+				}
+				return left, operator, right, nil
+			}
+		}
+	}
+	return "", "", "", errors.New("شرط باید مقایسه‌ای مانند {{amount}} > 1000 باشد (عملگرها: == != > >= < <=)")
+}
+
+// conditionOperand trims an operand of a branch condition and the quotes around a text value
+func conditionOperand(operand string) string {
+	operand = strings.TrimSpace(operand)
+	if len(operand) >= 2 && strings.HasPrefix(operand, `"`) && strings.HasSuffix(operand, `"`) {
+		return operand[1 : len(operand)-1]
+	}
+	return operand
+}
+
+// GetCompletedExecutionsByOwnerID returns the executions of the owner's processes that completed after the given time
+func (s *processService) GetCompletedExecutionsByOwnerID(ownerID int64, since time.Time) ([]models.ProcessExecution, error) {
+	return s.repo.GetCompletedExecutionsByOwnerID(ownerID, since)
+}
+
+// GetStalledExecutionsByOwnerID returns the open executions of the owner's processes with no task activity after the given time
+func (s *processService) GetStalledExecutionsByOwnerID(ownerID int64, idleSince time.Time) ([]models.ProcessExecution, error) {
+	return s.repo.GetStalledExecutionsByOwnerID(ownerID, idleSince)
+}
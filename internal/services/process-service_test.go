@@ -0,0 +1,70 @@
+package service
+
+import "testing"
+
+func TestEvaluateCondition(t *testing.T) {
+	variables := map[string]string{
+		"amount":   "1500",
+		"small":    "۲۰۰",
+		"approved": "true",
+		"city":     "تهران",
+		"note":     "a > b",
+	}
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+		wantErr   bool
+	}{
+		{name: "empty condition", condition: "", want: true},
+		{name: "blank condition", condition: "   ", want: true},
+		{name: "greater than holds", condition: "{{amount}} > 1000", want: true},
+		{name: "greater than fails", condition: "{{amount}} > 2000", want: false},
+		{name: "greater or equal on bound", condition: "{{amount}} >= 1500", want: true},
+		{name: "less than", condition: "{{amount}} < 1000", want: false},
+		{name: "less or equal", condition: "{{amount}}<=1500", want: true},
+		{name: "numbers compare by value", condition: "{{amount}} == 1500.0", want: true},
+		{name: "persian digits", condition: "{{small}} < ۳۰۰", want: true},
+		{name: "variable on the right", condition: "1000 < {{amount}}", want: true},
+		{name: "boolean variable", condition: "{{approved}} == true", want: true},
+		{name: "text not equal", condition: "{{city}} != تهران", want: false},
+		{name: "quoted text", condition: `{{city}} == "تهران"`, want: true},
+		{name: "operator in variable value", condition: "{{note}} == a > b", want: true},
+		{name: "spaces inside reference", condition: "{{ amount }} > 1000", want: true},
+		{name: "unknown variable", condition: "{{missing}} > 1000", wantErr: true},
+		{name: "ordering text", condition: "{{city}} > 1000", wantErr: true},
+		{name: "no operator", condition: "{{amount}}", wantErr: true},
+		{name: "single equals", condition: "{{amount}} = 1500", wantErr: true},
+		{name: "missing operand", condition: "{{amount}} >", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := EvaluateCondition(test.condition, variables)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("EvaluateCondition(%q) error = %v, wantErr %v", test.condition, err, test.wantErr)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("EvaluateCondition(%q) = %v, want %v", test.condition, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		wantErr   bool
+	}{
+		{condition: "", wantErr: false},
+		{condition: "{{amount}} > 1000", wantErr: false},
+		{condition: "{{missing}} != x", wantErr: false},
+		{condition: "{{amount}}", wantErr: true},
+		{condition: "> 1000", wantErr: true},
+		{condition: "{{a>b}}", wantErr: true},
+	}
+	for _, test := range tests {
+		if err := ValidateCondition(test.condition); (err != nil) != test.wantErr {
+			t.Errorf("ValidateCondition(%q) error = %v, wantErr %v", test.condition, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// ReportDefaultWeeks is the period a report covers unless another one is chosen
+	ReportDefaultWeeks = 12
+	// reportMaxWeeks caps the period of a report at about a year
+	reportMaxWeeks = 53
+)
+
+type (
+	// DurationStats summarizes a set of measured durations
+	DurationStats struct {
+		Count   int
+		Average time.Duration
+		Median  time.Duration
+		P90     time.Duration
+	}
+
+	// TaskReport measures the task executions of one task. Claim is from activation to the latest assignment, Work from
+	// the latest assignment to completion, and Lead from activation to completion. Dwell adds the age of the open task
+	// executions to Lead, so a task where executions are stuck ranks high even before they complete.
+	TaskReport struct {
+		Task      models.Task
+		Completed int
+		Open      int
+		Claim     DurationStats
+		Work      DurationStats
+		Lead      DurationStats
+		Dwell     DurationStats
+	}
+
+	// WeeklyThroughput is the number of executions completed in a week starting on Saturday
+	WeeklyThroughput struct {
+		WeekStart time.Time
+		Completed int
+	}
+
+	// ProcessReport tells how long the executions of a process and each of its tasks take over a period
+	ProcessReport struct {
+		Process    *models.Process
+		Since      time.Time
+		Until      time.Time
+		Weeks      int
+		Started    int
+		Completed  int
+		Open       int
+		CycleTime  DurationStats
+		Throughput []WeeklyThroughput
+		Tasks      []TaskReport
+		Bottleneck *TaskReport
+	}
+
+	// MemberReport measures the work of one member of a team. Claimed counts the task executions they took over the
+	// period, Completion is from their assignment to completion, and Assigned is what they hold open now.
+	MemberReport struct {
+		User       models.User
+		Claimed    int
+		Completed  int
+		Completion DurationStats
+		Assigned   int
+		Overdue    int
+	}
+
+	// TeamReport tells how the work of a team's tasks went over a period and how it is spread among the members now
+	TeamReport struct {
+		Team       *models.Team
+		Since      time.Time
+		Until      time.Time
+		Weeks      int
+		Completed  int
+		Pending    int
+		Assigned   int
+		Completion DurationStats
+		Throughput []WeeklyThroughput
+		Members    []MemberReport
+		Overdue    []models.TaskExecution
+	}
+
+	ReportService interface {
+		ProcessReport(processID uint, userID int64, weeks int) (*ProcessReport, error)
+		ProcessReportCSV(report *ProcessReport) ([]byte, error)
+		TeamReport(teamID uint, weeks int) (*TeamReport, error)
+		TeamReportCSV(report *TeamReport) ([]byte, error)
+	}
+
+	reportService struct {
+		processRepo repository.ProcessRepository
+		taskRepo    repository.TaskRepository
+		teamRepo    repository.TeamRepository
+		location    *time.Location
+	}
+)
+
+func NewReportService(processRepo repository.ProcessRepository, taskRepo repository.TaskRepository, teamRepo repository.TeamRepository, location *time.Location) ReportService {
+	if location == nil {
+		location = time.Local
+	}
+	return &reportService{
+		processRepo: processRepo,
+		taskRepo:    taskRepo,
+		teamRepo:    teamRepo,
+		location:    location,
+	}
+}
+
+// ProcessReport measures the executions of the process over the given number of weeks. Only the owner may see it.
+func (s *reportService) ProcessReport(processID uint, userID int64, weeks int) (*ProcessReport, error) {
+	if weeks <= 0 || weeks > reportMaxWeeks {
+		return nil, errors.New("بازه‌ی گزارش نامعتبر است")
+	}
+	process, err := s.processRepo.GetByID(processID)
+	if err != nil {
+		return nil, err
+	}
+	if process.UserID != userID {
+		return nil, errors.New("فقط مالک فرایند می‌تواند گزارش آن را ببیند")
+	}
+
+	now := time.Now()
+	since, throughput := s.reportWeeks(now, weeks)
+	executions, err := s.processRepo.GetProcessExecutionsSince(process.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.taskRepo.GetByProcessID(process.ID)
+	if err != nil {
+		return nil, err
+	}
+	taskExecutions, err := s.taskRepo.GetTaskExecutionsByProcessIDSince(process.ID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ProcessReport{
+		Process:    process,
+		Since:      since,
+		Until:      now,
+		Weeks:      weeks,
+		Throughput: throughput,
+	}
+
+	var cycleTimes []time.Duration
+	for _, execution := range executions {
+		if execution.StartedAt.After(since) {
+			report.Started++
+		}
+		switch execution.Status {
+		case models.ProcessExecutionStatusPending, models.ProcessExecutionStatusRunning:
+			report.Open++
+		case models.ProcessExecutionStatusCompleted:
+			if execution.CompletedAt == nil || !execution.CompletedAt.After(since) {
+				continue
+			}
+			report.Completed++
+			cycleTimes = append(cycleTimes, execution.CompletedAt.Sub(execution.StartedAt))
+			countCompletion(report.Throughput, *execution.CompletedAt)
+		}
+	}
+	report.CycleTime = durationStats(cycleTimes)
+
+	type samples struct{ claim, work, lead, dwell []time.Duration }
+	byTask := make(map[uint]*samples)
+	taskReports := make(map[uint]*TaskReport)
+	for _, task := range tasks {
+		byTask[task.ID] = &samples{}
+		taskReports[task.ID] = &TaskReport{Task: task}
+	}
+	for _, taskExecution := range taskExecutions {
+		taskSamples, exists := byTask[taskExecution.TaskID]
+		if !exists {
+			continue
+		}
+		taskReport := taskReports[taskExecution.TaskID]
+		// Instances started per team member are assigned when they start, so they were never claimed
+		claimed := taskExecution.Task == nil || taskExecution.Task.MultiInstance != models.MultiInstanceMembers
+		if claimed && taskExecution.AssignedAt != nil && taskExecution.AssignedAt.After(since) {
+			taskSamples.claim = append(taskSamples.claim, taskExecution.AssignedAt.Sub(taskExecution.CreatedAt))
+		}
+		switch taskExecution.Status {
+		case models.TaskStatusPending, models.TaskStatusAssigned, models.TaskStatusWaiting:
+			taskReport.Open++
+			taskSamples.dwell = append(taskSamples.dwell, now.Sub(taskExecution.CreatedAt))
+		case models.TaskStatusCompleted:
+			if taskExecution.CompletedAt == nil || !taskExecution.CompletedAt.After(since) {
+				continue
+			}
+			taskReport.Completed++
+			lead := taskExecution.CompletedAt.Sub(taskExecution.CreatedAt)
+			taskSamples.lead = append(taskSamples.lead, lead)
+			taskSamples.dwell = append(taskSamples.dwell, lead)
+			if taskExecution.AssignedAt != nil {
+				taskSamples.work = append(taskSamples.work, taskExecution.CompletedAt.Sub(*taskExecution.AssignedAt))
+			}
+		}
+	}
+
+	for _, task := range tasks {
+		taskReport := taskReports[task.ID]
+		taskSamples := byTask[task.ID]
+		taskReport.Claim = durationStats(taskSamples.claim)
+		taskReport.Work = durationStats(taskSamples.work)
+		taskReport.Lead = durationStats(taskSamples.lead)
+		taskReport.Dwell = durationStats(taskSamples.dwell)
+		report.Tasks = append(report.Tasks, *taskReport)
+	}
+	for i := range report.Tasks {
+		taskReport := &report.Tasks[i]
+		// Timer tasks wait on purpose, so they are never the bottleneck
+		if taskReport.Dwell.Count == 0 || taskReport.Task.IsTimer() {
+			continue
+		}
+		if report.Bottleneck == nil || taskReport.Dwell.Median > report.Bottleneck.Dwell.Median {
+			report.Bottleneck = taskReport
+		}
+	}
+	return report, nil
+}
+
+// ProcessReportCSV writes the report as CSV: a row for the whole execution and one for each task, followed by the
+// weekly throughput. Durations are in hours so spreadsheets can compute with them.
+func (s *reportService) ProcessReportCSV(report *ProcessReport) ([]byte, error) {
+	rows := [][]string{
+		{"process", report.Process.Name},
+		{"from", report.Since.In(s.location).Format("2006-01-02")},
+		{"to", report.Until.In(s.location).Format("2006-01-02")},
+		{"started", strconv.Itoa(report.Started)},
+		{"completed", strconv.Itoa(report.Completed)},
+		{"open", strconv.Itoa(report.Open)},
+		{},
+	}
+	header := []string{"task_id", "task", "completed", "open"}
+	for _, measure := range []string{"claim", "work", "lead", "dwell"} {
+		header = append(header, measure+"_count", measure+"_avg_hours", measure+"_median_hours", measure+"_p90_hours")
+	}
+	header = append(header, "bottleneck")
+	rows = append(rows, header)
+	// The whole execution has no claim or work time; its cycle time goes in the lead columns
+	execution := []string{"", "execution cycle time", strconv.Itoa(report.Completed), strconv.Itoa(report.Open)}
+	for _, stats := range []DurationStats{{}, {}, report.CycleTime, {}} {
+		execution = append(execution, statsRecord(stats)...)
+	}
+	rows = append(rows, append(execution, ""))
+	for i := range report.Tasks {
+		taskReport := &report.Tasks[i]
+		bottleneck := ""
+		if report.Bottleneck != nil && report.Bottleneck.Task.ID == taskReport.Task.ID {
+			bottleneck = "yes"
+		}
+		row := []string{strconv.FormatUint(uint64(taskReport.Task.ID), 10), taskReport.Task.Title,
+			strconv.Itoa(taskReport.Completed), strconv.Itoa(taskReport.Open)}
+		for _, stats := range []DurationStats{taskReport.Claim, taskReport.Work, taskReport.Lead, taskReport.Dwell} {
+			row = append(row, statsRecord(stats)...)
+		}
+		rows = append(rows, append(row, bottleneck))
+	}
+	rows = append(rows, []string{}, []string{"week_start", "completed_executions"})
+	for _, week := range report.Throughput {
+		rows = append(rows, []string{week.WeekStart.In(s.location).Format("2006-01-02"), strconv.Itoa(week.Completed)})
+	}
+	return writeCSV(rows)
+}
+
+// TeamReport measures the task executions of the team's tasks over the given number of weeks. Callers check that the
+// user may manage the team, since group administrators may and that takes asking the chat.
+func (s *reportService) TeamReport(teamID uint, weeks int) (*TeamReport, error) {
+	if weeks <= 0 || weeks > reportMaxWeeks {
+		return nil, errors.New("بازه‌ی گزارش نامعتبر است")
+	}
+	team, err := s.teamRepo.GetByID(teamID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.teamRepo.GetMembers(team.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	since, throughput := s.reportWeeks(now, weeks)
+	taskExecutions, err := s.taskRepo.GetTaskExecutionsByTeamIDSince(team.ID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TeamReport{
+		Team:       team,
+		Since:      since,
+		Until:      now,
+		Weeks:      weeks,
+		Throughput: throughput,
+	}
+	memberReports := make(map[int64]*MemberReport)
+	var order []int64
+	memberReport := func(user *models.User) *MemberReport {
+		if _, exists := memberReports[user.ID]; !exists {
+			memberReports[user.ID] = &MemberReport{User: *user}
+			order = append(order, user.ID)
+		}
+		return memberReports[user.ID]
+	}
+	for i := range members {
+		memberReport(&members[i])
+	}
+
+	var completionTimes []time.Duration
+	memberCompletionTimes := make(map[int64][]time.Duration)
+	for _, taskExecution := range taskExecutions {
+		// Former members who still hold or finished work of the team are listed after the current ones
+		var member *MemberReport
+		if taskExecution.User != nil {
+			member = memberReport(taskExecution.User)
+		}
+		// Instances started per team member are assigned when they start, so they were never claimed
+		claimed := taskExecution.Task == nil || taskExecution.Task.MultiInstance != models.MultiInstanceMembers
+		if member != nil && claimed && taskExecution.AssignedAt != nil && taskExecution.AssignedAt.After(since) {
+			member.Claimed++
+		}
+		switch taskExecution.Status {
+		case models.TaskStatusPending:
+			report.Pending++
+		case models.TaskStatusAssigned:
+			report.Assigned++
+			if member != nil {
+				member.Assigned++
+			}
+		case models.TaskStatusCompleted:
+			if taskExecution.CompletedAt == nil || !taskExecution.CompletedAt.After(since) {
+				continue
+			}
+			report.Completed++
+			countCompletion(report.Throughput, *taskExecution.CompletedAt)
+			if member != nil && taskExecution.AssignedAt != nil {
+				member.Completed++
+				completionTime := taskExecution.CompletedAt.Sub(*taskExecution.AssignedAt)
+				completionTimes = append(completionTimes, completionTime)
+				memberCompletionTimes[member.User.ID] = append(memberCompletionTimes[member.User.ID], completionTime)
+			}
+		}
+		if taskExecution.IsOverdue(now) {
+			report.Overdue = append(report.Overdue, taskExecution)
+			if member != nil {
+				member.Overdue++
+			}
+		}
+	}
+
+	report.Completion = durationStats(completionTimes)
+	for _, userID := range order {
+		member := memberReports[userID]
+		member.Completion = durationStats(memberCompletionTimes[userID])
+		report.Members = append(report.Members, *member)
+	}
+	sort.Slice(report.Overdue, func(i, j int) bool { return report.Overdue[i].DueAt.Before(*report.Overdue[j].DueAt) })
+	return report, nil
+}
+
+// TeamReportCSV writes the report as CSV: a row for each member, followed by the weekly throughput and the overdue task
+// executions. Durations are in hours so spreadsheets can compute with them.
+func (s *reportService) TeamReportCSV(report *TeamReport) ([]byte, error) {
+	rows := [][]string{
+		{"team", report.Team.Name},
+		{"from", report.Since.In(s.location).Format("2006-01-02")},
+		{"to", report.Until.In(s.location).Format("2006-01-02")},
+		{"completed", strconv.Itoa(report.Completed)},
+		{"pending", strconv.Itoa(report.Pending)},
+		{"assigned", strconv.Itoa(report.Assigned)},
+		{"overdue", strconv.Itoa(len(report.Overdue))},
+		{},
+		{"user_id", "first_name", "last_name", "username", "claimed", "completed",
+			"completion_count", "completion_avg_hours", "completion_median_hours", "completion_p90_hours", "assigned_now", "overdue_now"},
+	}
+	for _, member := range report.Members {
+		row := []string{strconv.FormatInt(member.User.ID, 10), member.User.FirstName, member.User.LastName, member.User.Username,
+			strconv.Itoa(member.Claimed), strconv.Itoa(member.Completed)}
+		row = append(row, statsRecord(member.Completion)...)
+		rows = append(rows, append(row, strconv.Itoa(member.Assigned), strconv.Itoa(member.Overdue)))
+	}
+	rows = append(rows, []string{}, []string{"week_start", "completed_task_executions"})
+	for _, week := range report.Throughput {
+		rows = append(rows, []string{week.WeekStart.In(s.location).Format("2006-01-02"), strconv.Itoa(week.Completed)})
+	}
+	rows = append(rows, []string{}, []string{"task_execution_id", "task", "process", "execution", "assignee_id", "due_at"})
+	for _, taskExecution := range report.Overdue {
+		task, process := "", ""
+		if taskExecution.Task != nil {
+			task = taskExecution.Task.Title
+			if taskExecution.InstanceItem != "" {
+				task += " — " + taskExecution.InstanceItem
+			}
+			if taskExecution.Task.Process != nil {
+				process = taskExecution.Task.Process.Name
+			}
+		}
+		assignee := ""
+		if taskExecution.UserID != nil {
+			assignee = strconv.FormatInt(*taskExecution.UserID, 10)
+		}
+		rows = append(rows, []string{strconv.FormatUint(uint64(taskExecution.ID), 10), task, process, executionName(&taskExecution),
+			assignee, taskExecution.DueAt.In(s.location).Format("2006-01-02 15:04")})
+	}
+	return writeCSV(rows)
+}
+
+// reportWeeks returns the start of a report period of the given number of weeks ending now, and a zero throughput
+// for each of its weeks
+func (s *reportService) reportWeeks(now time.Time, weeks int) (time.Time, []WeeklyThroughput) {
+	since := weekStart(now, s.location).AddDate(0, 0, -7*(weeks-1))
+	throughput := make([]WeeklyThroughput, weeks)
+	for i := range throughput {
+		throughput[i].WeekStart = since.AddDate(0, 0, 7*i)
+	}
+	return since, throughput
+}
+
+// countCompletion adds a completion to the week of the throughput it happened in
+func countCompletion(throughput []WeeklyThroughput, completedAt time.Time) {
+	for week := len(throughput) - 1; week >= 0; week-- {
+		if !completedAt.Before(throughput[week].WeekStart) {
+			throughput[week].Completed++
+			return
+		}
+	}
+}
+
+func writeCSV(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	// The byte order mark makes spreadsheet programs read the Persian titles as UTF-8
+	buf.WriteString("\uFEFF")
+	if err := csv.NewWriter(&buf).WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// statsRecord returns the count, average, median and 90th percentile of the stats as CSV fields, in hours
+func statsRecord(stats DurationStats) []string {
+	if stats.Count == 0 {
+		return []string{"0", "", "", ""}
+	}
+	hours := func(d time.Duration) string { return strconv.FormatFloat(d.Hours(), 'f', 2, 64) }
+	return []string{strconv.Itoa(stats.Count), hours(stats.Average), hours(stats.Median), hours(stats.P90)}
+}
+
+// durationStats computes the average and the nearest-rank median and 90th percentile of the durations
+func durationStats(durations []time.Duration) DurationStats {
+	if len(durations) == 0 {
+		return DurationStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p int) time.Duration {
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+	return DurationStats{
+		Count:   len(sorted),
+		Average: total / time.Duration(len(sorted)),
+		Median:  percentile(50),
+		P90:     percentile(90),
+	}
+}
+
+// weekStart returns the midnight of the Saturday starting the week of the given time
+func weekStart(t time.Time, location *time.Location) time.Time {
+	local := t.In(location)
+	daysSinceSaturday := (int(local.Weekday()) + 1) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()-daysSinceSaturday, 0, 0, 0, 0, location)
+}
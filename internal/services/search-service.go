@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const (
+	// searchProcessLimit, searchTaskLimit and searchTeamLimit cap each kind of search result;
+	// together they stay within the 50 results an inline query may answer with
+	searchProcessLimit = 10
+	searchTaskLimit    = 25
+	searchTeamLimit    = 10
+)
+
+type (
+	// SearchResults holds what a user found: their processes, the open task executions they can work on and their teams
+	SearchResults struct {
+		Processes      []models.Process
+		TaskExecutions []models.TaskExecution
+		Teams          []models.Team
+	}
+
+	SearchService interface {
+		Search(userID int64, query string) (*SearchResults, error)
+		FullTextSearch(userID int64, query string, page, pageSize int) ([]models.SearchResult, int64, error)
+	}
+
+	searchService struct {
+		repo        repository.SearchRepository
+		processRepo repository.ProcessRepository
+		taskRepo    repository.TaskRepository
+		teamRepo    repository.TeamRepository
+	}
+)
+
+func NewSearchService(repo repository.SearchRepository, processRepo repository.ProcessRepository, taskRepo repository.TaskRepository, teamRepo repository.TeamRepository) SearchService {
+	return &searchService{
+		repo:        repo,
+		processRepo: processRepo,
+		taskRepo:    taskRepo,
+		teamRepo:    teamRepo,
+	}
+}
+
+// Search finds what the user may see that matches the query. An empty query returns their latest items.
+func (s *searchService) Search(userID int64, query string) (*SearchResults, error) {
+	query = strings.TrimSpace(query)
+	processes, err := s.processRepo.SearchByUserID(userID, query, searchProcessLimit)
+	if err != nil {
+		return nil, err
+	}
+	taskExecutions, err := s.taskRepo.SearchOpenTaskExecutions(userID, query, searchTaskLimit)
+	if err != nil {
+		return nil, err
+	}
+	teams, err := s.teamRepo.SearchByUserID(userID, query, searchTeamLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchResults{
+		Processes:      processes,
+		TaskExecutions: taskExecutions,
+		Teams:          teams,
+	}, nil
+}
+
+// FullTextSearch searches the texts of the user's processes, tasks, executions, completion notes and comments for all
+// the words of the query, each word also matching the words it starts. Results come a page at a time, best first.
+func (s *searchService) FullTextSearch(userID int64, query string, page, pageSize int) ([]models.SearchResult, int64, error) {
+	tsQuery := fullTextQuery(query)
+	if tsQuery == "" {
+		return nil, 0, errors.New("عبارت جستجو باید دست کم یک حرف یا عدد داشته باشد")
+	}
+	return s.repo.Search(userID, tsQuery, page*pageSize, pageSize)
+}
+
+// fullTextQuery turns what the user typed into a to_tsquery prefix query of its words. Words are the runs of letters,
+// digits and the diacritics written on them, so the query never carries tsquery operators; the zero-width non-joiner
+// splits words the same way the normalization of the searched texts does.
+func fullTextQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		// Diacritics and tatweel are dropped by the normalization, so a word of nothing else would be left empty
+		if strings.IndexFunc(word, func(r rune) bool { return (unicode.IsLetter(r) || unicode.IsDigit(r)) && r != 'ـ' }) == -1 {
+			continue
+		}
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
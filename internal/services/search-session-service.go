@@ -0,0 +1,50 @@
+package service
+
+import "sync"
+
+// SearchSessionService keeps track of users asked for a search query, and of the last query of each user so the
+// pages of its results can be turned
+type SearchSessionService struct {
+	awaiting map[int64]bool
+	queries  map[int64]string
+	mu       sync.RWMutex
+}
+
+func NewSearchSessionService() *SearchSessionService {
+	return &SearchSessionService{
+		awaiting: make(map[int64]bool),
+		queries:  make(map[int64]string),
+	}
+}
+
+func (s *SearchSessionService) StartSession(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.awaiting[userID] = true
+}
+
+// EndSession ends the user's session and reports whether they were asked for a query
+func (s *SearchSessionService) EndSession(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	awaiting := s.awaiting[userID]
+	delete(s.awaiting, userID)
+	return awaiting
+}
+
+func (s *SearchSessionService) SetQuery(userID int64, query string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries[userID] = query
+}
+
+func (s *SearchSessionService) GetQuery(userID int64) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query, exists := s.queries[userID]
+	return query, exists
+}
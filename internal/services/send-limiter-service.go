@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SendPriority decides which queued messages go first when the messenger's rate limits are reached
+type SendPriority int
+
+const (
+	// SendPriorityInteractive is for replies to something a user just did
+	SendPriorityInteractive SendPriority = iota
+	// SendPriorityBulk is for notifications sent in the background, which wait while replies are queued
+	SendPriorityBulk
+)
+
+// maxThrottledRetries is how many times a message is sent again after the messenger asked to retry later
+const maxThrottledRetries = 3
+
+// maxThrottledResponseSize limits how much of a "too many requests" response is read for its retry time
+const maxThrottledResponseSize = 64 << 10
+
+type (
+	// SendLimiter queues the messages sent by the bot so they stay within the global and per-chat flood limits
+	// of the messenger. Its clients are used as the HTTP client of a bot, so every send of that bot is queued.
+	SendLimiter interface {
+		Client(priority SendPriority) tgbotapi.HTTPClient
+		Run()
+		Stats() SendStats
+	}
+
+	// SendStats is a snapshot of the send queue
+	SendStats struct {
+		InteractiveQueued int
+		BulkQueued        int
+		Sent              uint64
+		Throttled         uint64
+		PausedUntil       time.Time
+	}
+
+	sendLimiter struct {
+		client        *http.Client
+		interval      time.Duration
+		chatInterval  time.Duration
+		groupInterval time.Duration
+
+		mu           sync.Mutex
+		queues       [2][]*sendTicket
+		nextSend     time.Time
+		chatNextSend map[int64]time.Time
+		pausedUntil  time.Time
+		sent         uint64
+		throttled    uint64
+		wake         chan struct{}
+	}
+
+	// sendTicket is a message waiting for its turn; ready is closed when it may be sent
+	sendTicket struct {
+		chatID int64
+		ready  chan struct{}
+	}
+
+	limitedClient struct {
+		limiter  *sendLimiter
+		priority SendPriority
+	}
+
+	throttledResponse struct {
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+)
+
+// NewSendLimiter creates a send queue that sends at most perSecond messages a second in total, and waits
+// chatInterval between messages to one private chat and groupInterval between messages to one group.
+func NewSendLimiter(client *http.Client, perSecond int, chatInterval time.Duration, groupInterval time.Duration) SendLimiter {
+	if client == nil {
+		client = &http.Client{}
+	}
+	if perSecond <= 0 {
+		perSecond = 25
+	}
+	return &sendLimiter{
+		client:        client,
+		interval:      time.Second / time.Duration(perSecond),
+		chatInterval:  chatInterval,
+		groupInterval: groupInterval,
+		chatNextSend:  make(map[int64]time.Time),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Client returns an HTTP client for a bot whose messages are queued with the given priority
+func (l *sendLimiter) Client(priority SendPriority) tgbotapi.HTTPClient {
+	return &limitedClient{limiter: l, priority: priority}
+}
+
+// Run lets the queued messages go one at a time as the rate limits allow
+func (l *sendLimiter) Run() {
+	for {
+		timer := time.NewTimer(l.release(time.Now()))
+		select {
+		case <-timer.C:
+		case <-l.wake:
+		}
+		timer.Stop()
+	}
+}
+
+// Stats returns the length of the queues and how many messages were sent and throttled
+func (l *sendLimiter) Stats() SendStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return SendStats{
+		InteractiveQueued: len(l.queues[SendPriorityInteractive]),
+		BulkQueued:        len(l.queues[SendPriorityBulk]),
+		Sent:              l.sent,
+		Throttled:         l.throttled,
+		PausedUntil:       l.pausedUntil,
+	}
+}
+
+// release lets the next message go if the limits allow, and returns how long to wait before trying again
+func (l *sendLimiter) release(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.queues[SendPriorityInteractive]) == 0 && len(l.queues[SendPriorityBulk]) == 0 {
+		return time.Minute
+	}
+	gate := l.nextSend
+	if l.pausedUntil.After(gate) {
+		gate = l.pausedUntil
+	}
+	if now.Before(gate) {
+		return gate.Sub(now)
+	}
+
+	ticket, readyAt := l.nextTicket(now)
+	if ticket == nil {
+		return readyAt.Sub(now)
+	}
+	l.nextSend = now.Add(l.interval)
+	if ticket.chatID != 0 {
+		l.chatNextSend[ticket.chatID] = now.Add(l.chatIntervalOf(ticket.chatID))
+	}
+	l.sent++
+	close(ticket.ready)
+
+	if len(l.chatNextSend) > 1000 {
+		for chatID, next := range l.chatNextSend {
+			if next.Before(now) {
+				delete(l.chatNextSend, chatID)
+			}
+		}
+	}
+	return 0
+}
+
+// nextTicket removes the first queued message, interactive ones first, whose chat isn't waiting out its interval.
+// When every queued chat is waiting, it returns when the first of them may be sent to.
+func (l *sendLimiter) nextTicket(now time.Time) (*sendTicket, time.Time) {
+	var readyAt time.Time
+	for priority := range l.queues {
+		for i, ticket := range l.queues[priority] {
+			next := l.chatNextSend[ticket.chatID]
+			if !next.After(now) {
+				l.queues[priority] = append(l.queues[priority][:i], l.queues[priority][i+1:]...)
+				return ticket, now
+			}
+			if readyAt.IsZero() || next.Before(readyAt) {
+				readyAt = next
+			}
+		}
+	}
+	return nil, readyAt
+}
+
+func (l *sendLimiter) chatIntervalOf(chatID int64) time.Duration {
+	if chatID < 0 {
+		return l.groupInterval
+	}
+	return l.chatInterval
+}
+
+// wait queues a message and blocks until it may be sent
+func (l *sendLimiter) wait(priority SendPriority, chatID int64) {
+	ticket := &sendTicket{chatID: chatID, ready: make(chan struct{})}
+	l.mu.Lock()
+	l.queues[priority] = append(l.queues[priority], ticket)
+	l.mu.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	<-ticket.ready
+}
+
+// pause holds back every message until the retry time the messenger asked for has passed
+func (l *sendLimiter) pause(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.throttled++
+	if until := time.Now().Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Do sends a request of the bot. Messages wait for their turn in the queue, and are sent again when the messenger
+// answers 429 with a retry time; other requests, such as fetching updates, are sent right away.
+func (c *limitedClient) Do(req *http.Request) (*http.Response, error) {
+	if !rateLimitedMethod(req.URL.Path) {
+		return c.limiter.client.Do(req)
+	}
+
+	// Form bodies are read so the chat can be limited and the request repeated; file uploads are streamed,
+	// so they are only held to the global limit and sent once
+	var body []byte
+	var chatID int64
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") && req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			chatID, _ = strconv.ParseInt(values.Get("chat_id"), 10, 64)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		c.limiter.wait(c.priority, chatID)
+		attemptReq := req
+		if body != nil {
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+			attemptReq.ContentLength = int64(len(body))
+		}
+
+		resp, err := c.limiter.client.Do(attemptReq)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxThrottledResponseSize))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var throttled throttledResponse
+		retryAfter := time.Second
+		if json.Unmarshal(respBody, &throttled) == nil && throttled.Parameters.RetryAfter > 0 {
+			retryAfter = time.Duration(throttled.Parameters.RetryAfter) * time.Second
+		}
+		c.limiter.pause(retryAfter)
+		log.Printf("Messenger asked to retry %s to chat %d after %s", botAPIMethod(req.URL.Path), chatID, retryAfter)
+
+		if body == nil || attempt >= maxThrottledRetries {
+			resp.Body = io.NopCloser(bytes.NewReader(respBody))
+			return resp, nil
+		}
+	}
+}
+
+// rateLimitedMethod reports whether a bot API method sends or changes a message and so counts toward the flood limits
+func rateLimitedMethod(path string) bool {
+	method := strings.ToLower(botAPIMethod(path))
+	for _, prefix := range []string{"send", "copy", "forward", "edit"} {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func botAPIMethod(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bbb/internal/models"
+	"strings"
 	"sync"
 )
 
@@ -25,6 +26,7 @@ func (s *TaskBuilderService) StartTask(userID int64) {
 		CurrentStep:          "process",
 		Task:                 models.Task{},
 		Prerequisites:        make([]uint, 0),
+		Conditions:           make(map[uint]string),
 		HasMorePrerequisites: true,
 	}
 }
@@ -80,6 +82,23 @@ func (s *TaskBuilderService) AddPrerequisite(userID int64, prerequisiteID uint)
 
 	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "prerequisites" {
 		builder.Prerequisites = append(builder.Prerequisites, prerequisiteID)
+		builder.CurrentStep = "prerequisite_condition"
+		return true
+	}
+	return false
+}
+
+// SetPrerequisiteCondition sets the branch condition of the task after the prerequisite added last.
+// An empty condition starts the task whenever the prerequisite completes.
+func (s *TaskBuilderService) SetPrerequisiteCondition(userID int64, condition string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "prerequisite_condition" && ValidateCondition(condition) == nil {
+		if condition = strings.TrimSpace(condition); condition != "" {
+			builder.Conditions[builder.Prerequisites[len(builder.Prerequisites)-1]] = condition
+		}
+		builder.CurrentStep = "prerequisites"
 		return true
 	}
 	return false
@@ -105,6 +124,170 @@ func (s *TaskBuilderService) SetTeam(userID int64, teamID uint) bool {
 
 	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "team" {
 		builder.Task.TeamID = &teamID
+		builder.CurrentStep = "multi_instance"
+		return true
+	}
+	return false
+}
+
+// SetMultiInstance chooses whether the task is done once, by every team member, or once per item of a list variable
+func (s *TaskBuilderService) SetMultiInstance(userID int64, mode models.MultiInstanceMode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "multi_instance" {
+		builder.Task.MultiInstance = mode
+		switch mode {
+		case models.MultiInstanceNone:
+			builder.CurrentStep = "is_final"
+		case models.MultiInstanceMembers:
+			builder.CurrentStep = "multi_threshold"
+		case models.MultiInstanceList:
+			builder.CurrentStep = "multi_variable"
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetMultiInstanceVariable(userID int64, variable string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "multi_variable" {
+		builder.Task.MultiInstanceVariable = variable
+		builder.CurrentStep = "multi_threshold"
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetCompletionThreshold(userID int64, threshold int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "multi_threshold" && threshold >= 0 {
+		builder.Task.CompletionThreshold = threshold
+		builder.CurrentStep = "is_final"
+		return true
+	}
+	return false
+}
+
+// SetTimer makes the task a wait completed by the engine instead of assigning it to a team
+func (s *TaskBuilderService) SetTimer(userID int64, timerType models.TimerType) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "team" {
+		builder.Task.TimerType = timerType
+		switch timerType {
+		case models.TimerTypeDuration, models.TimerTypeBusinessHours:
+			builder.CurrentStep = "timer_wait"
+		case models.TimerTypeUntilVariable:
+			builder.CurrentStep = "timer_variable"
+		default:
+			builder.Task.TimerType = models.TimerTypeNone
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetTimerWait(userID int64, minutes int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "timer_wait" && minutes >= 0 {
+		builder.Task.TimerMinutes = minutes
+		builder.CurrentStep = "is_final"
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetTimerVariable(userID int64, variable string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "timer_variable" {
+		builder.Task.TimerVariable = variable
+		builder.CurrentStep = "is_final"
+		return true
+	}
+	return false
+}
+
+// SetWebhook makes the task a service task that calls a webhook instead of assigning it to a team
+func (s *TaskBuilderService) SetWebhook(userID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "team" {
+		builder.CurrentStep = "webhook_url"
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetWebhookURL(userID int64, webhookURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "webhook_url" && ValidateWebhookURL(webhookURL) == nil {
+		builder.Task.WebhookURL = webhookURL
+		builder.CurrentStep = "webhook_secret"
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetWebhookSecret(userID int64, secret string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "webhook_secret" {
+		builder.Task.WebhookSecret = secret
+		builder.CurrentStep = "is_final"
+		return true
+	}
+	return false
+}
+
+// SetSubProcess makes the task a call activity of another process instead of assigning it to a team
+func (s *TaskBuilderService) SetSubProcess(userID int64, processID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "team" && processID != builder.ProcessID {
+		builder.Task.SubProcessID = &processID
+		builder.CurrentStep = "subprocess_inputs"
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetSubProcessInputs(userID int64, mapping string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "subprocess_inputs" {
+		builder.Task.SubProcessInputs = mapping
+		builder.CurrentStep = "subprocess_outputs"
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) SetSubProcessOutputs(userID int64, mapping string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "subprocess_outputs" {
+		builder.Task.SubProcessOutputs = mapping
 		builder.CurrentStep = "is_final"
 		return true
 	}
@@ -117,18 +300,33 @@ func (s *TaskBuilderService) SetIsFinal(userID int64, isFinal bool) bool {
 
 	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "is_final" {
 		builder.Task.IsFinal = isFinal
+		builder.CurrentStep = "due"
 		return true
 	}
 	return false
 }
 
-func (s *TaskBuilderService) CompleteTask(userID int64) (*models.Task, []uint, bool) {
+func (s *TaskBuilderService) SetDueHours(userID int64, dueHours int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if builder, exists := s.builders[userID]; exists && builder.CurrentStep == "due" && dueHours >= 0 {
+		builder.Task.DueHours = dueHours
+		return true
+	}
+	return false
+}
+
+func (s *TaskBuilderService) CompleteTask(userID int64) (*models.Task, []models.TaskPrerequisite, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if builder, exists := s.builders[userID]; exists {
 		task := builder.Task
-		prerequisites := builder.Prerequisites
+		prerequisites := make([]models.TaskPrerequisite, len(builder.Prerequisites))
+		for i, prerequisiteID := range builder.Prerequisites {
+			prerequisites[i] = models.TaskPrerequisite{PrerequisiteID: prerequisiteID, Condition: builder.Conditions[prerequisiteID]}
+		}
 		delete(s.builders, userID)
 		return &task, prerequisites, true
 	}
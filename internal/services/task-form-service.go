@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"bbb/internal/repository"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	TaskFormService interface {
+		AddField(userID int64, field *models.TaskFormField) error
+		DeleteField(userID int64, fieldID uint) (*models.TaskFormField, error)
+		GetFields(taskID uint) ([]models.TaskFormField, error)
+		GetStartFields(processID uint) ([]models.TaskFormField, error)
+		CheckAnswers(taskExecution *models.TaskExecution, answers []models.TaskFormAnswer) (map[string]string, error)
+		GetAnswers(taskExecutionID uint) ([]models.TaskFormAnswer, error)
+		GetProcessExecutionAnswers(processExecutionID uint) ([]models.TaskFormAnswer, error)
+		IsProcessOwner(userID int64, taskID uint) bool
+	}
+
+	taskFormService struct {
+		repo           repository.FormRepository
+		taskRepo       repository.TaskRepository
+		processService ProcessService
+	}
+)
+
+func NewTaskFormService(repo repository.FormRepository, taskRepo repository.TaskRepository, processService ProcessService) TaskFormService {
+	return &taskFormService{
+		repo:           repo,
+		taskRepo:       taskRepo,
+		processService: processService,
+	}
+}
+
+func (s *taskFormService) AddField(userID int64, field *models.TaskFormField) error {
+	if !s.ownsField(userID, field) {
+		return errors.New("فقط مالک فرایند می‌تواند فرم وظیفه را تغییر دهد")
+	}
+	if strings.TrimSpace(field.Label) == "" {
+		return errors.New("form field label is required")
+	}
+	if field.Variable != "" && !ValidVariableName(field.Variable) {
+		return errors.New("نام متغیر نباید فاصله یا آکولاد داشته باشد")
+	}
+	if field.Type == models.FormFieldTypeChoice && len(field.Choices()) < 2 {
+		return errors.New("فیلد چندگزینه‌ای باید حداقل دو گزینه داشته باشد")
+	}
+
+	var fields []models.TaskFormField
+	var err error
+	if field.TaskID == 0 {
+		fields, err = s.repo.GetFieldsByProcessID(field.ProcessID)
+	} else {
+		fields, err = s.repo.GetFieldsByTaskID(field.TaskID)
+	}
+	if err != nil {
+		return err
+	}
+	field.Position = len(fields) + 1
+	return s.repo.SaveField(field)
+}
+
+func (s *taskFormService) DeleteField(userID int64, fieldID uint) (*models.TaskFormField, error) {
+	field, err := s.repo.GetFieldByID(fieldID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.ownsField(userID, field) {
+		return nil, errors.New("فقط مالک فرایند می‌تواند فرم وظیفه را تغییر دهد")
+	}
+	return field, s.repo.DeleteField(fieldID)
+}
+
+func (s *taskFormService) GetFields(taskID uint) ([]models.TaskFormField, error) {
+	return s.repo.GetFieldsByTaskID(taskID)
+}
+
+// GetStartFields returns the fields the starter of a process is asked to fill
+func (s *taskFormService) GetStartFields(processID uint) ([]models.TaskFormField, error) {
+	return s.repo.GetFieldsByProcessID(processID)
+}
+
+// CheckAnswers checks the answers given to the form of a task execution and returns the process variables they set.
+// The answers are stored along with the completion of the task execution.
+func (s *taskFormService) CheckAnswers(taskExecution *models.TaskExecution, answers []models.TaskFormAnswer) (map[string]string, error) {
+	fields, err := s.repo.GetFieldsByTaskID(taskExecution.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	answered := make(map[uint]bool)
+	variables := make(map[string]string)
+	for i := range answers {
+		answers[i].TaskExecutionID = taskExecution.ID
+		answered[answers[i].FieldID] = true
+		if answers[i].Field != nil {
+			variables[answers[i].Field.VariableName()] = answers[i].Value
+		}
+	}
+	for _, field := range fields {
+		if field.Required && !answered[field.ID] {
+			return nil, errors.New("پاسخ فیلد «" + field.Label + "» الزامی است")
+		}
+	}
+	return variables, nil
+}
+
+func (s *taskFormService) GetAnswers(taskExecutionID uint) ([]models.TaskFormAnswer, error) {
+	return s.repo.GetAnswersByTaskExecutionID(taskExecutionID)
+}
+
+func (s *taskFormService) GetProcessExecutionAnswers(processExecutionID uint) ([]models.TaskFormAnswer, error) {
+	return s.repo.GetAnswersByProcessExecutionID(processExecutionID)
+}
+
+func (s *taskFormService) IsProcessOwner(userID int64, taskID uint) bool {
+	task, err := s.taskRepo.GetByID(taskID)
+	if err != nil {
+		return false
+	}
+	process, err := s.processService.GetProcessByID(task.ProcessID)
+	if err != nil {
+		return false
+	}
+	return process.UserID == userID
+}
+
+// ownsField reports whether the user owns the process the field, either of a task form or of the start form, belongs to
+func (s *taskFormService) ownsField(userID int64, field *models.TaskFormField) bool {
+	if field.TaskID != 0 {
+		return s.IsProcessOwner(userID, field.TaskID)
+	}
+	process, err := s.processService.GetProcessByID(field.ProcessID)
+	if err != nil {
+		return false
+	}
+	return process.UserID == userID
+}
+
+// ValidateFormInput checks a typed answer against its field and returns the value to store
+func ValidateFormInput(field *models.TaskFormField, input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("پاسخ نمی‌تواند خالی باشد")
+	}
+
+	switch field.Type {
+	case models.FormFieldTypeText:
+		return input, nil
+	case models.FormFieldTypeNumber:
+		normalized := strings.NewReplacer(",", "", "٬", "", "،", "", "٫", ".").Replace(NormalizeDigits(input))
+		if _, err := strconv.ParseFloat(normalized, 64); err != nil {
+			return "", errors.New("لطفا یک عدد معتبر وارد کنید")
+		}
+		return normalized, nil
+	case models.FormFieldTypeDate:
+		normalized := NormalizeDigits(input)
+		if _, err := time.Parse("2006-01-02", normalized); err != nil {
+			return "", errors.New("لطفا تاریخ را به شکل 2025-01-31 وارد کنید")
+		}
+		return normalized, nil
+	case models.FormFieldTypeBool:
+		switch strings.ToLower(input) {
+		case "بله", "yes", "true", "1":
+			return "true", nil
+		case "خیر", "no", "false", "0":
+			return "false", nil
+		}
+		return "", errors.New("لطفا «بله» یا «خیر» را انتخاب کنید")
+	case models.FormFieldTypeChoice:
+		for _, choice := range field.Choices() {
+			if choice == input {
+				return choice, nil
+			}
+		}
+		return "", errors.New("لطفا یکی از گزینه‌ها را انتخاب کنید")
+	case models.FormFieldTypeFile:
+		return "", errors.New("لطفا فایل یا عکس ارسال کنید")
+	}
+	return "", errors.New("unknown form field type")
+}
+
+// NormalizeDigits converts Persian and Arabic-Indic digits to ASCII digits
+func NormalizeDigits(input string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '۰' && r <= '۹':
+			return '0' + (r - '۰')
+		case r >= '٠' && r <= '٩':
+			return '0' + (r - '٠')
+		}
+		return r
+	}, input)
+}
@@ -5,6 +5,9 @@ import (
 	"bbb/internal/repository"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -14,32 +17,100 @@ type (
 	TaskService interface {
 		CreateTask(task *models.Task) error
 		StartTaskExecution(processExecutionID, taskID uint) (models.TaskExecution, error)
+		StartProcessExecution(processID uint, title string, variables map[string]string) (*models.ProcessExecution, []models.TaskExecution, error)
 		GetTaskByID(taskID uint) (*models.Task, error)
 		GetTasksByProcessID(processID uint) ([]models.Task, error)
 		AssignTask(taskExecutionID uint, userID int64) error
-		CompleteTask(taskExecutionID uint, userID int64) error
+		CompleteTask(taskExecutionID uint, userID int64, answers []models.TaskFormAnswer, note string) error
 		GetUserTasks(userID int64) ([]models.TaskExecution, error)
-		AddPrerequisite(taskID uint, prerequisiteID uint) error
+		AddPrerequisite(taskID uint, prerequisiteID uint, condition string) error
 		GetTaskPrerequisites(taskID uint) ([]uint, error)
 		IsFinalTask(taskID uint) (bool, error)
 		GetDependentTasks(taskID uint) ([]models.Task, error)
 		GetTaskExecutionByID(taskExecutionID uint) (*models.TaskExecution, error)
+		CancelProcessExecution(processExecutionID uint, userID int64) error
+		ReleaseTask(taskExecutionID uint, userID int64) error
+		RequestDelegation(taskExecutionID uint, userID int64, delegateID int64) error
+		AcceptDelegation(taskExecutionID uint, userID int64) error
+		DeclineDelegation(taskExecutionID uint, userID int64) error
+		ReassignTask(taskExecutionID uint, ownerID int64, userID int64) error
+		GetAssignmentLogs(taskExecutionID uint) ([]models.TaskAssignmentLog, error)
+		GetOpenTeamTasks(teamID uint) ([]models.TaskExecution, error)
+		ListUserTasks(userID int64, filter UserTaskFilter, page, pageSize int) ([]models.TaskExecution, int64, error)
+		GetTaskExecutionsByProcessExecutionID(processExecutionID uint) ([]models.TaskExecution, error)
+		RunScheduledTasks() error
+		RunServiceTasks() error
+		PublishOverdueTasks() error
+		RunNotificationEdits()
+		RenderTaskNotification(taskExecutionID uint, chatID int64) (tgbotapi.MessageConfig, bool, error)
 	}
 
+	// UserTaskFilter selects which task executions of a user are listed
+	UserTaskFilter string
+
 	taskService struct {
 		repo           repository.TaskRepository
 		teamService    TeamService
 		processService ProcessService
+		userService    UserService
+		formService    TaskFormService
+		attachments    AttachmentService
+		businessHours  *BusinessHours
+		webhookService WebhookService
+		eventService   EventService
+		outboxService  OutboxService
+		notifications  NotificationService
 		bot            *tgbotapi.BotAPI
+		locks          *executionLocks
+		edits          *notificationEdits
+	}
+
+	// executionLocks serializes the changes to each tree of process executions. The bot, the API and the
+	// scheduler all read the task lists of an execution, change them and write them back, which must not interleave.
+	executionLocks struct {
+		mu    sync.Mutex
+		locks map[uint]*executionLock
+	}
+
+	executionLock struct {
+		sync.Mutex
+		holders int
+	}
+
+	// notificationEdits holds the task executions whose messages are waiting to be edited. A task changed several
+	// times before the edit is queued once, and queuing never blocks the caller, who may hold an execution lock.
+	notificationEdits struct {
+		mu      sync.Mutex
+		pending map[uint]bool
+		wake    chan struct{}
 	}
 )
 
-func NewTaskService(repo repository.TaskRepository, teamService TeamService, processService ProcessService, bot *tgbotapi.BotAPI) TaskService {
+const (
+	UserTaskFilterAssigned  UserTaskFilter = "assigned"
+	UserTaskFilterAvailable UserTaskFilter = "available"
+	UserTaskFilterCompleted UserTaskFilter = "completed"
+)
+
+func NewTaskService(repo repository.TaskRepository, teamService TeamService, processService ProcessService, userService UserService, formService TaskFormService, attachments AttachmentService, businessHours *BusinessHours, webhookService WebhookService, eventService EventService, outboxService OutboxService, notifications NotificationService, bot *tgbotapi.BotAPI) TaskService {
+	if businessHours == nil {
+		businessHours = NewBusinessHours("", "", nil)
+	}
 	return &taskService{
 		repo:           repo,
 		teamService:    teamService,
 		processService: processService,
+		userService:    userService,
+		formService:    formService,
+		attachments:    attachments,
+		businessHours:  businessHours,
+		webhookService: webhookService,
+		eventService:   eventService,
+		outboxService:  outboxService,
+		notifications:  notifications,
 		bot:            bot,
+		locks:          &executionLocks{locks: make(map[uint]*executionLock)},
+		edits:          &notificationEdits{pending: make(map[uint]bool), wake: make(chan struct{}, 1)},
 	}
 }
 
@@ -56,23 +127,22 @@ func (s *taskService) GetTasksByProcessID(processID uint) ([]models.Task, error)
 }
 
 func (s *taskService) AssignTask(taskExecutionID uint, userID int64) error {
-	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	taskExecution, unlock, err := s.lockTaskExecution(taskExecutionID)
 	if err != nil {
 		return err
 	}
-	if taskExecution == nil {
-		return errors.New("وظیفه‌ی درحال اجرا با این شناسه یافت نشد!")
-	}
+	defer unlock()
 
-	if taskExecution.Status != models.TaskStatusPending {
+	now := time.Now()
+	claimed := models.TaskExecution{ID: taskExecutionID, Status: models.TaskStatusAssigned, UserID: &userID, AssignedAt: &now}
+	ok, err := s.repo.TransitionTaskExecution(&claimed, models.TaskStatusPending)
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return errors.New("وظیفه را فرد دیگری به عهده گرفت‌:(")
 	}
 
-	taskExecution.Status = models.TaskStatusAssigned
-	taskExecution.UserID = &userID
-	now := time.Now()
-	taskExecution.AssignedAt = &now
-
 	// Move task from pending to in-progress
 	processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
 	if err != nil {
@@ -94,62 +164,596 @@ func (s *taskService) AssignTask(taskExecutionID uint, userID int64) error {
 		return err
 	}
 
-	return s.repo.UpdateTaskExecution(taskExecution)
+	s.logAssignment(taskExecutionID, models.TaskAssignmentActionClaim, nil, &userID, userID)
+	s.refreshTaskNotifications(taskExecutionID)
+	s.publishTaskEvent(EventTaskClaimed, taskExecutionID)
+	return nil
 }
 
-func (s *taskService) CompleteTask(taskExecutionID uint, userID int64) error {
-	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+// CompleteTask completes a task execution of the user with the answers to its form and an optional note, moves its
+// process execution forward and tells the process owner. The bot and the API both complete tasks here, so the owner
+// hears of them the same way.
+func (s *taskService) CompleteTask(taskExecutionID uint, userID int64, answers []models.TaskFormAnswer, note string) error {
+	taskExecution, unlock, err := s.lockTaskExecution(taskExecutionID)
 	if err != nil {
 		return err
 	}
-	if taskExecution == nil {
-		return errors.New("task execution not found")
-	}
+	defer unlock()
 
 	if taskExecution.Status != models.TaskStatusAssigned || taskExecution.UserID == nil || *taskExecution.UserID != userID {
 		return errors.New("task is not assigned to you")
 	}
 
-	taskExecution.Status = models.TaskStatusCompleted
+	variables, err := s.formService.CheckAnswers(taskExecution, answers)
+	if err != nil {
+		return err
+	}
+	taskExecution.UserDescription = strings.TrimSpace(note)
+	if err := s.markCompleted(taskExecution, answers, variables); err != nil {
+		return err
+	}
+	s.advanceProcessExecution(taskExecution)
+
+	if taskExecution.Task.Process.UserID != userID {
+		s.notifyTaskCompleted(taskExecution)
+		s.notifyIfProcessCompleted(taskExecution)
+	}
+	return nil
+}
+
+// notifyTaskCompleted tells the process owner that a task execution was completed, with its form answers and attachments.
+// The notice and the files follow the owner's notification settings together: in quiet hours they are all held until
+// the quiet hours end.
+func (s *taskService) notifyTaskCompleted(taskExecution *models.TaskExecution) {
+	ownerID := taskExecution.Task.Process.UserID
+	deliverAt, ok := s.notifications.Schedule(ownerID, models.NotificationEventProcessCompleted, time.Now())
+	if !ok {
+		return
+	}
+	settings, err := s.notifications.GetSettings(ownerID)
+	if err != nil {
+		settings = &models.NotificationSettings{}
+	}
+	title := taskExecution.Task.Title
+	if taskExecution.InstanceItem != "" {
+		title += " — " + taskExecution.InstanceItem
+	}
+	notice := fmt.Sprintf("☑️ اعلان تکمیل وظیفه\n- فرایند: %s\n- اجرا: %s\n- وظیفه: %s\n- انجام دهنده: %s\n- تاریخ: %s",
+		taskExecution.Task.Process.Name,
+		executionName(taskExecution),
+		title,
+		userFullName(taskExecution.User),
+		taskExecution.CompletedAt.In(s.notifications.Location(settings)).Format("2006-01-02 15:04"))
+
+	var files []models.OutboxMessage
+	answers, err := s.formService.GetAnswers(taskExecution.ID)
+	if err != nil {
+		log.Printf("Error getting form answers of task execution %d: %v", taskExecution.ID, err)
+	} else if len(answers) > 0 {
+		notice += "\n\nاطلاعات ثبت شده:"
+		for _, answer := range answers {
+			if answer.Field == nil {
+				continue
+			}
+			notice += fmt.Sprintf("\n- %s: %s", answer.Field.Label, answer.DisplayValue())
+			if answer.Field.Type == models.FormFieldTypeFile {
+				files = append(files, models.OutboxMessage{Text: answer.Field.Label, FileID: answer.Value})
+			}
+		}
+	}
+	attachments, err := s.attachments.GetAttachments(taskExecution.ID)
+	if err != nil {
+		log.Printf("Error getting attachments of task execution %d: %v", taskExecution.ID, err)
+	}
+	for _, attachment := range attachments {
+		files = append(files, models.OutboxMessage{Text: strings.TrimSpace(title + "\n" + attachment.Caption), FileID: attachment.FileID, FileType: attachment.Type})
+	}
+	if len(attachments) > 0 {
+		notice += fmt.Sprintf("\n\n📎 %d پیوست در ادامه ارسال می‌شود.", len(attachments))
+	}
+
+	// The outbox sends in the order messages were queued, so the files follow the notice
+	messages := append([]models.OutboxMessage{{Text: notice}}, files...)
+	for i := range messages {
+		message := &messages[i]
+		message.ChatID = ownerID
+		message.Status = models.OutboxStatusPending
+		message.NextAttemptAt = &deliverAt
+		if err := s.outboxService.Enqueue(message); err != nil {
+			log.Printf("Error queuing completion notice of task execution %d: %v", taskExecution.ID, err)
+		}
+	}
+}
+
+// markCompleted moves a task execution from the open lists of its process execution to the completed one, storing the
+// form answers and process variables it leaves behind in the same transaction. It fails when the task execution no
+// longer has the status it was loaded with, e.g. when its process execution was cancelled in the meantime.
+func (s *taskService) markCompleted(taskExecution *models.TaskExecution, answers []models.TaskFormAnswer, variables map[string]string) error {
+	var processVariables []models.ProcessVariable
+	for name, value := range variables {
+		if name = strings.TrimSpace(name); name != "" {
+			processVariables = append(processVariables, models.ProcessVariable{ProcessExecutionID: taskExecution.ProcessExecutionID, Name: name, Value: value})
+		}
+	}
+
 	now := time.Now()
+	completed := models.TaskExecution{
+		ID:                 taskExecution.ID,
+		ProcessExecutionID: taskExecution.ProcessExecutionID,
+		Status:             models.TaskStatusCompleted,
+		CompletedAt:        &now,
+		UserDescription:    taskExecution.UserDescription,
+	}
+	ok, err := s.repo.CompleteTaskExecution(&completed, taskExecution.Status, answers, processVariables)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("این وظیفه دیگر باز نیست")
+	}
+	taskExecution.Status = models.TaskStatusCompleted
 	taskExecution.CompletedAt = &now
 
-	// Move task from in-progress to completed
-	processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
+	s.refreshTaskNotifications(taskExecution.ID)
+	s.publishTaskEvent(EventTaskCompleted, taskExecution.ID)
+	return nil
+}
+
+// advanceProcessExecution moves a process execution forward after one of its task executions is completed.
+// Completing the final task completes the execution, which in turn completes the call activity that started it
+// as a sub-process. The tasks depending on the completed task are then started.
+func (s *taskService) advanceProcessExecution(taskExecution *models.TaskExecution) {
+	task, err := s.repo.GetByID(taskExecution.TaskID)
+	if err != nil {
+		log.Printf("Error getting task %d: %v", taskExecution.TaskID, err)
+		return
+	}
+
+	if task.IsMultiInstance() {
+		done, err := s.closeInstances(task, taskExecution.ProcessExecutionID)
+		if err != nil {
+			log.Printf("Error checking instances of task %d: %v", task.ID, err)
+			return
+		}
+		if !done {
+			return
+		}
+	}
+
+	if task.IsFinal {
+		processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
+		if err != nil {
+			log.Printf("Error getting process execution %d: %v", taskExecution.ProcessExecutionID, err)
+			return
+		}
+		now := time.Now()
+		processExecution.Status = models.ProcessExecutionStatusCompleted
+		processExecution.CompletedAt = &now
+		if err := s.processService.UpdateProcessExecution(processExecution); err != nil {
+			log.Printf("Error updating process execution status to completed: %v", err)
+		} else {
+			s.eventService.PublishProcessEvent(EventProcessCompleted, processExecution)
+			if processExecution.ParentTaskExecutionID != nil {
+				s.completeCallActivity(*processExecution.ParentTaskExecutionID, processExecution)
+			}
+		}
+	}
+
+	dependencies, err := s.repo.GetDependencies(task.ID)
+	if err != nil {
+		log.Printf("Error getting dependent tasks for task %d: %v", task.ID, err)
+		return
+	}
+	var variables map[string]string
+	for _, dependency := range dependencies {
+		if dependency.Condition != "" {
+			if variables == nil {
+				if variables, err = s.processService.GetVariables(taskExecution.ProcessExecutionID); err != nil {
+					log.Printf("Error getting variables of process execution %d: %v", taskExecution.ProcessExecutionID, err)
+					return
+				}
+			}
+			holds, err := EvaluateCondition(dependency.Condition, variables)
+			if err != nil {
+				log.Printf("Error evaluating condition of dependent task %d: %v", dependency.TaskID, err)
+				s.notifyProcessOwner(task.ProcessID, fmt.Sprintf("خطا در بررسی شرط وظیفه وابسته %s: %s", s.taskTitle(dependency.TaskID), err.Error()))
+				continue
+			}
+			if !holds {
+				continue
+			}
+		}
+		if _, err := s.StartTaskExecution(taskExecution.ProcessExecutionID, dependency.TaskID); err != nil {
+			log.Printf("Error starting dependent task %d: %v", dependency.TaskID, err)
+			s.notifyProcessOwner(task.ProcessID, fmt.Sprintf("خطا در شروع وظیفه وابسته %s: %s", s.taskTitle(dependency.TaskID), err.Error()))
+		}
+	}
+}
+
+// taskTitle returns the title of a task for messages, falling back to its ID.
+func (s *taskService) taskTitle(taskID uint) string {
+	task, err := s.repo.GetByID(taskID)
+	if err != nil {
+		return fmt.Sprintf("#%d", taskID)
+	}
+	return task.Title
+}
+
+// closeInstances reports whether enough instances of a multi-instance task are completed for the step to finish.
+// Once they are, the instances that are still open are cancelled.
+func (s *taskService) closeInstances(task *models.Task, processExecutionID uint) (bool, error) {
+	taskExecutions, err := s.repo.GetTaskExecutionsByProcessExecutionID(processExecutionID)
+	if err != nil {
+		return false, err
+	}
+	var total, completed int
+	var open []uint
+	for _, taskExecution := range taskExecutions {
+		if taskExecution.TaskID != task.ID {
+			continue
+		}
+		total++
+		if taskExecution.Status == models.TaskStatusCompleted {
+			completed++
+		} else if taskExecution.IsOpen() {
+			open = append(open, taskExecution.ID)
+		}
+	}
+	required := total
+	if task.CompletionThreshold > 0 && task.CompletionThreshold < total {
+		required = task.CompletionThreshold
+	}
+	// Only the completion that reaches the threshold moves the process forward
+	if completed != required {
+		return false, nil
+	}
+	if len(open) == 0 {
+		return true, nil
+	}
+
+	cancelled, err := s.repo.CancelOpenTaskExecutions(processExecutionID, open)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range cancelled {
+		s.refreshTaskNotifications(id)
+	}
+	return true, nil
+}
+
+// completeCallActivity completes the task execution that is waiting for a finished sub-process execution,
+// copies the output variables back to the parent execution and moves the parent execution forward
+func (s *taskService) completeCallActivity(taskExecutionID uint, subExecution *models.ProcessExecution) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting call activity %d: %v", taskExecutionID, err)
+		return
+	}
+	if taskExecution.Status != models.TaskStatusWaiting {
+		return
+	}
+
+	variables, err := s.processService.GetVariables(subExecution.ID)
+	if err != nil {
+		log.Printf("Error getting variables of process execution %d: %v", subExecution.ID, err)
+	}
+	outputs := MapVariables(variables, taskExecution.Task.SubProcessOutputs)
+	if err := s.processService.SetVariables(taskExecution.ProcessExecutionID, outputs); err != nil {
+		log.Printf("Error copying sub-process variables to process execution %d: %v", taskExecution.ProcessExecutionID, err)
+	}
+
+	if err := s.markCompleted(taskExecution, nil, nil); err != nil {
+		log.Printf("Error completing call activity %d: %v", taskExecutionID, err)
+		return
+	}
+	s.notifyProcessProgress(taskExecution.Task.ProcessID, fmt.Sprintf("🔗 زیرفرایند اجرای %s به پایان رسید و وظیفه «%s» در اجرای %s تکمیل شد.",
+		subExecution.DisplayName(), taskExecution.Task.Title, executionName(taskExecution)))
+
+	s.advanceProcessExecution(taskExecution)
+
+	s.notifyIfProcessCompleted(taskExecution)
+}
+
+// RunScheduledTasks completes the timer task executions whose waiting time is over, moving their process executions
+// forward. The wake-up times are stored with the task executions, so scheduled tasks survive restarts of the bot.
+func (s *taskService) RunScheduledTasks() error {
+	taskExecutions, err := s.repo.GetDueScheduledTaskExecutions(time.Now())
 	if err != nil {
 		return err
 	}
+	for i := range taskExecutions {
+		taskExecution := &taskExecutions[i]
+		if taskExecution.Task.IsService() {
+			continue
+		}
+		unlock := s.lockExecution(taskExecution.ProcessExecutionID)
+		s.completeScheduledTask(taskExecution)
+		unlock()
+	}
+	return nil
+}
 
-	// Remove from in-progress
-	for i, id := range processExecution.InProgressTaskExecutionIDs {
-		if id == taskExecutionID {
-			processExecution.InProgressTaskExecutionIDs = append(processExecution.InProgressTaskExecutionIDs[:i], processExecution.InProgressTaskExecutionIDs[i+1:]...)
-			break
+// RunServiceTasks calls the webhooks of the waiting service task executions and moves their process executions
+// forward with the response. A webhook may take a while to answer, so this runs apart from the timers.
+func (s *taskService) RunServiceTasks() error {
+	taskExecutions, err := s.repo.GetDueScheduledTaskExecutions(time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range taskExecutions {
+		taskExecution := &taskExecutions[i]
+		if !taskExecution.Task.IsService() {
+			continue
+		}
+		fields, callErr := s.callServiceTask(taskExecution)
+
+		// The webhook is called without the lock, so the process execution may have been cancelled meanwhile
+		unlock := s.lockExecution(taskExecution.ProcessExecutionID)
+		current, err := s.repo.GetTaskExecutionByID(taskExecution.ID)
+		switch {
+		case err != nil:
+			log.Printf("Error getting task execution %d: %v", taskExecution.ID, err)
+		case current.Status != models.TaskStatusWaiting:
+		case callErr != nil:
+			s.failServiceTask(taskExecution, callErr)
+		default:
+			if err := s.processService.SetVariables(taskExecution.ProcessExecutionID, fields); err != nil {
+				log.Printf("Error storing webhook response of task execution %d: %v", taskExecution.ID, err)
+			}
+			s.completeScheduledTask(taskExecution)
 		}
+		unlock()
 	}
+	return nil
+}
 
-	// Add to completed
-	processExecution.CompletedTaskExecutionIDs = append(processExecution.CompletedTaskExecutionIDs, taskExecutionID)
+// completeScheduledTask completes a timer or service task execution and moves its process execution forward.
+// The caller holds the lock of the process execution tree.
+func (s *taskService) completeScheduledTask(taskExecution *models.TaskExecution) {
+	if err := s.markCompleted(taskExecution, nil, nil); err != nil {
+		log.Printf("Error completing scheduled task execution %d: %v", taskExecution.ID, err)
+		return
+	}
+	s.advanceProcessExecution(taskExecution)
+	s.notifyIfProcessCompleted(taskExecution)
+}
 
-	if err := s.processService.UpdateProcessExecution(processExecution); err != nil {
+// PublishOverdueTasks publishes an overdue event for every open task execution that has passed its deadline
+// and reminds the assignee, or the team when nobody claimed it yet. Each task execution is reported once.
+func (s *taskService) PublishOverdueTasks() error {
+	now := time.Now()
+	taskExecutions, err := s.repo.GetOverdueTaskExecutions(now)
+	if err != nil {
 		return err
 	}
+	for i := range taskExecutions {
+		taskExecution := &taskExecutions[i]
+		reported := models.TaskExecution{ID: taskExecution.ID, OverdueAt: &now}
+		if err := s.repo.UpdateTaskExecution(&reported); err != nil {
+			log.Printf("Error marking task execution %d as overdue: %v", taskExecution.ID, err)
+			continue
+		}
+		s.eventService.PublishTaskEvent(EventTaskOverdue, taskExecution)
+		s.remindOverdue(taskExecution)
+	}
+	return nil
+}
+
+// remindOverdue reminds whoever should finish an overdue task execution, by their notification settings
+func (s *taskService) remindOverdue(taskExecution *models.TaskExecution) {
+	var recipients []int64
+	if taskExecution.UserID != nil {
+		recipients = []int64{*taskExecution.UserID}
+	} else if members, err := s.taskTeamMembers(taskExecution.Task); err == nil {
+		recipients = s.resolveRecipients(members)
+	}
+
+	title := taskExecution.Task.Title
+	if taskExecution.InstanceItem != "" {
+		title += " — " + taskExecution.InstanceItem
+	}
+	text := fmt.Sprintf("⏰ یادآوری: مهلت وظیفه «%s» از فرایند «%s» (اجرای %s) به پایان رسیده است.",
+		title, taskExecution.Task.Process.Name, executionName(taskExecution))
+	if taskExecution.UserID == nil {
+		// A team that works in a group is reminded once in the group, where the task was announced
+		if chatID, ok := s.teamGroupChatID(taskExecution.Task); ok {
+			text += "\nهنوز کسی این وظیفه را به عهده نگرفته است؛ از پیام وظیفه در همین گروه می‌توانید آن را بردارید."
+			if err := s.outboxService.Enqueue(&models.OutboxMessage{ChatID: chatID, Text: text, Status: models.OutboxStatusPending}); err != nil {
+				log.Printf("Error reminding group %d of task execution %d: %v", chatID, taskExecution.ID, err)
+			}
+			return
+		}
+		text += "\nهنوز کسی این وظیفه را به عهده نگرفته است؛ از «وظایف من» می‌توانید آن را بردارید."
+	}
+	for _, recipient := range recipients {
+		if err := s.notifications.Notify(recipient, models.NotificationEventReminder, text); err != nil {
+			log.Printf("Error reminding user %d of task execution %d: %v", recipient, taskExecution.ID, err)
+		}
+	}
+}
+
+// callServiceTask posts the execution context and variables of a service task execution to its webhook
+func (s *taskService) callServiceTask(taskExecution *models.TaskExecution) (map[string]string, error) {
+	variables, err := s.processService.GetVariables(taskExecution.ProcessExecutionID)
+	if err != nil {
+		return nil, err
+	}
+	payload := ServiceTaskPayload{
+		Event:              "service_task",
+		ProcessID:          taskExecution.Task.ProcessID,
+		ProcessName:        taskExecution.Task.Process.Name,
+		ProcessExecutionID: taskExecution.ProcessExecutionID,
+		TaskID:             taskExecution.TaskID,
+		TaskTitle:          taskExecution.Task.Title,
+		TaskExecutionID:    taskExecution.ID,
+		Variables:          variables,
+	}
+	if taskExecution.ProcessExecution != nil {
+		payload.ExecutionTitle = taskExecution.ProcessExecution.Title
+	}
+	return s.webhookService.Call(taskExecution.Task.WebhookURL, taskExecution.Task.WebhookSecret, payload)
+}
+
+// failServiceTask marks a service task execution as failed, which fails its process execution, and tells the process owner
+func (s *taskService) failServiceTask(taskExecution *models.TaskExecution, callErr error) {
+	log.Printf("Service task execution %d failed: %v", taskExecution.ID, callErr)
+	s.failTaskExecution(taskExecution, fmt.Sprintf("❌ وظیفه خودکار «%s» در اجرای %s ناموفق بود و فرایند اجرایی متوقف شد.\nخطا: %s",
+		taskExecution.Task.Title, executionName(taskExecution), callErr.Error()))
+}
+
+// failTaskExecution marks a task execution run by the engine as failed and fails its process execution.
+// When that execution is a sub-process, the call activity waiting for it fails too, up to the top execution,
+// and the owner of each failed process is told.
+func (s *taskService) failTaskExecution(taskExecution *models.TaskExecution, text string) {
+	failed := models.TaskExecution{ID: taskExecution.ID, Status: models.TaskStatusFailed}
+	ok, err := s.repo.TransitionTaskExecution(&failed, taskExecution.Status)
+	if err != nil {
+		log.Printf("Error marking task execution %d as failed: %v", taskExecution.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting process execution %d: %v", taskExecution.ProcessExecutionID, err)
+		return
+	}
+	processExecution.PendingTaskExecutionIDs = removeTaskExecutionID(processExecution.PendingTaskExecutionIDs, taskExecution.ID)
+	processExecution.InProgressTaskExecutionIDs = removeTaskExecutionID(processExecution.InProgressTaskExecutionIDs, taskExecution.ID)
+	if err := s.closeProcessExecution(processExecution, models.ProcessExecutionStatusFailed); err != nil {
+		log.Printf("Error failing process execution %d: %v", processExecution.ID, err)
+	}
+
+	s.notifyProcessOwner(taskExecution.Task.ProcessID, text)
+
+	if processExecution.ParentTaskExecutionID != nil {
+		s.failCallActivity(*processExecution.ParentTaskExecutionID, processExecution)
+	}
+}
+
+// failCallActivity fails the task execution that is waiting for a failed sub-process execution, along with its process execution
+func (s *taskService) failCallActivity(taskExecutionID uint, subExecution *models.ProcessExecution) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting call activity %d: %v", taskExecutionID, err)
+		return
+	}
+	if taskExecution.Status != models.TaskStatusWaiting {
+		return
+	}
+	s.failTaskExecution(taskExecution, fmt.Sprintf("❌ زیرفرایند اجرای %s ناموفق بود، پس وظیفه «%s» در اجرای %s ناموفق ماند و فرایند اجرایی متوقف شد.",
+		subExecution.DisplayName(), taskExecution.Task.Title, executionName(taskExecution)))
+}
+
+// notifyIfProcessCompleted tells the process owner when a task execution completed by the engine finished its process execution
+func (s *taskService) notifyIfProcessCompleted(taskExecution *models.TaskExecution) {
+	processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
+	if err != nil || processExecution.Status != models.ProcessExecutionStatusCompleted {
+		return
+	}
+	s.notifyProcessProgress(taskExecution.Task.ProcessID, fmt.Sprintf("✅ اعلان تکمیل فرایند\n- فرایند: %s\n- اجرا: %s",
+		taskExecution.Task.Process.Name, processExecution.DisplayName()))
+}
+
+// notifyProcessOwner tells the process owner about a problem right away, whatever their notification settings
+func (s *taskService) notifyProcessOwner(processID uint, text string) {
+	process, err := s.processService.GetProcessByID(processID)
+	if err != nil {
+		log.Printf("Error getting process %d: %v", processID, err)
+		return
+	}
+	if err := s.outboxService.Enqueue(&models.OutboxMessage{ChatID: process.UserID, Text: text, Status: models.OutboxStatusPending}); err != nil {
+		log.Printf("Error notifying owner of process %d: %v", processID, err)
+	}
+}
+
+// notifyProcessProgress tells the process owner how their process moves forward, by their notification settings
+func (s *taskService) notifyProcessProgress(processID uint, text string) {
+	process, err := s.processService.GetProcessByID(processID)
+	if err != nil {
+		log.Printf("Error getting process %d: %v", processID, err)
+		return
+	}
+	if err := s.notifications.Notify(process.UserID, models.NotificationEventProcessCompleted, text); err != nil {
+		log.Printf("Error notifying owner of process %d: %v", processID, err)
+	}
+}
 
-	return s.repo.UpdateTaskExecution(taskExecution)
+func executionName(taskExecution *models.TaskExecution) string {
+	if taskExecution.ProcessExecution != nil {
+		return taskExecution.ProcessExecution.DisplayName()
+	}
+	return fmt.Sprintf("#%d", taskExecution.ProcessExecutionID)
 }
 
 func (s *taskService) GetUserTasks(userID int64) ([]models.TaskExecution, error) {
 	return s.repo.GetTaskExecutionsByUserID(userID)
 }
 
-func (s *taskService) AddPrerequisite(taskID uint, prerequisiteID uint) error {
-	return s.repo.AddPrerequisite(taskID, prerequisiteID)
+// AddPrerequisite makes a task wait for a prerequisite task.
+// A non-empty condition such as "{{amount}} > 1000" starts the task only when it holds for the process variables.
+func (s *taskService) AddPrerequisite(taskID uint, prerequisiteID uint, condition string) error {
+	if err := ValidateCondition(condition); err != nil {
+		return err
+	}
+	return s.repo.AddPrerequisite(taskID, prerequisiteID, strings.TrimSpace(condition))
 }
 
 func (s *taskService) GetTaskPrerequisites(taskID uint) ([]uint, error) {
 	return s.repo.GetPrerequisites(taskID)
 }
 
+// StartProcessExecution starts an execution of a process with the given title and start form values,
+// and starts the tasks of the process that have no prerequisites. Tasks that fail to start are logged
+// and left out of the returned task executions.
+func (s *taskService) StartProcessExecution(processID uint, title string, variables map[string]string) (*models.ProcessExecution, []models.TaskExecution, error) {
+	return s.startProcessExecution(processID, title, variables, nil)
+}
+
+func (s *taskService) startProcessExecution(processID uint, title string, variables map[string]string, parentTaskExecutionID *uint) (*models.ProcessExecution, []models.TaskExecution, error) {
+	execution, err := s.processService.StartProcessExecution(processID, title, parentTaskExecutionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error starting process execution: %v", err)
+	}
+	// A sub-process is started while the tree of its parent is locked already
+	if parentTaskExecutionID == nil {
+		defer s.lockExecution(execution.ID)()
+	}
+	if err := s.processService.SetVariables(execution.ID, variables); err != nil {
+		return execution, nil, fmt.Errorf("error setting process variables: %v", err)
+	}
+	s.eventService.PublishProcessEvent(EventProcessStarted, execution)
+
+	tasks, err := s.repo.GetByProcessID(processID)
+	if err != nil {
+		return execution, nil, fmt.Errorf("error getting tasks: %v", err)
+	}
+
+	var started []models.TaskExecution
+	for i := range tasks {
+		task := &tasks[i]
+		preTaskIDs, err := s.GetTaskPrerequisites(task.ID)
+		if err != nil {
+			log.Printf("Error getting prerequisites for task %d: %v", task.ID, err)
+			continue
+		}
+		if len(preTaskIDs) > 0 {
+			continue
+		}
+
+		taskExecution, err := s.StartTaskExecution(execution.ID, task.ID)
+		if err != nil {
+			log.Printf("Error starting task execution for task %d in process exec %d: %v", task.ID, execution.ID, err)
+			continue
+		}
+		taskExecution.Task = task
+		started = append(started, taskExecution)
+	}
+	return execution, started, nil
+}
+
 func (s *taskService) StartTaskExecution(processExecutionID, taskID uint) (models.TaskExecution, error) {
 	// Get prerequisites
 	preTaskIDs, err := s.GetTaskPrerequisites(taskID)
@@ -162,24 +766,36 @@ func (s *taskService) StartTaskExecution(processExecutionID, taskID uint) (model
 	if err != nil {
 		return models.TaskExecution{}, fmt.Errorf("error getting process execution: %v", err)
 	}
+	if processExecution.Status == models.ProcessExecutionStatusCancelled {
+		return models.TaskExecution{}, errors.New("process execution is cancelled")
+	}
 
 	// Check if all prerequisites are completed
-	for _, preTaskID := range preTaskIDs {
-		found := false
-		for _, TEID := range processExecution.CompletedTaskExecutionIDs {
-			te, err := s.GetTaskExecutionByID(TEID)
-			if err != nil {
-				return models.TaskExecution{}, fmt.Errorf("error getting task executions: %v", err)
-			}
-			if te.TaskID == preTaskID {
-				found = true
-				break
+	if len(preTaskIDs) > 0 {
+		taskExecutions, err := s.repo.GetTaskExecutionsByProcessExecutionID(processExecutionID)
+		if err != nil {
+			return models.TaskExecution{}, fmt.Errorf("error getting task executions: %v", err)
+		}
+		for _, preTaskID := range preTaskIDs {
+			if !stepCompleted(taskExecutions, preTaskID) {
+				return models.TaskExecution{}, fmt.Errorf("prerequisite task %d is not completed in this process execution", preTaskID)
 			}
 		}
-		if !found {
-			return models.TaskExecution{}, fmt.Errorf("prerequisite task %d is not completed in this process execution", preTaskID)
+	}
+
+	task, err := s.repo.GetByID(taskID)
+	if err != nil {
+		return models.TaskExecution{}, fmt.Errorf("error getting task: %v", err)
+	}
+
+	if task.IsSubProcess() {
+		if err := s.checkSubProcessCycle(*task.SubProcessID, processExecution); err != nil {
+			return models.TaskExecution{}, err
 		}
 	}
+	if task.IsMultiInstance() && !task.IsSubProcess() && !task.IsTimer() && !task.IsService() {
+		return s.startInstances(task, processExecution)
+	}
 
 	// Start the task execution
 	taskExecution := models.TaskExecution{
@@ -187,63 +803,248 @@ func (s *taskService) StartTaskExecution(processExecutionID, taskID uint) (model
 		ProcessExecutionID: processExecutionID,
 		Status:             models.TaskStatusPending,
 	}
+	if task.IsTimer() {
+		variables, err := s.processService.GetVariables(processExecutionID)
+		if err != nil {
+			return models.TaskExecution{}, fmt.Errorf("error getting process variables: %v", err)
+		}
+		wakeAt, err := TimerWakeAt(task, time.Now(), variables, s.businessHours)
+		if err != nil {
+			return models.TaskExecution{}, err
+		}
+		taskExecution.Status = models.TaskStatusWaiting
+		taskExecution.WakeAt = &wakeAt
+	} else if task.IsService() {
+		// Service tasks are picked up by the service task worker right away, so the bot never waits for the webhook
+		now := time.Now()
+		taskExecution.Status = models.TaskStatusWaiting
+		taskExecution.WakeAt = &now
+	} else if task.IsSubProcess() {
+		taskExecution.Status = models.TaskStatusWaiting
+	} else if task.DueHours > 0 {
+		dueAt := time.Now().Add(time.Duration(task.DueHours) * time.Hour)
+		taskExecution.DueAt = &dueAt
+	}
 
-	if err := s.repo.SaveTaskExecution(&taskExecution); err != nil {
-		return models.TaskExecution{}, fmt.Errorf("error starting task execution: %v", err)
+	// Timers, service tasks and call activities are run by the engine, so nobody is notified.
+	// The team of other tasks is looked up first, so a task is never started without anyone to announce it to.
+	var recipients []int64
+	if !task.IsTimer() && !task.IsService() && !task.IsSubProcess() {
+		members, err := s.taskTeamMembers(task)
+		if err != nil {
+			return models.TaskExecution{}, err
+		}
+		recipients = s.resolveRecipients(members)
 	}
 
-	// Add to pending tasks
+	taskExecutions := []models.TaskExecution{taskExecution}
+	if err := s.repo.SaveTaskExecutionsWithOutbox(taskExecutions, func(taskExecution *models.TaskExecution) []models.OutboxMessage {
+		return s.taskNotificationMessages(task, taskExecution, recipients)
+	}); err != nil {
+		return models.TaskExecution{}, fmt.Errorf("error starting task execution: %v", err)
+	}
+	taskExecution = taskExecutions[0]
 	processExecution.PendingTaskExecutionIDs = append(processExecution.PendingTaskExecutionIDs, taskExecution.ID)
-	if err := s.processService.UpdateProcessExecution(processExecution); err != nil {
-		return models.TaskExecution{}, fmt.Errorf("error updating process execution: %v", err)
+	s.outboxService.Wake()
+	s.publishTaskEvent(EventTaskActivated, taskExecution.ID)
+
+	if task.IsSubProcess() {
+		if err := s.startSubProcess(task, &taskExecution, processExecution); err != nil {
+			return models.TaskExecution{}, fmt.Errorf("error starting sub-process: %v", err)
+		}
 	}
+	return taskExecution, nil
+}
 
-	// Get task details for notification
-	task, err := s.repo.GetByID(taskID)
+// startInstances starts the task executions of a multi-instance task: one assigned to each team member,
+// or one open to the team for each item of a list variable. It returns the first instance.
+func (s *taskService) startInstances(task *models.Task, processExecution *models.ProcessExecution) (models.TaskExecution, error) {
+	members, err := s.taskTeamMembers(task)
 	if err != nil {
-		return models.TaskExecution{}, fmt.Errorf("error getting task: %v", err)
+		return models.TaskExecution{}, err
+	}
+	recipients := s.resolveRecipients(members)
+
+	var instances []models.TaskExecution
+	switch task.MultiInstance {
+	case models.MultiInstanceMembers:
+		now := time.Now()
+		for _, recipient := range recipients {
+			userID := recipient
+			instances = append(instances, models.TaskExecution{Status: models.TaskStatusAssigned, UserID: &userID, AssignedAt: &now})
+		}
+	case models.MultiInstanceList:
+		variables, err := s.processService.GetVariables(processExecution.ID)
+		if err != nil {
+			return models.TaskExecution{}, fmt.Errorf("error getting process variables: %v", err)
+		}
+		for _, item := range SplitListVariable(variables[task.MultiInstanceVariable]) {
+			instances = append(instances, models.TaskExecution{Status: models.TaskStatusPending, InstanceItem: item})
+		}
+	default:
+		return models.TaskExecution{}, fmt.Errorf("unknown multi-instance mode: %s", task.MultiInstance)
+	}
+	if len(instances) == 0 {
+		return models.TaskExecution{}, fmt.Errorf("فهرست «%s» برای ایجاد نمونه‌های وظیفه خالی است", task.MultiInstanceVariable)
+	}
+
+	now := time.Now()
+	for i := range instances {
+		instance := &instances[i]
+		instance.TaskID = task.ID
+		instance.ProcessExecutionID = processExecution.ID
+		if task.DueHours > 0 {
+			dueAt := now.Add(time.Duration(task.DueHours) * time.Hour)
+			instance.DueAt = &dueAt
+		}
+		if instance.UserID != nil {
+			instance.User, _ = s.userService.GetUserByID(*instance.UserID)
+		}
+	}
+	if err := s.repo.SaveTaskExecutionsWithOutbox(instances, func(instance *models.TaskExecution) []models.OutboxMessage {
+		if instance.UserID != nil {
+			return s.taskNotificationMessages(task, instance, []int64{*instance.UserID})
+		}
+		return s.taskNotificationMessages(task, instance, recipients)
+	}); err != nil {
+		return models.TaskExecution{}, fmt.Errorf("error starting task execution: %v", err)
 	}
-	if task == nil {
-		return models.TaskExecution{}, errors.New("task not found")
+	s.outboxService.Wake()
+
+	for i := range instances {
+		instance := &instances[i]
+		if instance.Status == models.TaskStatusAssigned {
+			processExecution.InProgressTaskExecutionIDs = append(processExecution.InProgressTaskExecutionIDs, instance.ID)
+			s.logAssignment(instance.ID, models.TaskAssignmentActionClaim, nil, instance.UserID, *instance.UserID)
+		} else {
+			processExecution.PendingTaskExecutionIDs = append(processExecution.PendingTaskExecutionIDs, instance.ID)
+		}
+		s.publishTaskEvent(EventTaskActivated, instance.ID)
 	}
+	return instances[0], nil
+}
 
+// taskTeamMembers returns the members of the team responsible for a task
+func (s *taskService) taskTeamMembers(task *models.Task) ([]models.User, error) {
 	if task.TeamID == nil {
-		return models.TaskExecution{}, errors.New("task has no team assigned")
+		return nil, errors.New("task has no team assigned")
 	}
 
 	team, err := s.teamService.GetTeamByID(*task.TeamID)
 	if err != nil {
-		return models.TaskExecution{}, fmt.Errorf("error getting team: %v", err)
+		return nil, fmt.Errorf("error getting team: %v", err)
 	}
 	if team == nil {
-		return models.TaskExecution{}, errors.New("team not found")
+		return nil, errors.New("team not found")
 	}
 
 	members, err := s.teamService.GetTeamMembers(team.ID)
 	if err != nil {
-		return models.TaskExecution{}, fmt.Errorf("error getting team members: %v", err)
+		return nil, fmt.Errorf("error getting team members: %v", err)
 	}
 	if len(members) == 0 {
-		return models.TaskExecution{}, errors.New("team has no members")
+		return nil, errors.New("team has no members")
 	}
+	return members, nil
+}
 
-	taskMsg := fmt.Sprintf("وظیفه با اطلاعات زیر فعال شده است، اگر تمایل دارید که انجام دهید اعلام کنید.\n\nعنوان: %s\nتوضیحات: %s",
-		task.Title, task.Description)
+// taskNotificationMessages builds the outbox messages announcing a task execution to the recipients. Task executions
+// open to the team follow the recipients' notification settings: recipients in their quiet hours get the message when
+// the quiet hours end, and those who turned new team tasks off or left them to their digest get none. When the team
+// works in a group chat, such task executions are instead announced once in the group, where any member can claim
+// them. Task executions assigned to someone are announced right away. The messages only name the task execution and
+// the recipient; the outbox renders them when they are sent. Once sent, they are recorded as task notifications so
+// later changes can edit them.
+func (s *taskService) taskNotificationMessages(task *models.Task, taskExecution *models.TaskExecution, recipients []int64) []models.OutboxMessage {
+	groupChatID, inGroup := s.teamGroupChatID(task)
+	if taskExecution.UserID == nil && inGroup {
+		recipients = []int64{groupChatID}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	var ownerID int64
+	if process, err := s.processService.GetProcessByID(task.ProcessID); err == nil {
+		ownerID = process.UserID
+	}
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("به عهده گرفتن وظیفه", fmt.Sprintf("take_task_%d", taskExecution.ID)),
-		),
-	)
+	now := time.Now()
+	messages := make([]models.OutboxMessage, 0, len(recipients))
+	for _, recipient := range recipients {
+		deliverAt := now
+		if taskExecution.UserID == nil && !inGroup {
+			var ok bool
+			if deliverAt, ok = s.notifications.Schedule(recipient, models.NotificationEventTeamTask, now); !ok {
+				continue
+			}
+		}
+		taskExecutionID := taskExecution.ID
+		messages = append(messages, models.OutboxMessage{
+			TaskExecutionID: &taskExecutionID,
+			OwnerID:         ownerID,
+			ChatID:          recipient,
+			Status:          models.OutboxStatusPending,
+			NextAttemptAt:   &deliverAt,
+		})
+	}
+	return messages
+}
 
-	for _, member := range members {
-		msg := tgbotapi.NewMessage(member.ID, taskMsg)
-		msg.ReplyMarkup = keyboard
-		if _, err := s.bot.Send(msg); err != nil {
-			return models.TaskExecution{}, fmt.Errorf("error sending message to user %d: %v", member.ID, err)
+// teamGroupChatID returns the group chat of the team responsible for a task, if the team works in one
+func (s *taskService) teamGroupChatID(task *models.Task) (int64, bool) {
+	if task.TeamID == nil {
+		return 0, false
+	}
+	team, err := s.teamService.GetTeamByID(*task.TeamID)
+	if err != nil || team.GroupChatID == nil {
+		return 0, false
+	}
+	return *team.GroupChatID, true
+}
+
+// startSubProcess starts the sub-process execution of a call activity with the input variables of the parent execution
+func (s *taskService) startSubProcess(task *models.Task, taskExecution *models.TaskExecution, parent *models.ProcessExecution) error {
+	variables, err := s.processService.GetVariables(parent.ID)
+	if err != nil {
+		return err
+	}
+	title := RenderVariables(task.Title, variables)
+	if parent.Title != "" {
+		title = parent.Title + " / " + title
+	}
+	if len([]rune(title)) > 200 {
+		title = string([]rune(title)[:200])
+	}
+
+	_, started, err := s.startProcessExecution(*task.SubProcessID, title, MapVariables(variables, task.SubProcessInputs), &taskExecution.ID)
+	if err != nil {
+		return err
+	}
+	if len(started) == 0 {
+		return errors.New("هیچ وظیفه‌ای از زیرفرایند شروع نشد")
+	}
+	return nil
+}
+
+// checkSubProcessCycle prevents a call activity from starting a process that is already running above it
+func (s *taskService) checkSubProcessCycle(subProcessID uint, execution *models.ProcessExecution) error {
+	for execution != nil {
+		if execution.ProcessID == subProcessID {
+			return errors.New("زیرفرایند نمی‌تواند یکی از فرایندهای والد خود را اجرا کند")
+		}
+		if execution.ParentTaskExecutionID == nil {
+			return nil
+		}
+		parentTask, err := s.repo.GetTaskExecutionByID(*execution.ParentTaskExecutionID)
+		if err != nil {
+			return err
+		}
+		execution, err = s.processService.GetProcessExecutionByID(parentTask.ProcessExecutionID)
+		if err != nil {
+			return err
 		}
 	}
-	return taskExecution, nil
+	return nil
 }
 
 func (s *taskService) IsFinalTask(taskID uint) (bool, error) {
@@ -261,3 +1062,636 @@ func (s *taskService) GetDependentTasks(taskID uint) ([]models.Task, error) {
 func (s *taskService) GetTaskExecutionByID(taskExecutionID uint) (*models.TaskExecution, error) {
 	return s.repo.GetTaskExecutionByID(taskExecutionID)
 }
+
+// CancelProcessExecution stops a running process execution and closes its open task executions
+func (s *taskService) CancelProcessExecution(processExecutionID uint, userID int64) error {
+	defer s.lockExecution(processExecutionID)()
+
+	processExecution, err := s.processService.GetProcessExecutionByID(processExecutionID)
+	if err != nil {
+		return err
+	}
+
+	if processExecution.Status == models.ProcessExecutionStatusCompleted || processExecution.Status == models.ProcessExecutionStatusCancelled {
+		return errors.New("این فرایند اجرایی قبلا به پایان رسیده است")
+	}
+
+	process, err := s.processService.GetProcessByID(processExecution.ProcessID)
+	if err != nil {
+		return err
+	}
+	if process.UserID != userID {
+		return errors.New("فقط مالک فرایند می‌تواند آن را لغو کند")
+	}
+
+	return s.closeProcessExecution(processExecution, models.ProcessExecutionStatusCancelled)
+}
+
+// closeProcessExecution ends a process execution with the given status, cancelling its open task executions
+// along with the sub-process executions its call activities are waiting for
+func (s *taskService) closeProcessExecution(processExecution *models.ProcessExecution, status models.ProcessExecutionStatus) error {
+	openTaskExecutionIDs := append(processExecution.PendingTaskExecutionIDs, processExecution.InProgressTaskExecutionIDs...)
+	for _, id := range openTaskExecutionIDs {
+		if subExecution, err := s.processService.GetSubProcessExecution(id); err == nil {
+			if subExecution.Status == models.ProcessExecutionStatusPending || subExecution.Status == models.ProcessExecutionStatusRunning {
+				if err := s.closeProcessExecution(subExecution, models.ProcessExecutionStatusCancelled); err != nil {
+					return err
+				}
+			}
+		}
+		taskExecution := models.TaskExecution{ID: id, Status: models.TaskStatusCancelled}
+		if err := s.repo.UpdateTaskExecution(&taskExecution); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	processExecution.Status = status
+	processExecution.CompletedAt = &now
+	processExecution.PendingTaskExecutionIDs = nil
+	processExecution.InProgressTaskExecutionIDs = nil
+	if err := s.processService.UpdateProcessExecution(processExecution); err != nil {
+		return err
+	}
+	if status == models.ProcessExecutionStatusFailed {
+		s.eventService.PublishProcessEvent(EventProcessFailed, processExecution)
+	} else {
+		s.eventService.PublishProcessEvent(EventProcessCancelled, processExecution)
+	}
+
+	for _, id := range openTaskExecutionIDs {
+		s.refreshTaskNotifications(id)
+	}
+	return nil
+}
+
+// publishTaskEvent posts a lifecycle event of a task execution to the webhook subscriptions of its process and team
+func (s *taskService) publishTaskEvent(event string, taskExecutionID uint) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting task execution %d for %s event: %v", taskExecutionID, event, err)
+		return
+	}
+	s.eventService.PublishTaskEvent(event, taskExecution)
+}
+
+// refreshTaskNotifications has the messages sent for a task execution edited to reflect its current state.
+// The edits are left to RunNotificationEdits, so changing a task never waits for the bulk sends.
+func (s *taskService) refreshTaskNotifications(taskExecutionID uint) {
+	s.edits.mu.Lock()
+	s.edits.pending[taskExecutionID] = true
+	s.edits.mu.Unlock()
+	select {
+	case s.edits.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunNotificationEdits edits the messages of the task executions that changed, one at a time. Each edit reads the
+// state of the task when it runs, so an older state never overwrites a newer one. It runs until the program exits.
+func (s *taskService) RunNotificationEdits() {
+	for range s.edits.wake {
+		s.edits.mu.Lock()
+		pending := s.edits.pending
+		s.edits.pending = make(map[uint]bool)
+		s.edits.mu.Unlock()
+		for taskExecutionID := range pending {
+			s.editTaskNotifications(taskExecutionID)
+		}
+	}
+}
+
+// editTaskNotifications edits every message sent for a task execution to reflect its current state
+func (s *taskService) editTaskNotifications(taskExecutionID uint) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting task execution %d for notification refresh: %v", taskExecutionID, err)
+		return
+	}
+
+	notifications, err := s.repo.GetTaskNotifications(taskExecutionID)
+	if err != nil {
+		log.Printf("Error getting notifications of task execution %d: %v", taskExecutionID, err)
+		return
+	}
+
+	text := s.taskNotificationText(taskExecution.Task, taskExecution)
+	groupChatID, inGroup := s.teamGroupChatID(taskExecution.Task)
+	for _, notification := range notifications {
+		var edit tgbotapi.EditMessageTextConfig
+		if keyboard, ok := taskNotificationKeyboard(taskExecution.Task, taskExecution, inGroup && notification.ChatID == groupChatID); ok {
+			edit = tgbotapi.NewEditMessageTextAndMarkup(notification.ChatID, notification.MessageID, text, keyboard)
+		} else {
+			edit = tgbotapi.NewEditMessageText(notification.ChatID, notification.MessageID, text)
+		}
+		if _, err := s.bot.Send(edit); err != nil {
+			log.Printf("Error editing notification %d of task execution %d: %v", notification.MessageID, taskExecutionID, err)
+		}
+	}
+}
+
+// RenderTaskNotification builds the announcement of a task execution for a chat as the task is now. It reports false
+// once the task is closed, or claimed by anyone but the recipient, since the announcement would only offer a stale task.
+func (s *taskService) RenderTaskNotification(taskExecutionID uint, chatID int64) (tgbotapi.MessageConfig, bool, error) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		return tgbotapi.MessageConfig{}, false, err
+	}
+	switch taskExecution.Status {
+	case models.TaskStatusPending:
+	case models.TaskStatusAssigned:
+		if taskExecution.UserID == nil || *taskExecution.UserID != chatID {
+			return tgbotapi.MessageConfig{}, false, nil
+		}
+	default:
+		return tgbotapi.MessageConfig{}, false, nil
+	}
+	msg := tgbotapi.NewMessage(chatID, s.taskNotificationText(taskExecution.Task, taskExecution))
+	groupChatID, inGroup := s.teamGroupChatID(taskExecution.Task)
+	if keyboard, ok := taskNotificationKeyboard(taskExecution.Task, taskExecution, inGroup && chatID == groupChatID); ok {
+		msg.ReplyMarkup = keyboard
+	}
+	return msg, true, nil
+}
+
+// taskNotificationText builds the text of a task notification according to the task execution state,
+// including what was recorded in the forms of earlier tasks of the same process execution
+func (s *taskService) taskNotificationText(task *models.Task, taskExecution *models.TaskExecution) string {
+	variables, err := s.processService.GetVariables(taskExecution.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting variables of process execution %d: %v", taskExecution.ProcessExecutionID, err)
+	}
+	title := RenderVariables(task.Title, variables)
+	description := RenderVariables(task.Description, variables)
+
+	var text string
+	if taskExecution.Status == models.TaskStatusPending {
+		text = fmt.Sprintf("وظیفه با اطلاعات زیر فعال شده است، اگر تمایل دارید که انجام دهید اعلام کنید.\n\nعنوان: %s\nتوضیحات: %s",
+			title, description)
+	} else {
+		text = fmt.Sprintf("اطلاعات وظیفه:\n\nعنوان: %s\nتوضیحات: %s", title, description)
+	}
+	if taskExecution.InstanceItem != "" {
+		text += fmt.Sprintf("\nمورد: %s", taskExecution.InstanceItem)
+	}
+	text += s.processExecutionSummary(task.ProcessID, taskExecution.ProcessExecutionID, variables)
+
+	answers, err := s.formService.GetProcessExecutionAnswers(taskExecution.ProcessExecutionID)
+	if err != nil {
+		log.Printf("Error getting form answers of process execution %d: %v", taskExecution.ProcessExecutionID, err)
+	} else if len(answers) > 0 {
+		text += "\n\nاطلاعات ثبت شده در مراحل قبل:"
+		for _, answer := range answers {
+			if answer.Field == nil {
+				continue
+			}
+			text += fmt.Sprintf("\n- %s: %s", answer.Field.Label, answer.DisplayValue())
+		}
+	}
+
+	switch taskExecution.Status {
+	case models.TaskStatusAssigned:
+		if task.MultiInstance == models.MultiInstanceMembers {
+			text += fmt.Sprintf("\n\n👥 این وظیفه برای هر یک از اعضای تیم جداگانه فعال شده است و این نمونه سهم %s است.", userFullName(taskExecution.User))
+		} else {
+			text += fmt.Sprintf("\n\n🔒 این وظیفه را %s به عهده گرفت.", userFullName(taskExecution.User))
+		}
+	case models.TaskStatusCompleted:
+		text += fmt.Sprintf("\n\n☑️ این وظیفه توسط %s تکمیل شد.", userFullName(taskExecution.User))
+	case models.TaskStatusCancelled:
+		switch {
+		case taskExecution.ProcessExecution == nil || taskExecution.ProcessExecution.Status == models.ProcessExecutionStatusCancelled:
+			text += "\n\n⛔️ فرایند اجرایی لغو شد و این وظیفه دیگر فعال نیست."
+		case taskExecution.ProcessExecution.Status == models.ProcessExecutionStatusFailed:
+			text += "\n\n⛔️ فرایند اجرایی ناموفق شد و این وظیفه دیگر فعال نیست."
+		default:
+			text += "\n\n⛔️ حد نصاب این مرحله تکمیل شد و این نمونه دیگر لازم نیست."
+		}
+	}
+	return text
+}
+
+// processExecutionSummary describes the process execution a task belongs to with the values given in its start form
+func (s *taskService) processExecutionSummary(processID, processExecutionID uint, variables map[string]string) string {
+	var text string
+	process, err := s.processService.GetProcessByID(processID)
+	if err != nil {
+		log.Printf("Error getting process %d: %v", processID, err)
+		return text
+	}
+	execution, err := s.processService.GetProcessExecutionByID(processExecutionID)
+	if err != nil {
+		log.Printf("Error getting process execution %d: %v", processExecutionID, err)
+		return text
+	}
+	text = fmt.Sprintf("\nفرایند: %s\nاجرا: %s", process.Name, execution.DisplayName())
+
+	fields, err := s.formService.GetStartFields(processID)
+	if err != nil {
+		log.Printf("Error getting start form of process %d: %v", processID, err)
+		return text
+	}
+	for i := range fields {
+		value, exists := variables[fields[i].VariableName()]
+		if !exists {
+			continue
+		}
+		answer := models.TaskFormAnswer{Field: &fields[i], Value: value}
+		text += fmt.Sprintf("\n- %s: %s", fields[i].Label, answer.DisplayValue())
+	}
+	return text
+}
+
+// taskNotificationKeyboard returns the buttons of a task notification according to the task execution state.
+// It reports false when the notification should have no buttons. Announcements in a team's group only offer to
+// claim the task: commenting asks for the text in the chat and the thread is posted there, which belongs in the
+// private chat.
+func taskNotificationKeyboard(task *models.Task, taskExecution *models.TaskExecution, inGroup bool) (tgbotapi.InlineKeyboardMarkup, bool) {
+	takeRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("به عهده گرفتن وظیفه", fmt.Sprintf("take_task_%d", taskExecution.ID)),
+	)
+	if inGroup {
+		if taskExecution.Status == models.TaskStatusPending {
+			return tgbotapi.NewInlineKeyboardMarkup(takeRow), true
+		}
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	commentRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("💬 نظر", fmt.Sprintf("comment_task_%d", taskExecution.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("گفتگو", fmt.Sprintf("comments_task_%d", taskExecution.ID)),
+	)
+	switch taskExecution.Status {
+	case models.TaskStatusPending:
+		return tgbotapi.NewInlineKeyboardMarkup(takeRow, commentRow), true
+	case models.TaskStatusAssigned:
+		// Instances for each team member are assigned from the start, so their notification carries the task actions
+		if task.MultiInstance == models.MultiInstanceMembers {
+			return tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("تکمیل وظیفه", fmt.Sprintf("complete_task_%d", taskExecution.ID)),
+					tgbotapi.NewInlineKeyboardButtonData("📎 افزودن پیوست", fmt.Sprintf("attach_task_%d", taskExecution.ID)),
+				),
+				commentRow,
+			), true
+		}
+	case models.TaskStatusCancelled:
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(commentRow), true
+}
+
+func userFullName(user *models.User) string {
+	if user == nil {
+		return "-"
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// ReleaseTask gives an assigned task execution back to the team pool
+func (s *taskService) ReleaseTask(taskExecutionID uint, userID int64) error {
+	_, unlock, err := s.lockTaskExecution(taskExecutionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	taskExecution, err := s.getAssignedTaskExecution(taskExecutionID, userID)
+	if err != nil {
+		return err
+	}
+	if taskExecution.Task.MultiInstance == models.MultiInstanceMembers {
+		return errors.New("این وظیفه سهم شخص شما از یک مرحله‌ی چندنفره است و به تیم بازگردانده نمی‌شود")
+	}
+
+	processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
+	if err != nil {
+		return err
+	}
+	processExecution.InProgressTaskExecutionIDs = removeTaskExecutionID(processExecution.InProgressTaskExecutionIDs, taskExecutionID)
+	processExecution.PendingTaskExecutionIDs = append(processExecution.PendingTaskExecutionIDs, taskExecutionID)
+	if err := s.processService.UpdateProcessExecution(processExecution); err != nil {
+		return err
+	}
+
+	taskExecution.Status = models.TaskStatusPending
+	taskExecution.UserID = nil
+	taskExecution.AssignedAt = nil
+	taskExecution.DelegateUserID = nil
+	if err := s.repo.UpdateTaskExecutionAssignment(taskExecution); err != nil {
+		return err
+	}
+
+	s.logAssignment(taskExecutionID, models.TaskAssignmentActionRelease, &userID, nil, userID)
+	s.refreshTaskNotifications(taskExecutionID)
+	return nil
+}
+
+// RequestDelegation offers an assigned task execution to a teammate who still has to accept it
+func (s *taskService) RequestDelegation(taskExecutionID uint, userID int64, delegateID int64) error {
+	_, unlock, err := s.lockTaskExecution(taskExecutionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	taskExecution, err := s.getAssignedTaskExecution(taskExecutionID, userID)
+	if err != nil {
+		return err
+	}
+	if delegateID == userID {
+		return errors.New("نمی‌توانید وظیفه را به خودتان واگذار کنید")
+	}
+	if err := s.checkTeamMember(taskExecution.Task, delegateID); err != nil {
+		return err
+	}
+	if err := s.checkAvailable(delegateID); err != nil {
+		return err
+	}
+
+	taskExecution.DelegateUserID = &delegateID
+	if err := s.repo.UpdateTaskExecutionAssignment(taskExecution); err != nil {
+		return err
+	}
+
+	s.logAssignment(taskExecutionID, models.TaskAssignmentActionDelegateRequest, &userID, &delegateID, userID)
+	return nil
+}
+
+// AcceptDelegation makes the invited teammate the new assignee of the task execution
+func (s *taskService) AcceptDelegation(taskExecutionID uint, userID int64) error {
+	taskExecution, unlock, err := s.lockTaskExecution(taskExecutionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if taskExecution.Status != models.TaskStatusAssigned || taskExecution.DelegateUserID == nil || *taskExecution.DelegateUserID != userID {
+		return errors.New("درخواست واگذاری معتبری برای شما وجود ندارد")
+	}
+
+	previousUserID := taskExecution.UserID
+	now := time.Now()
+	taskExecution.UserID = &userID
+	taskExecution.AssignedAt = &now
+	taskExecution.DelegateUserID = nil
+	if err := s.repo.UpdateTaskExecutionAssignment(taskExecution); err != nil {
+		return err
+	}
+
+	s.logAssignment(taskExecutionID, models.TaskAssignmentActionDelegateAccept, previousUserID, &userID, userID)
+	s.refreshTaskNotifications(taskExecutionID)
+	return nil
+}
+
+// DeclineDelegation withdraws a pending delegation, leaving the task with its current assignee
+func (s *taskService) DeclineDelegation(taskExecutionID uint, userID int64) error {
+	taskExecution, unlock, err := s.lockTaskExecution(taskExecutionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if taskExecution.DelegateUserID == nil || *taskExecution.DelegateUserID != userID {
+		return errors.New("درخواست واگذاری معتبری برای شما وجود ندارد")
+	}
+
+	taskExecution.DelegateUserID = nil
+	if err := s.repo.UpdateTaskExecutionAssignment(taskExecution); err != nil {
+		return err
+	}
+
+	s.logAssignment(taskExecutionID, models.TaskAssignmentActionDelegateDecline, taskExecution.UserID, &userID, userID)
+	return nil
+}
+
+// ReassignTask lets the owner of the responsible team, or an administrator of its group, hand an open task execution to any member
+func (s *taskService) ReassignTask(taskExecutionID uint, ownerID int64, userID int64) error {
+	taskExecution, unlock, err := s.lockTaskExecution(taskExecutionID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if taskExecution.Status != models.TaskStatusPending && taskExecution.Status != models.TaskStatusAssigned {
+		return errors.New("این وظیفه دیگر باز نیست")
+	}
+	if taskExecution.Task.TeamID == nil {
+		return errors.New("task has no team assigned")
+	}
+
+	team, err := s.teamService.GetTeamByID(*taskExecution.Task.TeamID)
+	if err != nil {
+		return err
+	}
+	if !s.teamService.IsManager(team, ownerID) {
+		return errors.New("فقط مالک تیم یا مدیران گروه تیم می‌توانند وظیفه را دوباره تخصیص دهند")
+	}
+	if err := s.checkTeamMember(taskExecution.Task, userID); err != nil {
+		return err
+	}
+	if err := s.checkAvailable(userID); err != nil {
+		return err
+	}
+
+	if taskExecution.Status == models.TaskStatusPending {
+		processExecution, err := s.processService.GetProcessExecutionByID(taskExecution.ProcessExecutionID)
+		if err != nil {
+			return err
+		}
+		processExecution.PendingTaskExecutionIDs = removeTaskExecutionID(processExecution.PendingTaskExecutionIDs, taskExecutionID)
+		processExecution.InProgressTaskExecutionIDs = append(processExecution.InProgressTaskExecutionIDs, taskExecutionID)
+		if err := s.processService.UpdateProcessExecution(processExecution); err != nil {
+			return err
+		}
+	}
+
+	previousUserID := taskExecution.UserID
+	now := time.Now()
+	taskExecution.Status = models.TaskStatusAssigned
+	taskExecution.UserID = &userID
+	taskExecution.AssignedAt = &now
+	taskExecution.DelegateUserID = nil
+	if err := s.repo.UpdateTaskExecutionAssignment(taskExecution); err != nil {
+		return err
+	}
+
+	s.logAssignment(taskExecutionID, models.TaskAssignmentActionReassign, previousUserID, &userID, ownerID)
+	s.refreshTaskNotifications(taskExecutionID)
+	return nil
+}
+
+func (s *taskService) GetAssignmentLogs(taskExecutionID uint) ([]models.TaskAssignmentLog, error) {
+	return s.repo.GetAssignmentLogs(taskExecutionID)
+}
+
+func (s *taskService) GetOpenTeamTasks(teamID uint) ([]models.TaskExecution, error) {
+	return s.repo.GetOpenTaskExecutionsByTeamID(teamID)
+}
+
+// ListUserTasks returns a page of the user's task executions and the total count for the filter
+func (s *taskService) ListUserTasks(userID int64, filter UserTaskFilter, page, pageSize int) ([]models.TaskExecution, int64, error) {
+	offset := page * pageSize
+	switch filter {
+	case UserTaskFilterAssigned:
+		return s.repo.GetAssignedTaskExecutions(userID, offset, pageSize)
+	case UserTaskFilterAvailable:
+		return s.repo.GetClaimableTaskExecutions(userID, offset, pageSize)
+	case UserTaskFilterCompleted:
+		return s.repo.GetCompletedTaskExecutions(userID, offset, pageSize)
+	}
+	return nil, 0, fmt.Errorf("unknown task filter: %s", filter)
+}
+
+func (s *taskService) GetTaskExecutionsByProcessExecutionID(processExecutionID uint) ([]models.TaskExecution, error) {
+	return s.repo.GetTaskExecutionsByProcessExecutionID(processExecutionID)
+}
+
+func (s *taskService) getAssignedTaskExecution(taskExecutionID uint, userID int64) (*models.TaskExecution, error) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		return nil, err
+	}
+	if taskExecution.Status != models.TaskStatusAssigned || taskExecution.UserID == nil || *taskExecution.UserID != userID {
+		return nil, errors.New("task is not assigned to you")
+	}
+	return taskExecution, nil
+}
+
+func (s *taskService) checkTeamMember(task *models.Task, userID int64) error {
+	if task.TeamID == nil {
+		return errors.New("task has no team assigned")
+	}
+	members, err := s.teamService.GetTeamMembers(*task.TeamID)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.ID == userID {
+			return nil
+		}
+	}
+	return errors.New("کاربر انتخاب شده عضو تیم این وظیفه نیست")
+}
+
+func (s *taskService) checkAvailable(userID int64) error {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if s.userService.IsAway(user) {
+		return errors.New("کاربر انتخاب شده در مرخصی است")
+	}
+	return nil
+}
+
+// resolveRecipients maps team members to the users that should be notified, skipping away members
+// and replacing them with their delegates
+func (s *taskService) resolveRecipients(members []models.User) []int64 {
+	seen := make(map[int64]bool)
+	var recipients []int64
+	for _, member := range members {
+		recipient, ok := s.userService.ResolveRecipient(member.ID)
+		if !ok || seen[recipient] {
+			continue
+		}
+		seen[recipient] = true
+		recipients = append(recipients, recipient)
+	}
+	return recipients
+}
+
+func (s *taskService) logAssignment(taskExecutionID uint, action models.TaskAssignmentAction, fromUserID, toUserID *int64, byUserID int64) {
+	entry := models.TaskAssignmentLog{
+		TaskExecutionID: taskExecutionID,
+		Action:          action,
+		FromUserID:      fromUserID,
+		ToUserID:        toUserID,
+		ByUserID:        byUserID,
+	}
+	if err := s.repo.SaveAssignmentLog(&entry); err != nil {
+		log.Printf("Error logging %s of task execution %d: %v", action, taskExecutionID, err)
+	}
+}
+
+// stepCompleted reports whether a task has a completed execution and no open one left in a process execution
+func stepCompleted(taskExecutions []models.TaskExecution, taskID uint) bool {
+	completed := false
+	for _, taskExecution := range taskExecutions {
+		if taskExecution.TaskID != taskID {
+			continue
+		}
+		if taskExecution.IsOpen() {
+			return false
+		}
+		if taskExecution.Status == models.TaskStatusCompleted {
+			completed = true
+		}
+	}
+	return completed
+}
+
+func removeTaskExecutionID(ids []uint, taskExecutionID uint) []uint {
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id != taskExecutionID {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// lockTaskExecution locks the process execution tree of a task execution and loads the task execution under the lock.
+// The returned function releases the lock.
+func (s *taskService) lockTaskExecution(taskExecutionID uint) (*models.TaskExecution, func(), error) {
+	taskExecution, err := s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock := s.lockExecution(taskExecution.ProcessExecutionID)
+	taskExecution, err = s.repo.GetTaskExecutionByID(taskExecutionID)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return taskExecution, unlock, nil
+}
+
+// lockExecution locks the tree of process executions a process execution belongs to, and returns the function
+// that releases it. A sub-process is locked along with its parents, as finishing it moves them forward too.
+func (s *taskService) lockExecution(processExecutionID uint) func() {
+	return s.locks.lock(s.rootExecutionID(processExecutionID))
+}
+
+// rootExecutionID returns the top process execution above a sub-process execution
+func (s *taskService) rootExecutionID(processExecutionID uint) uint {
+	for {
+		execution, err := s.processService.GetProcessExecutionByID(processExecutionID)
+		if err != nil || execution.ParentTaskExecutionID == nil {
+			return processExecutionID
+		}
+		parentTask, err := s.repo.GetTaskExecutionByID(*execution.ParentTaskExecutionID)
+		if err != nil {
+			return processExecutionID
+		}
+		processExecutionID = parentTask.ProcessExecutionID
+	}
+}
+
+func (l *executionLocks) lock(id uint) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &executionLock{}
+		l.locks[id] = lock
+	}
+	lock.holders++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
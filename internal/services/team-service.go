@@ -1,38 +1,52 @@
 package service
 
 import (
-	"bbb/internal/dto"
 	"bbb/internal/models"
 	"bbb/internal/repository"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type TeamService interface {
 	CreateTeam(team *models.Team) error
 	GetTeamByID(id uint) (*models.Team, error)
 	GetTeamMembers(teamID uint) ([]models.User, error)
+	IsMember(teamID uint, userID int64) (bool, error)
 	AddMember(teamID uint, userID int64) error
 	RemoveMember(teamID uint, userID int64) error
 	GenerateJoinKey() string
 	JoinTeam(userID int64, joinKey string) error
 	GetAllTeams() ([]models.Team, error)
 	GetTeamsByOwnerID(ownerID int64) ([]*models.Team, error)
+	CreateGroupTeam(userID int64, chatID int64, title string) (*models.Team, error)
+	LinkGroup(teamID uint, userID int64, chatID int64, title string) (*models.Team, error)
+	UnlinkGroup(team *models.Team) error
+	MoveGroup(team *models.Team, chatID int64) error
+	GetTeamByGroupChatID(chatID int64) (*models.Team, error)
+	AddGroupMember(team *models.Team, userID int64) (bool, error)
+	RemoveGroupMember(team *models.Team, userID int64) error
+	IsGroupAdmin(chatID int64, userID int64) bool
+	IsManager(team *models.Team, userID int64) bool
+	GetGroupAdmins(chatID int64) ([]tgbotapi.User, error)
 }
 
 type teamService struct {
 	repo     repository.TeamRepository
 	userRepo repository.UserRepository
+	bot      *tgbotapi.BotAPI
 }
 
-func NewTeamService(repo repository.TeamRepository, userRepo repository.UserRepository) TeamService {
-	return &teamService{repo: repo, userRepo: userRepo}
+func NewTeamService(repo repository.TeamRepository, userRepo repository.UserRepository, bot *tgbotapi.BotAPI) TeamService {
+	return &teamService{repo: repo, userRepo: userRepo, bot: bot}
 }
 
 func (s *teamService) CreateTeam(team *models.Team) error {
 	team.JoinKey = s.GenerateJoinKey()
-	return s.repo.Save(*team)
+	return s.repo.Save(team)
 }
 
 func (s *teamService) GetTeamByID(id uint) (*models.Team, error) {
@@ -46,6 +60,19 @@ func (s *teamService) GetTeamMembers(teamID uint) ([]models.User, error) {
 	return s.repo.GetMembers(teamID)
 }
 
+func (s *teamService) IsMember(teamID uint, userID int64) (bool, error) {
+	members, err := s.repo.GetMembers(teamID)
+	if err != nil {
+		return false, err
+	}
+	for _, member := range members {
+		if member.ID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (s *teamService) AddMember(teamID uint, userID int64) error {
 	return s.repo.AddMember(teamID, userID)
 }
@@ -76,40 +103,118 @@ func (s *teamService) JoinTeam(userID int64, joinKey string) error {
 	return s.repo.AddMember(team.ID, userID)
 }
 
-func (g *teamService) AddToNewTeam(message dto.Message) {
-	// Save Team repository
-	team := models.Team{
-		ID:      uint(message.Chat.ID),
-		Name:    message.Chat.Title,
-		JoinKey: g.GenerateJoinKey(),
+// CreateGroupTeam creates a team named after a group chat and linked to it, owned by the user who asked for it
+func (s *teamService) CreateGroupTeam(userID int64, chatID int64, title string) (*models.Team, error) {
+	if _, err := s.repo.GetByGroupChatID(chatID); err == nil {
+		return nil, errors.New("این گروه قبلا به یک تیم متصل شده است")
+	}
+	team := &models.Team{
+		Name:        title,
+		OwnerID:     userID,
+		GroupChatID: &chatID,
+		GroupTitle:  title,
+	}
+	if err := s.CreateTeam(team); err != nil {
+		return nil, err
 	}
-	err := g.repo.Save(team)
+	if _, err := s.repo.EnsureMember(team.ID, userID); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// LinkGroup links a team of the user to a group chat, so the group members join the team and its task
+// notifications are posted in the group. A team linked to another group is moved to this one.
+func (s *teamService) LinkGroup(teamID uint, userID int64, chatID int64, title string) (*models.Team, error) {
+	team, err := s.GetTeamByID(teamID)
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return nil, err
+	}
+	if team.OwnerID != userID {
+		return nil, errors.New("فقط مالک تیم می‌تواند آن را به گروه متصل کند")
+	}
+	if linked, err := s.repo.GetByGroupChatID(chatID); err == nil {
+		if linked.ID == team.ID {
+			return team, nil
+		}
+		return nil, fmt.Errorf("این گروه قبلا به تیم «%s» متصل شده است", linked.Name)
+	}
+	team.GroupChatID = &chatID
+	team.GroupTitle = title
+	if err := s.repo.Save(team); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.EnsureMember(team.ID, userID); err != nil {
+		return nil, err
 	}
+	return team, nil
+}
+
+// UnlinkGroup detaches a team from its group chat; its members stay in the team
+func (s *teamService) UnlinkGroup(team *models.Team) error {
+	team.GroupChatID = nil
+	team.GroupTitle = ""
+	return s.repo.Save(team)
+}
+
+// MoveGroup points a team at the new chat of its group, after the group became a supergroup
+func (s *teamService) MoveGroup(team *models.Team, chatID int64) error {
+	team.GroupChatID = &chatID
+	return s.repo.Save(team)
+}
+
+func (s *teamService) GetTeamByGroupChatID(chatID int64) (*models.Team, error) {
+	return s.repo.GetByGroupChatID(chatID)
+}
 
-	// Save User owner repository
-	user := models.User{
-		ID:        message.From.ID,
-		FirstName: message.From.First_name,
-		LastName:  message.From.Last_name,
-		Username:  message.From.Username,
+// AddGroupMember makes a member of the team's group a team member and reports whether they were not one already
+func (s *teamService) AddGroupMember(team *models.Team, userID int64) (bool, error) {
+	return s.repo.EnsureMember(team.ID, userID)
+}
+
+// RemoveGroupMember removes a user who left the team's group from the team. The owner always stays.
+func (s *teamService) RemoveGroupMember(team *models.Team, userID int64) error {
+	if team.OwnerID == userID {
+		return nil
 	}
+	return s.repo.RemoveMember(team.ID, userID)
+}
 
-	err = g.userRepo.Save(&user)
+// IsGroupAdmin reports whether the user is an administrator of the group chat
+func (s *teamService) IsGroupAdmin(chatID int64, userID int64) bool {
+	member, err := s.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
 	if err != nil {
-		fmt.Println("Error: ", err)
+		log.Printf("Error getting member %d of chat %d: %v", userID, chatID, err)
+		return false
 	}
+	return member.IsCreator() || member.IsAdministrator()
+}
 
-	// Save UserTeams
-	userTeam := models.UserTeams{
-		UserID: user.ID,
-		TeamID: team.ID,
+// IsManager reports whether the user may manage the team: its owner, or an administrator of its group
+func (s *teamService) IsManager(team *models.Team, userID int64) bool {
+	if team.OwnerID == userID {
+		return true
 	}
-	err = g.repo.SaveUserTeam(userTeam)
+	return team.GroupChatID != nil && s.IsGroupAdmin(*team.GroupChatID, userID)
+}
+
+// GetGroupAdmins returns the administrators of the group chat, other than bots
+func (s *teamService) GetGroupAdmins(chatID int64) ([]tgbotapi.User, error) {
+	admins, err := s.bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID},
+	})
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return nil, err
+	}
+	users := make([]tgbotapi.User, 0, len(admins))
+	for _, admin := range admins {
+		if admin.User != nil && !admin.User.IsBot {
+			users = append(users, *admin.User)
+		}
 	}
+	return users, nil
 }
 
 func (s *teamService) GetAllTeams() ([]models.Team, error) {
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BusinessHours describes the working days and the daily working period timer tasks can wait for
+type BusinessHours struct {
+	Days     map[time.Weekday]bool
+	Start    time.Duration // Offset of the start of the working period from midnight
+	End      time.Duration // Offset of the end of the working period from midnight
+	Location *time.Location
+}
+
+// NewBusinessHours parses working days such as "6,0,1,2,3" (0 is Sunday) and working hours such as "08:00-16:00".
+// Empty or invalid values fall back to Saturday to Wednesday, 08:00 to 16:00.
+func NewBusinessHours(days string, hours string, location *time.Location) *BusinessHours {
+	if location == nil {
+		location = time.Local
+	}
+	businessHours := &BusinessHours{
+		Days:     make(map[time.Weekday]bool),
+		Start:    8 * time.Hour,
+		End:      16 * time.Hour,
+		Location: location,
+	}
+
+	for _, day := range strings.Split(days, ",") {
+		if weekday, err := strconv.Atoi(strings.TrimSpace(day)); err == nil && weekday >= 0 && weekday <= 6 {
+			businessHours.Days[time.Weekday(weekday)] = true
+		}
+	}
+	if len(businessHours.Days) == 0 {
+		for _, weekday := range []time.Weekday{time.Saturday, time.Sunday, time.Monday, time.Tuesday, time.Wednesday} {
+			businessHours.Days[weekday] = true
+		}
+	}
+
+	if from, to, found := strings.Cut(hours, "-"); found {
+		start, errStart := parseClock(from)
+		end, errEnd := parseClock(to)
+		if errStart == nil && errEnd == nil && start < end {
+			businessHours.Start = start
+			businessHours.End = end
+		}
+	}
+	return businessHours
+}
+
+// Next returns the earliest moment at or after t that falls within business hours
+func (b *BusinessHours) Next(t time.Time) time.Time {
+	t = t.In(b.Location)
+	for i := 0; i < 8; i++ {
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, b.Location)
+		if b.Days[t.Weekday()] {
+			start := midnight.Add(b.Start)
+			end := midnight.Add(b.End)
+			if t.Before(start) {
+				return start
+			}
+			if t.Before(end) {
+				return t
+			}
+		}
+		t = midnight.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// TimerWakeAt returns when a timer task started at the given time should be completed
+func TimerWakeAt(task *models.Task, start time.Time, variables map[string]string, businessHours *BusinessHours) (time.Time, error) {
+	wait := time.Duration(task.TimerMinutes) * time.Minute
+	switch task.TimerType {
+	case models.TimerTypeDuration:
+		return start.Add(wait), nil
+	case models.TimerTypeBusinessHours:
+		return businessHours.Next(start.Add(wait)), nil
+	case models.TimerTypeUntilVariable:
+		value, exists := variables[task.TimerVariable]
+		if !exists || strings.TrimSpace(value) == "" {
+			return time.Time{}, fmt.Errorf("متغیر «%s» برای زمان‌سنج مقدار ندارد", task.TimerVariable)
+		}
+		return ParseVariableTime(value, businessHours.Location)
+	}
+	return time.Time{}, fmt.Errorf("unknown timer type: %s", task.TimerType)
+}
+
+// ParseVariableTime reads a date such as 2025-01-31 or a date and time such as 2025-01-31 14:30 from a variable
+func ParseVariableTime(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(NormalizeDigits(value))
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("مقدار «%s» تاریخ یا زمان معتبری نیست", value)
+}
+
+// ParseWaitDuration reads a waiting time such as "3 روز", "4h" or "90 دقیقه". A bare number is read as hours.
+func ParseWaitDuration(input string) (time.Duration, error) {
+	input = strings.TrimSpace(NormalizeDigits(input))
+	number := strings.TrimRightFunc(input, func(r rune) bool { return r < '0' || r > '9' })
+	unit := strings.TrimSpace(strings.TrimPrefix(input, number))
+	amount, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || amount < 0 {
+		return 0, errors.New("لطفا مدت را به شکل «3 روز»، «4 ساعت» یا «30 دقیقه» وارد کنید")
+	}
+
+	switch strings.ToLower(unit) {
+	case "", "h", "ساعت":
+		return time.Duration(amount) * time.Hour, nil
+	case "d", "روز":
+		return time.Duration(amount) * 24 * time.Hour, nil
+	case "m", "دقیقه":
+		return time.Duration(amount) * time.Minute, nil
+	}
+	return 0, errors.New("واحد مدت باید دقیقه، ساعت یا روز باشد")
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
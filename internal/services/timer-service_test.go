@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"testing"
+	"time"
+)
+
+// tehran is a fixed zone so the tests do not depend on the time zone of the machine
+var tehran = time.FixedZone("IRST", 3*3600+1800)
+
+func at(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, tehran)
+}
+
+func TestBusinessHoursNext(t *testing.T) {
+	// Saturday to Wednesday, 08:00 to 16:00. 2025-01-29 is a Wednesday.
+	defaults := NewBusinessHours("", "", tehran)
+	custom := NewBusinessHours("1,2", "09:00-17:30", tehran)
+	tests := []struct {
+		name  string
+		hours *BusinessHours
+		t     time.Time
+		want  time.Time
+	}{
+		{name: "within hours", hours: defaults, t: at(2025, 1, 29, 10, 0), want: at(2025, 1, 29, 10, 0)},
+		{name: "at the start", hours: defaults, t: at(2025, 1, 29, 8, 0), want: at(2025, 1, 29, 8, 0)},
+		{name: "before the start", hours: defaults, t: at(2025, 2, 1, 7, 0), want: at(2025, 2, 1, 8, 0)},
+		{name: "at midnight", hours: defaults, t: at(2025, 1, 27, 0, 0), want: at(2025, 1, 27, 8, 0)},
+		{name: "after hours crosses midnight", hours: defaults, t: at(2025, 1, 27, 23, 30), want: at(2025, 1, 28, 8, 0)},
+		{name: "at the end skips the weekend", hours: defaults, t: at(2025, 1, 29, 16, 0), want: at(2025, 2, 1, 8, 0)},
+		{name: "thursday", hours: defaults, t: at(2025, 1, 30, 9, 0), want: at(2025, 2, 1, 8, 0)},
+		{name: "friday night", hours: defaults, t: at(2025, 1, 31, 23, 59), want: at(2025, 2, 1, 8, 0)},
+		{name: "other location", hours: defaults, t: time.Date(2025, 1, 29, 4, 0, 0, 0, time.UTC), want: at(2025, 1, 29, 8, 0)},
+		{name: "custom hours", hours: custom, t: at(2025, 1, 27, 17, 0), want: at(2025, 1, 27, 17, 0)},
+		{name: "custom days wrap the week", hours: custom, t: at(2025, 1, 28, 17, 30), want: at(2025, 2, 3, 9, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.hours.Next(test.t); !got.Equal(test.want) {
+				t.Errorf("Next(%s) = %s, want %s", test.t, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewBusinessHoursFallsBack(t *testing.T) {
+	tests := []struct{ days, hours string }{
+		{days: "", hours: ""},
+		{days: "7,x", hours: "16:00-08:00"},
+		{days: "-1", hours: "8-16"},
+	}
+	for _, test := range tests {
+		hours := NewBusinessHours(test.days, test.hours, tehran)
+		if len(hours.Days) != 5 || !hours.Days[time.Saturday] || hours.Days[time.Friday] {
+			t.Errorf("NewBusinessHours(%q, %q) days = %v, want Saturday to Wednesday", test.days, test.hours, hours.Days)
+		}
+		if hours.Start != 8*time.Hour || hours.End != 16*time.Hour {
+			t.Errorf("NewBusinessHours(%q, %q) hours = %s-%s, want 8h-16h", test.days, test.hours, hours.Start, hours.End)
+		}
+	}
+}
+
+func TestTimerWakeAt(t *testing.T) {
+	businessHours := NewBusinessHours("", "", tehran)
+	start := at(2025, 1, 29, 15, 0)
+	tests := []struct {
+		name      string
+		task      models.Task
+		variables map[string]string
+		want      time.Time
+		wantErr   bool
+	}{
+		{
+			name: "duration ignores business hours",
+			task: models.Task{TimerType: models.TimerTypeDuration, TimerMinutes: 90},
+			want: at(2025, 1, 29, 16, 30),
+		},
+		{
+			name: "duration across midnight",
+			task: models.Task{TimerType: models.TimerTypeDuration, TimerMinutes: 10 * 60},
+			want: at(2025, 1, 30, 1, 0),
+		},
+		{
+			name: "business hours within the day",
+			task: models.Task{TimerType: models.TimerTypeBusinessHours, TimerMinutes: 30},
+			want: at(2025, 1, 29, 15, 30),
+		},
+		{
+			name: "business hours after closing waits for the weekend to end",
+			task: models.Task{TimerType: models.TimerTypeBusinessHours, TimerMinutes: 90},
+			want: at(2025, 2, 1, 8, 0),
+		},
+		{
+			name: "business hours landing on a weekend",
+			task: models.Task{TimerType: models.TimerTypeBusinessHours, TimerMinutes: 2 * 24 * 60},
+			want: at(2025, 2, 1, 8, 0),
+		},
+		{
+			name:      "until a date and time",
+			task:      models.Task{TimerType: models.TimerTypeUntilVariable, TimerVariable: "due"},
+			variables: map[string]string{"due": "2025-02-05 14:30"},
+			want:      at(2025, 2, 5, 14, 30),
+		},
+		{
+			name:      "until a date in persian digits",
+			task:      models.Task{TimerType: models.TimerTypeUntilVariable, TimerVariable: "due"},
+			variables: map[string]string{"due": "۲۰۲۵-۰۲-۰۵"},
+			want:      at(2025, 2, 5, 0, 0),
+		},
+		{
+			// The scheduler completes timers whose wake time has passed on its next run
+			name:      "until a time in the past wakes at once",
+			task:      models.Task{TimerType: models.TimerTypeUntilVariable, TimerVariable: "due"},
+			variables: map[string]string{"due": "2024-12-01T09:00"},
+			want:      at(2024, 12, 1, 9, 0),
+		},
+		{
+			name:    "until a missing variable",
+			task:    models.Task{TimerType: models.TimerTypeUntilVariable, TimerVariable: "due"},
+			wantErr: true,
+		},
+		{
+			name:      "until an empty variable",
+			task:      models.Task{TimerType: models.TimerTypeUntilVariable, TimerVariable: "due"},
+			variables: map[string]string{"due": "  "},
+			wantErr:   true,
+		},
+		{
+			name:      "until a value that is not a date",
+			task:      models.Task{TimerType: models.TimerTypeUntilVariable, TimerVariable: "due"},
+			variables: map[string]string{"due": "next week"},
+			wantErr:   true,
+		},
+		{
+			name:    "unknown timer type",
+			task:    models.Task{TimerType: "cron", TimerMinutes: 5},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := TimerWakeAt(&test.task, start, test.variables, businessHours)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("TimerWakeAt() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err == nil && !got.Equal(test.want) {
+				t.Errorf("TimerWakeAt() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseVariableTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2025-01-31", want: at(2025, 1, 31, 0, 0)},
+		{value: " 2025-01-31 14:30 ", want: at(2025, 1, 31, 14, 30)},
+		{value: "2025-01-31T23:59", want: at(2025, 1, 31, 23, 59)},
+		{value: "۲۰۲۵-۰۱-۳۱ ۰۸:۰۵", want: at(2025, 1, 31, 8, 5)},
+		{value: "2025-02-30", wantErr: true},
+		{value: "31/01/2025", wantErr: true},
+		{value: "2025-01-31 25:00", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseVariableTime(test.value, tehran)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseVariableTime(%q) error = %v, wantErr %v", test.value, err, test.wantErr)
+			continue
+		}
+		if err == nil && !got.Equal(test.want) {
+			t.Errorf("ParseVariableTime(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseWaitDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "3 روز", want: 72 * time.Hour},
+		{input: "2d", want: 48 * time.Hour},
+		{input: "4 ساعت", want: 4 * time.Hour},
+		{input: "4H", want: 4 * time.Hour},
+		{input: "5", want: 5 * time.Hour},
+		{input: "90 دقیقه", want: 90 * time.Minute},
+		{input: "۳۰m", want: 30 * time.Minute},
+		{input: " 0 ", want: 0},
+		{input: "", wantErr: true},
+		{input: "روز", wantErr: true},
+		{input: "-2 ساعت", wantErr: true},
+		{input: "1h30", wantErr: true},
+		{input: "2 هفته", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseWaitDuration(test.input)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseWaitDuration(%q) error = %v, wantErr %v", test.input, err, test.wantErr)
+			continue
+		}
+		if err == nil && got != test.want {
+			t.Errorf("ParseWaitDuration(%q) = %s, want %s", test.input, got, test.want)
+		}
+	}
+}
@@ -4,12 +4,20 @@ import (
 	"bbb/internal/dto"
 	"bbb/internal/models"
 	"bbb/internal/repository"
+	"errors"
+	"time"
 )
 
 type (
 	UserService interface {
 		SaveOrUpdateUser(message dto.Message) error
 		GetUserByID(userID int64) (*models.User, error)
+		GetTeammates(userID int64) ([]models.User, error)
+		SetAway(userID int64, until time.Time, delegateID *int64) error
+		SetAvailable(userID int64) error
+		IsAway(user *models.User) bool
+		ResolveRecipient(userID int64) (int64, bool)
+		ClearExpiredAvailability() ([]models.User, error)
 	}
 
 	userService struct {
@@ -53,3 +61,74 @@ func (s *userService) SaveOrUpdateUser(message dto.Message) error {
 func (s *userService) GetUserByID(userID int64) (*models.User, error) {
 	return s.userRepo.GetByID(userID)
 }
+
+func (s *userService) GetTeammates(userID int64) ([]models.User, error) {
+	return s.userRepo.GetTeammates(userID)
+}
+
+// SetAway marks the user as out of office until the given time, optionally naming a delegate
+func (s *userService) SetAway(userID int64, until time.Time, delegateID *int64) error {
+	if !until.After(time.Now()) {
+		return errors.New("تاریخ پایان مرخصی باید در آینده باشد")
+	}
+	if delegateID != nil && *delegateID == userID {
+		return errors.New("نمی‌توانید خودتان را جانشین خود کنید")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	user.AwayUntil = &until
+	user.AwayDelegateID = delegateID
+	return s.userRepo.Update(user)
+}
+
+func (s *userService) SetAvailable(userID int64) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	user.AwayUntil = nil
+	user.AwayDelegateID = nil
+	return s.userRepo.Update(user)
+}
+
+func (s *userService) IsAway(user *models.User) bool {
+	return user.AwayUntil != nil && user.AwayUntil.After(time.Now())
+}
+
+// ResolveRecipient returns who should receive work meant for the user.
+// Away users are replaced by their delegate when the delegate is available, otherwise they are skipped.
+func (s *userService) ResolveRecipient(userID int64) (int64, bool) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || !s.IsAway(user) {
+		return userID, true
+	}
+	if user.AwayDelegateID == nil {
+		return 0, false
+	}
+
+	delegate, err := s.userRepo.GetByID(*user.AwayDelegateID)
+	if err != nil || s.IsAway(delegate) {
+		return 0, false
+	}
+	return delegate.ID, true
+}
+
+// ClearExpiredAvailability resets users whose away period has ended and returns them
+func (s *userService) ClearExpiredAvailability() ([]models.User, error) {
+	users, err := s.userRepo.GetExpiredAway(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range users {
+		users[i].AwayUntil = nil
+		users[i].AwayDelegateID = nil
+		if err := s.userRepo.Update(&users[i]); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
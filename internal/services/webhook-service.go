@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WebhookSignatureHeader carries the HMAC-SHA256 signature of the request body when a secret is configured
+const WebhookSignatureHeader = "X-Signature-256"
+
+// maxWebhookResponseSize limits how much of a webhook response is read
+const maxWebhookResponseSize = 1 << 20
+
+type (
+	WebhookService interface {
+		Call(endpoint string, secret string, payload interface{}) (map[string]string, error)
+		Deliver(endpoint string, secret string, body []byte) (int, error)
+	}
+
+	webhookService struct {
+		client  *http.Client
+		retries int
+		backoff time.Duration
+	}
+
+	// ServiceTaskPayload is the body posted to the webhook of a service task
+	ServiceTaskPayload struct {
+		Event              string            `json:"event"`
+		ProcessID          uint              `json:"process_id"`
+		ProcessName        string            `json:"process_name"`
+		ProcessExecutionID uint              `json:"process_execution_id"`
+		ExecutionTitle     string            `json:"execution_title"`
+		TaskID             uint              `json:"task_id"`
+		TaskTitle          string            `json:"task_title"`
+		TaskExecutionID    uint              `json:"task_execution_id"`
+		Variables          map[string]string `json:"variables"`
+	}
+
+	// webhookStatusError is returned for responses with a non-success status code
+	webhookStatusError struct {
+		statusCode int
+		body       string
+	}
+
+	// webhookAddressError is returned when a webhook host resolves to an internal address
+	webhookAddressError struct {
+		host string
+	}
+)
+
+// NewWebhookService creates a webhook caller. The client sets the timeout of each attempt; failed attempts are
+// repeated up to retries more times, waiting backoff and then twice as long before each repetition.
+func NewWebhookService(client *http.Client, retries int, backoff time.Duration) WebhookService {
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &webhookService{
+		client:  client,
+		retries: retries,
+		backoff: backoff,
+	}
+}
+
+// NewWebhookClient creates the HTTP client webhooks are called with. Its connections refuse loopback, private,
+// link-local and other internal addresses, checked once the host name is resolved, so process and team owners cannot
+// make the bot post to services on its own network. Proxies from the environment are not used, since the check would
+// then apply to the proxy instead of the webhook.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return &webhookAddressError{host: host}
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// ValidateWebhookURL checks that a webhook URL is an http or https URL of a host outside the bot's network. Host names
+// are checked again when the client connects, after they are resolved.
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return errors.New("آدرس وب‌هوک باید با http:// یا https:// شروع شود")
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("آدرس وب‌هوک نمی‌تواند به خود سرور اشاره کند")
+	}
+	if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+		return errors.New("آدرس وب‌هوک نمی‌تواند یک نشانی داخلی یا خصوصی باشد")
+	}
+	return nil
+}
+
+// internalIP reports whether an address belongs to the bot's own host or network rather than the internet
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// Call posts the payload as JSON to the URL and returns the top-level fields of the JSON response as strings.
+// Network errors and 5xx responses are retried; other failures, and responses with "success": false, are not.
+func (s *webhookService) Call(endpoint string, secret string, payload interface{}) (map[string]string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := s.backoff
+	for attempt := 0; ; attempt++ {
+		fields, err := s.post(endpoint, secret, body)
+		if err == nil || attempt >= s.retries || !retryableWebhookError(err) {
+			return fields, err
+		}
+		log.Printf("Webhook %s failed on attempt %d, retrying: %v", endpoint, attempt+1, err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (s *webhookService) post(endpoint string, secret string, body []byte) (map[string]string, error) {
+	_, respBody, err := s.send(endpoint, secret, body)
+	if err != nil {
+		return nil, err
+	}
+	return parseWebhookResponse(respBody)
+}
+
+// Deliver posts an already encoded JSON body once and returns the status code of the response, if one arrived.
+// Retrying failed deliveries is left to the caller.
+func (s *webhookService) Deliver(endpoint string, secret string, body []byte) (int, error) {
+	statusCode, _, err := s.send(endpoint, secret, body)
+	return statusCode, err
+}
+
+// send posts a JSON body, signed when a secret is given, and returns the status code and body of a successful response
+func (s *webhookService) send(endpoint string, secret string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookBody(secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseSize+1))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if len(respBody) > maxWebhookResponseSize {
+		return resp.StatusCode, nil, fmt.Errorf("webhook response is larger than %d bytes", maxWebhookResponseSize)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, nil, &webhookStatusError{statusCode: resp.StatusCode, body: strings.TrimSpace(string(respBody))}
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// SignWebhookBody returns the signature header value of a body, in the form "sha256=<hex digest>"
+func SignWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseWebhookResponse flattens a JSON object response into string fields. Empty and non-JSON bodies have no fields.
+func parseWebhookResponse(body []byte) (map[string]string, error) {
+	fields := make(map[string]string)
+	var response map[string]interface{}
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &response) != nil {
+		return fields, nil
+	}
+
+	if success, ok := response["success"].(bool); ok && !success {
+		message, _ := response["error"].(string)
+		if message == "" {
+			message, _ = response["message"].(string)
+		}
+		if message == "" {
+			message = "وب‌هوک عدم موفقیت را اعلام کرد"
+		}
+		return nil, errors.New(message)
+	}
+
+	for name, value := range response {
+		switch v := value.(type) {
+		case string:
+			fields[name] = v
+		case nil:
+			fields[name] = ""
+		case float64:
+			fields[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			fields[name] = strconv.FormatBool(v)
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = string(encoded)
+		}
+	}
+	return fields, nil
+}
+
+// retryableWebhookError reports whether a failed call may succeed when repeated: network errors, timeouts and 5xx responses
+func retryableWebhookError(err error) bool {
+	var statusErr *webhookStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= 500
+	}
+	var addressErr *webhookAddressError
+	if errors.As(err, &addressErr) {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func (e *webhookStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("webhook responded with status %d", e.statusCode)
+	}
+	return fmt.Sprintf("webhook responded with status %d: %s", e.statusCode, e.body)
+}
+
+func (e *webhookAddressError) Error() string {
+	return fmt.Sprintf("webhook address %s is internal and not allowed", e.host)
+}
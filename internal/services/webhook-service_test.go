@@ -0,0 +1,239 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestWebhookService calls local test servers, which the client of NewWebhookClient refuses to reach
+func newTestWebhookService(timeout time.Duration, retries int) WebhookService {
+	return NewWebhookService(&http.Client{Timeout: timeout}, retries, 10*time.Millisecond)
+}
+
+func TestWebhookCallSignsBody(t *testing.T) {
+	const secret = "s3cret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		if got, want := r.Header.Get(WebhookSignatureHeader), SignWebhookBody(secret, body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if !strings.HasPrefix(r.Header.Get(WebhookSignatureHeader), "sha256=") {
+			t.Errorf("signature %q lacks the sha256= prefix", r.Header.Get(WebhookSignatureHeader))
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		var payload ServiceTaskPayload
+		if err := json.Unmarshal(body, &payload); err != nil || payload.TaskExecutionID != 7 {
+			t.Errorf("payload = %+v, %v", payload, err)
+		}
+		w.Write([]byte(`{"success": true, "order_id": "A-1", "amount": 12.5, "approved": true, "items": [1, 2]}`))
+	}))
+	defer server.Close()
+
+	fields, err := newTestWebhookService(time.Second, 0).Call(server.URL, secret, ServiceTaskPayload{TaskExecutionID: 7})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	want := map[string]string{"success": "true", "order_id": "A-1", "amount": "12.5", "approved": "true", "items": "[1,2]"}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("field %s = %q, want %q", name, fields[name], value)
+		}
+	}
+}
+
+func TestWebhookCallWithoutSecretIsUnsigned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(WebhookSignatureHeader); got != "" {
+			t.Errorf("unexpected signature %q", got)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := newTestWebhookService(time.Second, 0).Call(server.URL, "", map[string]string{}); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+}
+
+func TestWebhookCallRetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "done"}`))
+	}))
+	defer server.Close()
+
+	fields, err := newTestWebhookService(time.Second, 3).Call(server.URL, "", map[string]string{})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if fields["status"] != "done" {
+		t.Errorf("status = %q, want done", fields["status"])
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestWebhookCallGivesUpAfterRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := newTestWebhookService(time.Second, 2).Call(server.URL, "", map[string]string{})
+	var statusErr *webhookStatusError
+	if !errors.As(err, &statusErr) || statusErr.statusCode != http.StatusBadGateway {
+		t.Fatalf("err = %v, want a 502 status error", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestWebhookCallDoesNotRetryClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if _, err := newTestWebhookService(time.Second, 3).Call(server.URL, "", map[string]string{}); err == nil {
+		t.Fatal("Call succeeded on a 400 response")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+func TestWebhookCallTimesOut(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// The server notices the client gave up only once the body is read
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := newTestWebhookService(50*time.Millisecond, 1).Call(server.URL, "", map[string]string{})
+	var timeoutErr interface{ Timeout() bool }
+	if !errors.As(err, &timeoutErr) || !timeoutErr.Timeout() {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Call took %v, the client timeout was not applied", elapsed)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
+
+func TestWebhookCallRejectsOversizedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": "`))
+		w.Write([]byte(strings.Repeat("x", maxWebhookResponseSize)))
+		w.Write([]byte(`"}`))
+	}))
+	defer server.Close()
+
+	if _, err := newTestWebhookService(time.Second, 0).Call(server.URL, "", map[string]string{}); err == nil {
+		t.Fatal("Call accepted a response over the size limit")
+	}
+}
+
+func TestWebhookCallReportsDeclaredFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"error", `{"success": false, "error": "موجودی کافی نیست"}`, "موجودی کافی نیست"},
+		{"message", `{"success": false, "message": "rejected"}`, "rejected"},
+		{"no reason", `{"success": false}`, "وب‌هوک عدم موفقیت را اعلام کرد"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := newTestWebhookService(time.Second, 3).Call(server.URL, "", map[string]string{})
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if got := attempts.Load(); got != 1 {
+				t.Errorf("attempts = %d, want 1: a declared failure must not be retried", got)
+			}
+		})
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer server.Close()
+
+	service := NewWebhookService(NewWebhookClient(time.Second), 3, 10*time.Millisecond)
+	_, err := service.Call(server.URL, "", map[string]string{})
+	var addressErr *webhookAddressError
+	if !errors.As(err, &addressErr) {
+		t.Fatalf("err = %v, want the internal address to be refused", err)
+	}
+	if got := attempts.Load(); got != 0 {
+		t.Errorf("the server was reached %d times", got)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"ftp://example.com/hook", false},
+		{"example.com/hook", false},
+		{"https://", false},
+		{"http://localhost:9000/hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[::1]/hook", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateWebhookURL(tt.url); (err == nil) != tt.valid {
+			t.Errorf("ValidateWebhookURL(%q) = %v, want valid %v", tt.url, err, tt.valid)
+		}
+	}
+}
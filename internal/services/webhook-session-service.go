@@ -0,0 +1,41 @@
+package service
+
+import (
+	"bbb/internal/models"
+	"sync"
+)
+
+// WebhookSessionService keeps track of users typing the URL of a new webhook subscription
+type WebhookSessionService struct {
+	sessions map[int64]models.WebhookSubscription
+	mu       sync.RWMutex
+}
+
+func NewWebhookSessionService() *WebhookSessionService {
+	return &WebhookSessionService{
+		sessions: make(map[int64]models.WebhookSubscription),
+	}
+}
+
+// StartSession remembers the process or team a user is subscribing to until the URL arrives
+func (s *WebhookSessionService) StartSession(userID int64, subscription models.WebhookSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = subscription
+}
+
+func (s *WebhookSessionService) GetSession(userID int64) (models.WebhookSubscription, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscription, exists := s.sessions[userID]
+	return subscription, exists
+}
+
+func (s *WebhookSessionService) EndSession(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+}